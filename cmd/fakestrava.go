@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"stravaDataImporter/internal/strava/fake"
+)
+
+const defaultFakeStravaAddr = "localhost:8089"
+
+// runFakeStravaCommand serves the fake Strava API for local development.
+// Point the importer at it with the printed STRAVA_* variables. It serves
+// until ctx is cancelled.
+func runFakeStravaCommand(ctx context.Context, args []string, out io.Writer) error {
+	addr := defaultFakeStravaAddr
+	if len(args) > 0 {
+		addr = args[0]
+	}
+
+	fakeStrava, err := fake.New()
+	if err != nil {
+		return err
+	}
+	// Keep the fixture activities inside the regular import window
+	fakeStrava.ShiftActivities(time.Now().Add(-3 * time.Hour))
+
+	baseURL := "http://" + addr
+	_, _ = fmt.Fprintf(out, "Fake Strava listening on %s\n", baseURL)
+	_, _ = fmt.Fprintf(out, "STRAVA_API_BASE_URL=%s/api/v3\n", baseURL)
+	_, _ = fmt.Fprintf(out, "STRAVA_AUTH_URL=%s/oauth/authorize\n", baseURL)
+	_, _ = fmt.Fprintf(out, "STRAVA_TOKEN_URL=%s/oauth/token\n", baseURL)
+
+	server := &http.Server{Addr: addr, Handler: fakeStrava}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
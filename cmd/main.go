@@ -7,17 +7,20 @@ import (
 	"os/signal"
 	"syscall"
 
+	"stravaDataImporter/internal/auth"
 	"stravaDataImporter/internal/config"
 	"stravaDataImporter/internal/db"
 	"stravaDataImporter/internal/scheduler"
+	"stravaDataImporter/internal/strava"
 	"stravaDataImporter/internal/web"
 )
 
 var log *slog.Logger
 
 func main() {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	// SIGINT/SIGTERM cancel ctx, which aborts in-flight work everywhere
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	// Load configuration first
 	cfg, err := config.Load()
@@ -36,51 +39,85 @@ func main() {
 	}))
 	slog.SetDefault(log)
 
+	// Run a CLI subcommand instead of the server when one is given
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "webhook":
+			// The registered subscription ID is recorded for the server
+			influxClient, err := db.NewInfluxDBClient(ctx, cfg)
+			if err != nil {
+				log.Error("Failed to initialize InfluxDB client", "error", err)
+				os.Exit(1)
+			}
+			err = runWebhookCommand(ctx, cfg, strava.NewClient(cfg), influxClient, os.Args[2:], os.Stdout)
+			influxClient.Close()
+			if err != nil {
+				log.Error("Webhook command failed", "error", err)
+				os.Exit(1)
+			}
+			return
+		case "fake-strava":
+			if err := runFakeStravaCommand(ctx, os.Args[2:], os.Stdout); err != nil {
+				log.Error("Fake Strava server failed", "error", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	log.Info("Starting stravaDataImporter", "logLevel", cfg.LogLevel)
 
 	// Initialize InfluxDB client
-	influxClient, err := db.NewInfluxDBClient(cfg)
+	influxClient, err := db.NewInfluxDBClient(ctx, cfg)
 	if err != nil {
 		log.Error("Failed to initialize InfluxDB client", "error", err)
 		os.Exit(1)
 	}
 	defer influxClient.Close()
 
+	// The token store is shared so that the scheduler and the web handlers
+	// see the same token and never refresh it twice
+	tokenStore := auth.NewTokenStore(influxClient)
+	tokenStore.SetRefresher(strava.NewClient(cfg))
+
 	// Initialize scheduler
-	scheduler := scheduler.New(cfg, influxClient)
-	scheduler.Start()
-	defer scheduler.Stop()
+	scheduler := scheduler.New(cfg, influxClient, tokenStore)
+	scheduler.Start(ctx)
 
 	// Initialize web server
-	server, err := web.NewServer(cfg, influxClient)
+	server, err := web.NewServer(cfg, influxClient, tokenStore)
 	if err != nil {
 		log.Error("Failed to create web server", "error", err)
 		return
 	}
 
+	// Import activities announced by the Strava webhook right away
+	server.SetWebhookProcessor(scheduler)
+	server.SetReconciler(scheduler)
+	server.SetFTPSuggester(scheduler)
+
 	// Start web server in goroutine
 	go func() {
-		if err := server.Start(); err != nil {
+		if err := server.Start(ctx); err != nil {
 			log.Error("Web server error", "error", err)
-			cancel()
+			stop()
 		}
 	}()
 
-	// Wait for interrupt signal
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	<-ctx.Done()
+	log.Info("Shutting down...", "timeout", cfg.ShutdownTimeout)
 
-	select {
-	case <-sigChan:
-		log.Info("Received interrupt signal, shutting down...")
-	case <-ctx.Done():
-		log.Info("Context cancelled, shutting down...")
-	}
+	// Graceful shutdown: stop accepting requests, then wait for running jobs,
+	// both bounded by the shutdown timeout
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
 
-	// Graceful shutdown
-	if err := server.Shutdown(ctx); err != nil {
+	if err := server.Shutdown(shutdownCtx); err != nil {
 		log.Error("Error during server shutdown", "error", err)
 	}
+	if err := scheduler.Stop(shutdownCtx); err != nil {
+		log.Error("Error during scheduler shutdown", "error", err)
+	}
 
 	log.Info("Application stopped")
 }
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"stravaDataImporter/internal/config"
+	"stravaDataImporter/internal/strava"
+)
+
+const webhookUsage = `usage: strava-data-importer webhook <command>
+
+commands:
+  list                      list the registered push subscriptions
+  register [callback_url]   register the push subscription (defaults to STRAVA_WEBHOOK_CALLBACK_URL)
+  delete <subscription_id>  delete a push subscription`
+
+// subscriptionStore records the registered push subscription so that the
+// server can tell its events from forged ones
+type subscriptionStore interface {
+	SaveWebhookSubscription(ctx context.Context, subscriptionID int64) error
+	LoadWebhookSubscription(ctx context.Context) (int64, error)
+}
+
+// runWebhookCommand manages the Strava push subscription from the command line
+func runWebhookCommand(ctx context.Context, cfg *config.Config, client *strava.Client, store subscriptionStore, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(webhookUsage)
+	}
+
+	switch args[0] {
+	case "list":
+		subscriptions, err := client.ListPushSubscriptions(ctx)
+		if err != nil {
+			return err
+		}
+		if len(subscriptions) == 0 {
+			_, _ = fmt.Fprintln(out, "No push subscriptions registered")
+			return nil
+		}
+		for _, subscription := range subscriptions {
+			_, _ = fmt.Fprintf(out, "%d\t%s\t%s\n", subscription.ID, subscription.CallbackURL, subscription.CreatedAt)
+		}
+		return nil
+
+	case "register":
+		callbackURL := cfg.StravaWebhookCallbackURL
+		if len(args) > 1 {
+			callbackURL = args[1]
+		}
+		if cfg.StravaWebhookVerifyToken == "" {
+			return errors.New("STRAVA_WEBHOOK_VERIFY_TOKEN must be set to register a push subscription")
+		}
+		subscription, err := client.CreatePushSubscription(ctx, callbackURL, cfg.StravaWebhookVerifyToken)
+		if err != nil {
+			return err
+		}
+		if err := store.SaveWebhookSubscription(ctx, subscription.ID); err != nil {
+			return fmt.Errorf("registered push subscription %d but failed to record it: %w", subscription.ID, err)
+		}
+		_, _ = fmt.Fprintf(out, "Registered push subscription %d for %s\n", subscription.ID, subscription.CallbackURL)
+		return nil
+
+	case "delete":
+		if len(args) < 2 {
+			return errors.New(webhookUsage)
+		}
+		subscriptionID, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid subscription id %q: %w", args[1], err)
+		}
+		if err := client.DeletePushSubscription(ctx, subscriptionID); err != nil {
+			return err
+		}
+		if stored, err := store.LoadWebhookSubscription(ctx); err != nil {
+			return fmt.Errorf("deleted push subscription %d but failed to load the recorded one: %w", subscriptionID, err)
+		} else if stored == subscriptionID {
+			if err := store.SaveWebhookSubscription(ctx, 0); err != nil {
+				return fmt.Errorf("deleted push subscription %d but failed to clear the record: %w", subscriptionID, err)
+			}
+		}
+		_, _ = fmt.Fprintf(out, "Deleted push subscription %d\n", subscriptionID)
+		return nil
+
+	default:
+		return errors.New(webhookUsage)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"stravaDataImporter/internal/config"
+	"stravaDataImporter/internal/strava"
+	"stravaDataImporter/internal/strava/fake"
+)
+
+// memorySubscriptionStore keeps the recorded subscription ID in memory
+type memorySubscriptionStore struct {
+	subscriptionID int64
+}
+
+func (m *memorySubscriptionStore) SaveWebhookSubscription(ctx context.Context, subscriptionID int64) error {
+	m.subscriptionID = subscriptionID
+	return nil
+}
+
+func (m *memorySubscriptionStore) LoadWebhookSubscription(ctx context.Context) (int64, error) {
+	return m.subscriptionID, nil
+}
+
+func TestRunWebhookCommand(t *testing.T) {
+	fakeStrava, err := fake.New()
+	if err != nil {
+		t.Fatalf("fake.New() error = %v", err)
+	}
+	server := httptest.NewServer(fakeStrava)
+	defer server.Close()
+
+	cfg := &config.Config{
+		StravaClientID:           "test_client_id",
+		StravaClientSecret:       "test_client_secret",
+		StravaWebhookCallbackURL: "https://example.com/webhook/strava",
+	}
+	fake.Configure(cfg, server.URL)
+	client := strava.NewClient(cfg)
+	store := &memorySubscriptionStore{}
+
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		err := runWebhookCommand(context.Background(), cfg, client, store, args, &out)
+		return out.String(), err
+	}
+
+	if _, err := run("register"); err == nil {
+		t.Error("register without a verify token should fail")
+	}
+
+	cfg.StravaWebhookVerifyToken = "verify"
+	out, err := run("register")
+	if err != nil {
+		t.Fatalf("register error = %v", err)
+	}
+	if !strings.Contains(out, "Registered push subscription 1 for https://example.com/webhook/strava") {
+		t.Errorf("register output = %q", out)
+	}
+	if store.subscriptionID != 1 {
+		t.Errorf("recorded subscription = %d, want 1", store.subscriptionID)
+	}
+
+	out, err = run("list")
+	if err != nil {
+		t.Fatalf("list error = %v", err)
+	}
+	if !strings.HasPrefix(out, "1\thttps://example.com/webhook/strava") {
+		t.Errorf("list output = %q", out)
+	}
+
+	if _, err := run("delete", "1"); err != nil {
+		t.Fatalf("delete error = %v", err)
+	}
+	if len(fakeStrava.Subscriptions()) != 0 {
+		t.Error("subscription was not deleted")
+	}
+	if store.subscriptionID != 0 {
+		t.Errorf("recorded subscription = %d after delete, want 0", store.subscriptionID)
+	}
+
+	out, err = run("list")
+	if err != nil || !strings.Contains(out, "No push subscriptions registered") {
+		t.Errorf("list after delete = %q, %v", out, err)
+	}
+
+	if _, err := run("delete", "abc"); err == nil {
+		t.Error("delete with an invalid id should fail")
+	}
+	if _, err := run(); err == nil {
+		t.Error("missing command should print usage")
+	}
+}
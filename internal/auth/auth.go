@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
@@ -13,15 +14,27 @@ import (
 
 // InfluxDBTokenStore interface for dependency injection
 type InfluxDBTokenStore interface {
-	SaveToken(token *strava.TokenData) error
-	LoadToken() (*strava.TokenData, error)
-	ClearToken() error
+	SaveToken(ctx context.Context, token *strava.TokenData) error
+	LoadToken(ctx context.Context) (*strava.TokenData, error)
+	ClearToken(ctx context.Context) error
 }
 
+// TokenRefresher exchanges a refresh token for a new access token
+type TokenRefresher interface {
+	RefreshToken(ctx context.Context, refreshToken string) (*strava.TokenData, error)
+}
+
+// refreshSkew is how long before ExpiresAt a token is refreshed
+const refreshSkew = 5 * time.Minute
+
 type TokenStore struct {
 	mu          sync.RWMutex
 	influxStore InfluxDBTokenStore
 	token       *strava.TokenData
+
+	// refreshMu makes concurrent refreshes single-flight
+	refreshMu sync.Mutex
+	refresher TokenRefresher
 }
 
 func NewTokenStore(influxStore InfluxDBTokenStore) *TokenStore {
@@ -30,11 +43,16 @@ func NewTokenStore(influxStore InfluxDBTokenStore) *TokenStore {
 	}
 }
 
-func (ts *TokenStore) SaveToken(token *strava.TokenData) error {
+// SetRefresher sets how expired tokens are refreshed
+func (ts *TokenStore) SetRefresher(refresher TokenRefresher) {
+	ts.refresher = refresher
+}
+
+func (ts *TokenStore) SaveToken(ctx context.Context, token *strava.TokenData) error {
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
 
-	if err := ts.influxStore.SaveToken(token); err != nil {
+	if err := ts.influxStore.SaveToken(ctx, token); err != nil {
 		return fmt.Errorf("failed to save token to InfluxDB: %w", err)
 	}
 
@@ -43,7 +61,7 @@ func (ts *TokenStore) SaveToken(token *strava.TokenData) error {
 	return nil
 }
 
-func (ts *TokenStore) LoadToken() (*strava.TokenData, error) {
+func (ts *TokenStore) LoadToken(ctx context.Context) (*strava.TokenData, error) {
 	// 最初に読み取りロックでメモリキャッシュをチェック
 	ts.mu.RLock()
 	if ts.token != nil {
@@ -65,7 +83,7 @@ func (ts *TokenStore) LoadToken() (*strava.TokenData, error) {
 	}
 
 	slog.Debug("Loading token from InfluxDB")
-	token, err := ts.influxStore.LoadToken()
+	token, err := ts.influxStore.LoadToken(ctx)
 	if err != nil {
 		slog.Error("Failed to load token from InfluxDB", "error", err)
 		return nil, fmt.Errorf("failed to load token from InfluxDB: %w", err)
@@ -81,8 +99,86 @@ func (ts *TokenStore) LoadToken() (*strava.TokenData, error) {
 	return token, nil
 }
 
-func (ts *TokenStore) HasValidToken() bool {
-	token, err := ts.LoadToken()
+// Token returns the stored token, refreshing it first when it expires
+// within refreshSkew. It implements strava.TokenSource.
+func (ts *TokenStore) Token(ctx context.Context) (*strava.TokenData, error) {
+	token, err := ts.LoadToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, fmt.Errorf("no token found")
+	}
+
+	if isFresh(token) {
+		return token, nil
+	}
+
+	return ts.refresh(ctx, token, false)
+}
+
+// Refresh refreshes a token that Strava rejected, unless another caller has
+// already replaced it. It implements strava.TokenSource.
+func (ts *TokenStore) Refresh(ctx context.Context, rejected *strava.TokenData) (*strava.TokenData, error) {
+	return ts.refresh(ctx, rejected, true)
+}
+
+// refresh exchanges the refresh token and persists the rotated token.
+// Callers that wait on refreshMu reuse the token refreshed before them.
+func (ts *TokenStore) refresh(ctx context.Context, stale *strava.TokenData, force bool) (*strava.TokenData, error) {
+	if ts.refresher == nil {
+		return nil, fmt.Errorf("no token refresher configured")
+	}
+
+	ts.refreshMu.Lock()
+	defer ts.refreshMu.Unlock()
+
+	current, err := ts.LoadToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, fmt.Errorf("no token found")
+	}
+
+	// Another goroutine refreshed while we were waiting
+	if current.AccessToken != stale.AccessToken && isFresh(current) {
+		slog.Debug("Token already refreshed by another caller", "athlete_id", current.AthleteID)
+		return current, nil
+	}
+	if !force && isFresh(current) {
+		return current, nil
+	}
+
+	slog.Info("Refreshing access token", "athlete_id", current.AthleteID, "expires_at", current.ExpiresAt)
+	refreshed, err := ts.refresher.RefreshToken(ctx, current.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// The refresh response does not include the athlete
+	if refreshed.AthleteID == 0 {
+		refreshed.AthleteID = current.AthleteID
+	}
+	if refreshed.RefreshToken == "" {
+		refreshed.RefreshToken = current.RefreshToken
+	}
+
+	if err := ts.SaveToken(ctx, refreshed); err != nil {
+		return nil, err
+	}
+
+	return refreshed, nil
+}
+
+func isFresh(token *strava.TokenData) bool {
+	return time.Now().Add(refreshSkew).Before(token.ExpiresAt)
+}
+
+// HasValidToken reports whether a usable token is available, refreshing an
+// expired token when a refresher is configured
+func (ts *TokenStore) HasValidToken(ctx context.Context) bool {
+	token, err := ts.LoadToken(ctx)
 	if err != nil {
 		slog.Debug("Failed to load token", "error", err)
 		return false
@@ -93,16 +189,24 @@ func (ts *TokenStore) HasValidToken() bool {
 	}
 
 	// Check if token is expired (with 5 minute buffer)
-	isValid := time.Now().Add(5 * time.Minute).Before(token.ExpiresAt)
+	isValid := isFresh(token)
 	slog.Debug("Token validity check", "expires_at", token.ExpiresAt, "is_valid", isValid, "athlete_id", token.AthleteID)
-	return isValid
+	if isValid || ts.refresher == nil || token.RefreshToken == "" {
+		return isValid
+	}
+
+	if _, err := ts.Token(ctx); err != nil {
+		slog.Warn("Failed to refresh expired token", "error", err)
+		return false
+	}
+	return true
 }
 
-func (ts *TokenStore) ClearToken() error {
+func (ts *TokenStore) ClearToken(ctx context.Context) error {
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
 
-	if err := ts.influxStore.ClearToken(); err != nil {
+	if err := ts.influxStore.ClearToken(ctx); err != nil {
 		return fmt.Errorf("failed to clear token from InfluxDB: %w", err)
 	}
 
@@ -176,3 +280,76 @@ func (ss *StateStore) cleanupExpiredStates() {
 		ss.mu.Unlock()
 	}
 }
+
+// SessionTTL is how long a browser session stays signed in
+const SessionTTL = 30 * 24 * time.Hour
+
+// SessionStore keeps the sessions of the clients that completed the Strava
+// OAuth flow. A valid server token alone does not identify a client, so
+// protected routes also require one of these sessions. Sessions are held in
+// memory and end when the server restarts.
+type SessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]time.Time
+}
+
+func NewSessionStore() *SessionStore {
+	store := &SessionStore{
+		sessions: make(map[string]time.Time),
+	}
+
+	go store.cleanupExpiredSessions()
+
+	return store
+}
+
+// Create starts a new session and returns its ID
+func (ss *SessionStore) Create() (string, error) {
+	id, err := GenerateState()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate session ID: %w", err)
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ss.sessions[id] = time.Now().Add(SessionTTL)
+	return id, nil
+}
+
+// Validate reports whether the session exists and has not expired
+func (ss *SessionStore) Validate(id string) bool {
+	if id == "" {
+		return false
+	}
+
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+
+	expiry, exists := ss.sessions[id]
+	return exists && time.Now().Before(expiry)
+}
+
+// Remove ends the session
+func (ss *SessionStore) Remove(id string) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	delete(ss.sessions, id)
+}
+
+func (ss *SessionStore) cleanupExpiredSessions() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ss.mu.Lock()
+		now := time.Now()
+		for id, expiry := range ss.sessions {
+			if now.After(expiry) {
+				delete(ss.sessions, id)
+			}
+		}
+		ss.mu.Unlock()
+	}
+}
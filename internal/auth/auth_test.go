@@ -1,6 +1,10 @@
 package auth
 
 import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -12,16 +16,16 @@ type mockInfluxDBTokenStore struct {
 	token *strava.TokenData
 }
 
-func (m *mockInfluxDBTokenStore) SaveToken(token *strava.TokenData) error {
+func (m *mockInfluxDBTokenStore) SaveToken(ctx context.Context, token *strava.TokenData) error {
 	m.token = token
 	return nil
 }
 
-func (m *mockInfluxDBTokenStore) LoadToken() (*strava.TokenData, error) {
+func (m *mockInfluxDBTokenStore) LoadToken(ctx context.Context) (*strava.TokenData, error) {
 	return m.token, nil
 }
 
-func (m *mockInfluxDBTokenStore) ClearToken() error {
+func (m *mockInfluxDBTokenStore) ClearToken(ctx context.Context) error {
 	m.token = nil
 	return nil
 }
@@ -39,12 +43,12 @@ func TestTokenStore(t *testing.T) {
 		AthleteID:    12345,
 	}
 
-	err := store.SaveToken(token)
+	err := store.SaveToken(context.Background(), token)
 	if err != nil {
 		t.Fatalf("SaveToken() error = %v", err)
 	}
 
-	loadedToken, err := store.LoadToken()
+	loadedToken, err := store.LoadToken(context.Background())
 	if err != nil {
 		t.Fatalf("LoadToken() error = %v", err)
 	}
@@ -62,7 +66,7 @@ func TestTokenStore(t *testing.T) {
 	}
 
 	// Test HasValidToken
-	if !store.HasValidToken() {
+	if !store.HasValidToken(context.Background()) {
 		t.Error("HasValidToken() = false, want true")
 	}
 
@@ -75,22 +79,22 @@ func TestTokenStore(t *testing.T) {
 		AthleteID:    67890,
 	}
 
-	err = store.SaveToken(expiredToken)
+	err = store.SaveToken(context.Background(), expiredToken)
 	if err != nil {
 		t.Fatalf("SaveToken() error = %v", err)
 	}
 
-	if store.HasValidToken() {
+	if store.HasValidToken(context.Background()) {
 		t.Error("HasValidToken() = true, want false for expired token")
 	}
 
 	// Test ClearToken
-	err = store.ClearToken()
+	err = store.ClearToken(context.Background())
 	if err != nil {
-		t.Fatalf("ClearToken() error = %v", err)
+		t.Fatalf("ClearToken(ctx context.Context) error = %v", err)
 	}
 
-	if store.HasValidToken() {
+	if store.HasValidToken(context.Background()) {
 		t.Error("HasValidToken() = true, want false after clearing token")
 	}
 }
@@ -99,7 +103,7 @@ func TestTokenStoreNoFile(t *testing.T) {
 	mockStore := &mockInfluxDBTokenStore{}
 	store := NewTokenStore(mockStore)
 
-	token, err := store.LoadToken()
+	token, err := store.LoadToken(context.Background())
 	if err != nil {
 		t.Fatalf("LoadToken() error = %v", err)
 	}
@@ -108,7 +112,7 @@ func TestTokenStoreNoFile(t *testing.T) {
 		t.Error("LoadToken() = non-nil, want nil for empty store")
 	}
 
-	if store.HasValidToken() {
+	if store.HasValidToken(context.Background()) {
 		t.Error("HasValidToken() = true, want false for empty token store")
 	}
 }
@@ -161,3 +165,126 @@ func TestStateStore(t *testing.T) {
 		t.Error("ValidateAndRemove() = true, want false for invalid state")
 	}
 }
+
+func TestSessionStore(t *testing.T) {
+	store := NewSessionStore()
+
+	id, err := store.Create()
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if !store.Validate(id) {
+		t.Error("Validate() = false, want true for a new session")
+	}
+	if store.Validate("") || store.Validate("unknown_session") {
+		t.Error("Validate() = true, want false for an unknown session")
+	}
+
+	store.Remove(id)
+	if store.Validate(id) {
+		t.Error("Validate() = true, want false for a removed session")
+	}
+}
+
+// countingRefresher issues a new token for every refresh and counts calls
+type countingRefresher struct {
+	calls atomic.Int32
+}
+
+func (r *countingRefresher) RefreshToken(ctx context.Context, refreshToken string) (*strava.TokenData, error) {
+	n := r.calls.Add(1)
+	time.Sleep(10 * time.Millisecond) // widen the race window
+	return &strava.TokenData{
+		AccessToken:  fmt.Sprintf("access_%d", n),
+		RefreshToken: fmt.Sprintf("rotated_%d", n),
+		ExpiresAt:    time.Now().Add(6 * time.Hour),
+	}, nil
+}
+
+func TestTokenRefreshesBeforeExpiry(t *testing.T) {
+	mockStore := &mockInfluxDBTokenStore{}
+	store := NewTokenStore(mockStore)
+	refresher := &countingRefresher{}
+	store.SetRefresher(refresher)
+
+	_ = store.SaveToken(context.Background(), &strava.TokenData{
+		AccessToken:  "old_access",
+		RefreshToken: "old_refresh",
+		ExpiresAt:    time.Now().Add(2 * time.Minute),
+		AthleteID:    12345,
+	})
+
+	token, err := store.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	if token.AccessToken != "access_1" {
+		t.Errorf("AccessToken = %v, want access_1", token.AccessToken)
+	}
+	if token.AthleteID != 12345 {
+		t.Errorf("AthleteID = %v, want the athlete of the previous token", token.AthleteID)
+	}
+
+	// The rotated refresh token is persisted
+	if mockStore.token.RefreshToken != "rotated_1" {
+		t.Errorf("persisted RefreshToken = %v, want rotated_1", mockStore.token.RefreshToken)
+	}
+
+	// A fresh token is returned without refreshing again
+	if _, err := store.Token(context.Background()); err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	if refresher.calls.Load() != 1 {
+		t.Errorf("refresh calls = %d, want 1", refresher.calls.Load())
+	}
+}
+
+func TestTokenRefreshIsSingleFlight(t *testing.T) {
+	mockStore := &mockInfluxDBTokenStore{}
+	store := NewTokenStore(mockStore)
+	refresher := &countingRefresher{}
+	store.SetRefresher(refresher)
+
+	rejected := &strava.TokenData{
+		AccessToken:  "rejected",
+		RefreshToken: "refresh",
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}
+	_ = store.SaveToken(context.Background(), rejected)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.Refresh(context.Background(), rejected); err != nil {
+				t.Errorf("Refresh() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if refresher.calls.Load() != 1 {
+		t.Errorf("refresh calls = %d, want 1 for concurrent refreshes of the same token", refresher.calls.Load())
+	}
+}
+
+func TestHasValidTokenRefreshesExpiredToken(t *testing.T) {
+	mockStore := &mockInfluxDBTokenStore{}
+	store := NewTokenStore(mockStore)
+
+	_ = store.SaveToken(context.Background(), &strava.TokenData{
+		AccessToken:  "expired",
+		RefreshToken: "refresh",
+		ExpiresAt:    time.Now().Add(-time.Hour),
+	})
+
+	if store.HasValidToken(context.Background()) {
+		t.Error("HasValidToken() = true, want false without a refresher")
+	}
+
+	store.SetRefresher(&countingRefresher{})
+	if !store.HasValidToken(context.Background()) {
+		t.Error("HasValidToken() = false, want true after refreshing")
+	}
+}
@@ -21,6 +21,15 @@ type Config struct {
 	StravaClientSecret string
 	StravaRedirectURL  string
 
+	// Strava endpoints, overridable to point at a fake Strava server
+	StravaAPIBaseURL string
+	StravaAuthURL    string
+	StravaTokenURL   string
+
+	// Strava push subscription (webhook) configuration
+	StravaWebhookCallbackURL string
+	StravaWebhookVerifyToken string
+
 	// InfluxDB configuration
 	InfluxDBURL    string
 	InfluxDBToken  string
@@ -37,15 +46,65 @@ type Config struct {
 	TokenRefreshInterval time.Duration
 	DataImportInterval   time.Duration
 
+	// How long shutdown waits for in-flight requests and running jobs
+	ShutdownTimeout time.Duration
+
 	// Cron schedules
 	TokenRefreshCron   string
 	DataImportCron     string
 	WeeklySummaryCron  string
 	MonthlySummaryCron string
 	YearlySummaryCron  string
+	ReconcileCron      string
+	FitnessCron        string
+
+	// Number of days the reconcile job compares against Strava
+	ReconcileDays int
+
+	// Time constants of the fitness (CTL) and fatigue (ATL) averages in days
+	FitnessCTLDays int
+	FitnessATLDays int
+
+	// Timezone that summary periods and cron schedules follow
+	AthleteTimezone *time.Location
+
+	// Month in which the season of the power curve bests starts
+	SeasonStartMonth time.Month
+
+	// Number of days of best efforts the critical power model is fitted to
+	CPWindowDays int
+
+	// Danger thresholds of the overload alerts: an ACWR or monotony above
+	// and a TSB below the value raise an alert. Zero disables the alert.
+	ACWRAlertThreshold     float64
+	MonotonyAlertThreshold float64
+	TSBAlertThreshold      float64
 
 	// FTP CSV file path
 	FTPFilePath string
+
+	// Dated heart rate thresholds, threshold pace and CSS CSV file path
+	ThresholdFilePath string
+
+	// Dated body weight CSV file path
+	WeightFilePath string
+
+	// Gear maintenance intervals CSV file path
+	MaintenanceFilePath string
+
+	// Fetch per-second activity streams on import
+	ImportStreams bool
+
+	// Fetch detailed activities on import for laps and segment efforts
+	ImportLaps bool
+
+	// Fraction of the Strava rate limit budget above which background work
+	// such as the history backfill waits for the next window
+	RateLimitDeferThreshold float64
+
+	// History backfill
+	BackfillOnStartup bool
+	BackfillPageSize  int
 }
 
 func Load() (*Config, error) {
@@ -58,6 +117,11 @@ func Load() (*Config, error) {
 		StravaClientID:           getEnv("STRAVA_CLIENT_ID", ""),
 		StravaClientSecret:       getEnv("STRAVA_CLIENT_SECRET", ""),
 		StravaRedirectURL:        getEnv("STRAVA_REDIRECT_URL", "http://localhost:9090/auth/callback"),
+		StravaAPIBaseURL:         getEnv("STRAVA_API_BASE_URL", "https://www.strava.com/api/v3"),
+		StravaAuthURL:            getEnv("STRAVA_AUTH_URL", "https://www.strava.com/oauth/authorize"),
+		StravaTokenURL:           getEnv("STRAVA_TOKEN_URL", "https://www.strava.com/oauth/token"),
+		StravaWebhookCallbackURL: getEnv("STRAVA_WEBHOOK_CALLBACK_URL", "http://localhost:9090/webhook/strava"),
+		StravaWebhookVerifyToken: getEnv("STRAVA_WEBHOOK_VERIFY_TOKEN", ""),
 		InfluxDBURL:              getEnv("INFLUXDB_URL", "http://localhost:8086"),
 		InfluxDBToken:            getEnv("INFLUXDB_TOKEN", ""),
 		InfluxDBOrg:              getEnv("INFLUXDB_ORG", "my-org"),
@@ -67,6 +131,9 @@ func Load() (*Config, error) {
 		TwitterAccessToken:       getEnv("TWITTER_ACCESS_TOKEN", ""),
 		TwitterAccessTokenSecret: getEnv("TWITTER_ACCESS_TOKEN_SECRET", ""),
 		FTPFilePath:              getEnv("FTP_FILE_PATH", "./conf/ftp.csv"),
+		ThresholdFilePath:        getEnv("THRESHOLD_FILE_PATH", "./conf/thresholds.csv"),
+		WeightFilePath:           getEnv("WEIGHT_FILE_PATH", "./conf/weight.csv"),
+		MaintenanceFilePath:      getEnv("MAINTENANCE_FILE_PATH", "./conf/maintenance.csv"),
 
 		// Cron schedules with defaults
 		TokenRefreshCron:   getEnv("TOKEN_REFRESH_CRON", "0 0 2 * * *"),   // 2 AM daily
@@ -74,6 +141,8 @@ func Load() (*Config, error) {
 		WeeklySummaryCron:  getEnv("WEEKLY_SUMMARY_CRON", "0 0 3 * * 1"),  // 3 AM every Monday
 		MonthlySummaryCron: getEnv("MONTHLY_SUMMARY_CRON", "0 0 4 1 * *"), // 4 AM on the 1st of each month
 		YearlySummaryCron:  getEnv("YEARLY_SUMMARY_CRON", "0 0 5 1 1 *"),  // 5 AM on January 1st
+		ReconcileCron:      getEnv("RECONCILE_CRON", "0 30 4 * * *"),      // 4:30 AM daily
+		FitnessCron:        getEnv("FITNESS_CRON", "0 5 0 * * *"),         // 0:05 AM daily
 	}
 
 	// Parse intervals
@@ -89,6 +158,108 @@ func Load() (*Config, error) {
 	}
 	cfg.DataImportInterval = time.Duration(dataImportHours) * time.Hour
 
+	shutdownTimeoutSeconds, err := strconv.Atoi(getEnv("SHUTDOWN_TIMEOUT_SECONDS", "30"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SHUTDOWN_TIMEOUT_SECONDS: %w", err)
+	}
+	cfg.ShutdownTimeout = time.Duration(shutdownTimeoutSeconds) * time.Second
+
+	importStreams, err := strconv.ParseBool(getEnv("STRAVA_IMPORT_STREAMS", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid STRAVA_IMPORT_STREAMS: %w", err)
+	}
+	cfg.ImportStreams = importStreams
+
+	importLaps, err := strconv.ParseBool(getEnv("STRAVA_IMPORT_LAPS", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid STRAVA_IMPORT_LAPS: %w", err)
+	}
+	cfg.ImportLaps = importLaps
+
+	rateLimitDeferThreshold, err := strconv.ParseFloat(getEnv("STRAVA_RATE_LIMIT_DEFER_THRESHOLD", "0.8"), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid STRAVA_RATE_LIMIT_DEFER_THRESHOLD: %w", err)
+	}
+	cfg.RateLimitDeferThreshold = rateLimitDeferThreshold
+
+	backfillOnStartup, err := strconv.ParseBool(getEnv("BACKFILL_ON_STARTUP", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid BACKFILL_ON_STARTUP: %w", err)
+	}
+	cfg.BackfillOnStartup = backfillOnStartup
+
+	backfillPageSize, err := strconv.Atoi(getEnv("BACKFILL_PAGE_SIZE", "200"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid BACKFILL_PAGE_SIZE: %w", err)
+	}
+	cfg.BackfillPageSize = backfillPageSize
+
+	reconcileDays, err := strconv.Atoi(getEnv("RECONCILE_DAYS", "30"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RECONCILE_DAYS: %w", err)
+	}
+	cfg.ReconcileDays = reconcileDays
+
+	fitnessCTLDays, err := strconv.Atoi(getEnv("FITNESS_CTL_DAYS", "42"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid FITNESS_CTL_DAYS: %w", err)
+	}
+	if fitnessCTLDays < 1 {
+		return nil, fmt.Errorf("invalid FITNESS_CTL_DAYS: must be at least 1 day")
+	}
+	cfg.FitnessCTLDays = fitnessCTLDays
+
+	fitnessATLDays, err := strconv.Atoi(getEnv("FITNESS_ATL_DAYS", "7"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid FITNESS_ATL_DAYS: %w", err)
+	}
+	if fitnessATLDays < 1 {
+		return nil, fmt.Errorf("invalid FITNESS_ATL_DAYS: must be at least 1 day")
+	}
+	cfg.FitnessATLDays = fitnessATLDays
+
+	athleteTimezone, err := time.LoadLocation(getEnv("ATHLETE_TIMEZONE", "Local"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ATHLETE_TIMEZONE: %w", err)
+	}
+	cfg.AthleteTimezone = athleteTimezone
+
+	seasonStartMonth, err := strconv.Atoi(getEnv("SEASON_START_MONTH", "1"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SEASON_START_MONTH: %w", err)
+	}
+	if seasonStartMonth < 1 || seasonStartMonth > 12 {
+		return nil, fmt.Errorf("invalid SEASON_START_MONTH: must be between 1 and 12")
+	}
+	cfg.SeasonStartMonth = time.Month(seasonStartMonth)
+
+	cpWindowDays, err := strconv.Atoi(getEnv("CP_WINDOW_DAYS", "90"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CP_WINDOW_DAYS: %w", err)
+	}
+	if cpWindowDays < 1 {
+		return nil, fmt.Errorf("invalid CP_WINDOW_DAYS: must be at least 1 day")
+	}
+	cfg.CPWindowDays = cpWindowDays
+
+	acwrAlertThreshold, err := strconv.ParseFloat(getEnv("ALERT_ACWR_THRESHOLD", "1.5"), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ALERT_ACWR_THRESHOLD: %w", err)
+	}
+	cfg.ACWRAlertThreshold = acwrAlertThreshold
+
+	monotonyAlertThreshold, err := strconv.ParseFloat(getEnv("ALERT_MONOTONY_THRESHOLD", "2.0"), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ALERT_MONOTONY_THRESHOLD: %w", err)
+	}
+	cfg.MonotonyAlertThreshold = monotonyAlertThreshold
+
+	tsbAlertThreshold, err := strconv.ParseFloat(getEnv("ALERT_TSB_THRESHOLD", "-30"), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ALERT_TSB_THRESHOLD: %w", err)
+	}
+	cfg.TSBAlertThreshold = tsbAlertThreshold
+
 	return cfg, nil
 }
 
@@ -99,6 +270,15 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// AthleteLocation returns the athlete timezone, falling back to the server's
+// local timezone when none is configured
+func (c *Config) AthleteLocation() *time.Location {
+	if c.AthleteTimezone == nil {
+		return time.Local
+	}
+	return c.AthleteTimezone
+}
+
 // ParseLogLevel converts a log level string to slog.Level
 func (c *Config) ParseLogLevel() slog.Level {
 	switch strings.ToLower(c.LogLevel) {
@@ -14,6 +14,7 @@ func TestLoad(t *testing.T) {
 	_ = os.Setenv("STRAVA_CLIENT_ID", "test_client_id")
 	_ = os.Setenv("TOKEN_REFRESH_HOURS", "48")
 	_ = os.Setenv("DATA_IMPORT_HOURS", "2")
+	_ = os.Setenv("ATHLETE_TIMEZONE", "Asia/Tokyo")
 
 	defer func() {
 		_ = os.Unsetenv("PORT")
@@ -21,6 +22,7 @@ func TestLoad(t *testing.T) {
 		_ = os.Unsetenv("STRAVA_CLIENT_ID")
 		_ = os.Unsetenv("TOKEN_REFRESH_HOURS")
 		_ = os.Unsetenv("DATA_IMPORT_HOURS")
+		_ = os.Unsetenv("ATHLETE_TIMEZONE")
 	}()
 
 	cfg, err := Load()
@@ -47,6 +49,10 @@ func TestLoad(t *testing.T) {
 	if cfg.DataImportInterval != 2*time.Hour {
 		t.Errorf("DataImportInterval = %v, want %v", cfg.DataImportInterval, 2*time.Hour)
 	}
+
+	if cfg.AthleteLocation().String() != "Asia/Tokyo" {
+		t.Errorf("AthleteLocation = %v, want %v", cfg.AthleteLocation(), "Asia/Tokyo")
+	}
 }
 
 func TestLoadDefaults(t *testing.T) {
@@ -70,6 +76,54 @@ func TestLoadDefaults(t *testing.T) {
 	if cfg.DataImportInterval != 1*time.Hour {
 		t.Errorf("Default DataImportInterval = %v, want %v", cfg.DataImportInterval, 1*time.Hour)
 	}
+
+	if cfg.ShutdownTimeout != 30*time.Second {
+		t.Errorf("Default ShutdownTimeout = %v, want %v", cfg.ShutdownTimeout, 30*time.Second)
+	}
+
+	if cfg.BackfillOnStartup {
+		t.Error("Default BackfillOnStartup = true, want false")
+	}
+
+	if cfg.FitnessCTLDays != 42 || cfg.FitnessATLDays != 7 {
+		t.Errorf("Default fitness time constants = %d/%d, want 42/7", cfg.FitnessCTLDays, cfg.FitnessATLDays)
+	}
+
+	if cfg.SeasonStartMonth != time.January {
+		t.Errorf("Default SeasonStartMonth = %v, want January", cfg.SeasonStartMonth)
+	}
+
+	if cfg.CPWindowDays != 90 {
+		t.Errorf("Default CPWindowDays = %v, want 90", cfg.CPWindowDays)
+	}
+
+	if cfg.ACWRAlertThreshold != 1.5 || cfg.MonotonyAlertThreshold != 2 || cfg.TSBAlertThreshold != -30 {
+		t.Errorf("Default alert thresholds = ACWR %v, monotony %v, TSB %v, want 1.5/2/-30", cfg.ACWRAlertThreshold, cfg.MonotonyAlertThreshold, cfg.TSBAlertThreshold)
+	}
+
+	if cfg.AthleteLocation() != time.Local {
+		t.Errorf("Default AthleteLocation = %v, want Local", cfg.AthleteLocation())
+	}
+
+	if cfg.BackfillPageSize != 200 {
+		t.Errorf("Default BackfillPageSize = %v, want %v", cfg.BackfillPageSize, 200)
+	}
+
+	if !cfg.ImportLaps {
+		t.Error("Default ImportLaps = false, want true")
+	}
+
+	if cfg.ReconcileDays != 30 {
+		t.Errorf("Default ReconcileDays = %v, want %v", cfg.ReconcileDays, 30)
+	}
+
+	if cfg.StravaAPIBaseURL != "https://www.strava.com/api/v3" {
+		t.Errorf("Default StravaAPIBaseURL = %v, want %v", cfg.StravaAPIBaseURL, "https://www.strava.com/api/v3")
+	}
+
+	if cfg.StravaTokenURL != "https://www.strava.com/oauth/token" {
+		t.Errorf("Default StravaTokenURL = %v, want %v", cfg.StravaTokenURL, "https://www.strava.com/oauth/token")
+	}
 }
 
 func TestParseLogLevel(t *testing.T) {
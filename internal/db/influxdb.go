@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"sort"
 	"strconv"
 	"time"
 
@@ -13,33 +14,35 @@ import (
 
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
 	"github.com/influxdata/influxdb-client-go/v2/api"
+	"github.com/influxdata/influxdb-client-go/v2/api/query"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
 )
 
 type InfluxDBClient struct {
 	client   influxdb2.Client
-	writeAPI api.WriteAPI
+	writeAPI api.WriteAPIBlocking
 	queryAPI api.QueryAPI
 	bucket   string
 	org      string
 }
 
-func NewInfluxDBClient(cfg *config.Config) (*InfluxDBClient, error) {
+func NewInfluxDBClient(ctx context.Context, cfg *config.Config) (*InfluxDBClient, error) {
 	slog.Info("Creating InfluxDB client", "url", cfg.InfluxDBURL, "org", cfg.InfluxDBOrg, "bucket", cfg.InfluxDBBucket, "token_length", len(cfg.InfluxDBToken))
 
 	client := influxdb2.NewClient(cfg.InfluxDBURL, cfg.InfluxDBToken)
 
 	// Test connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	healthCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	health, err := client.Health(ctx)
+	health, err := client.Health(healthCtx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to InfluxDB: %w", err)
 	}
 
 	slog.Info("InfluxDB health check passed", "status", health.Status, "message", health.Message)
 
-	writeAPI := client.WriteAPI(cfg.InfluxDBOrg, cfg.InfluxDBBucket)
+	writeAPI := client.WriteAPIBlocking(cfg.InfluxDBOrg, cfg.InfluxDBBucket)
 	queryAPI := client.QueryAPI(cfg.InfluxDBOrg)
 
 	return &InfluxDBClient{
@@ -55,11 +58,11 @@ func (c *InfluxDBClient) Close() {
 	c.client.Close()
 }
 
-func (c *InfluxDBClient) WriteActivity(activity *strava.ActivityData) error {
+func (c *InfluxDBClient) WriteActivity(ctx context.Context, activity *strava.ActivityData) error {
 	p := influxdb2.NewPointWithMeasurement("activities").
 		AddTag("activity_id", fmt.Sprintf("%d", activity.ID)).
 		AddTag("activity_type", activity.Type).
-		AddTag("activity_name", activity.Name).
+		AddField("name", activity.Name).
 		AddField("distance", activity.Distance).
 		AddField("moving_time", activity.MovingTime).
 		AddField("elapsed_time", activity.ElapsedTime).
@@ -76,18 +79,35 @@ func (c *InfluxDBClient) WriteActivity(activity *strava.ActivityData) error {
 		AddField("ftp", activity.FTP).
 		AddField("tss", activity.TSS).
 		AddField("np", activity.NP).
+		AddField("intensity_factor", activity.IF).
+		AddField("np_source", activity.NPSource).
+		AddField("load_model", activity.LoadModel).
+		AddField("trimp", activity.TRIMP).
+		AddField("lthr", activity.LTHR).
+		AddField("ngp", activity.NGP).
+		AddField("threshold_speed", activity.ThresholdSpeed).
+		AddField("variability_index", activity.VI).
+		AddField("efficiency_factor", activity.EF).
+		AddField("decoupling", activity.Decoupling).
+		AddField("weight", activity.Weight).
+		AddField("ftp_per_kg", activity.FTPPerKg).
+		AddField("np_per_kg", activity.NPPerKg).
+		AddField("cp", activity.CP).
+		AddField("w_prime", activity.WPrime).
+		AddField("w_prime_balance_min", activity.WPrimeBalanceMin).
+		AddField("gear_id", activity.GearID).
+		AddField("timezone", activity.Timezone).
+		// Rewriting an activity in place clears an earlier deleted flag
+		AddField("deleted", activity.Deleted).
 		SetTime(activity.StartDate)
+	if !activity.StartDateLocal.IsZero() {
+		p.AddField("start_date_local", activity.StartDateLocal.Format(time.RFC3339))
+	}
+	addZoneFields(p, activity.PowerZoneSeconds, activity.HeartRateZoneSeconds)
 
-	// Check for write errors
-	errorsCh := c.writeAPI.Errors()
-	go func() {
-		for err := range errorsCh {
-			slog.Error("InfluxDB write error", "error", err)
-		}
-	}()
-
-	c.writeAPI.WritePoint(p)
-	c.writeAPI.Flush()
+	if err := c.writeAPI.WritePoint(ctx, p); err != nil {
+		return fmt.Errorf("failed to write activity: %w", err)
+	}
 
 	activityJsonStr, err := json.Marshal(activity)
 	if err == nil {
@@ -98,53 +118,390 @@ func (c *InfluxDBClient) WriteActivity(activity *strava.ActivityData) error {
 	return nil
 }
 
-func (c *InfluxDBClient) WriteWeeklySummary(summary *strava.WeeklySummary) error {
+// streamWriteBatchSize is the number of stream samples sent per write request
+const streamWriteBatchSize = 5000
+
+// WriteActivityStreams writes one activity_streams point per recorded sample
+func (c *InfluxDBClient) WriteActivityStreams(ctx context.Context, activity *strava.ActivityData, streams *strava.ActivityStreams) error {
+	activityID := fmt.Sprintf("%d", activity.ID)
+	samples := streams.Len()
+
+	points := make([]*write.Point, 0, streamWriteBatchSize)
+	for i := 0; i < samples; i++ {
+		p := influxdb2.NewPointWithMeasurement("activity_streams").
+			AddTag("activity_id", activityID).
+			SetTime(activity.StartDate.Add(time.Duration(streams.Offset(i)) * time.Second))
+
+		if i < len(streams.Watts) {
+			p.AddField("watts", streams.Watts[i])
+		}
+		if i < len(streams.Heartrate) {
+			p.AddField("heartrate", streams.Heartrate[i])
+		}
+		if i < len(streams.Cadence) {
+			p.AddField("cadence", streams.Cadence[i])
+		}
+		if i < len(streams.VelocitySmooth) {
+			p.AddField("velocity_smooth", streams.VelocitySmooth[i])
+		}
+		if i < len(streams.Altitude) {
+			p.AddField("altitude", streams.Altitude[i])
+		}
+		if i < len(streams.LatLng) {
+			p.AddField("lat", streams.LatLng[i][0])
+			p.AddField("lng", streams.LatLng[i][1])
+		}
+		if i < len(streams.Distance) {
+			p.AddField("distance", streams.Distance[i])
+		}
+		if i < len(streams.Temp) {
+			p.AddField("temp", streams.Temp[i])
+		}
+		if i < len(streams.Moving) {
+			p.AddField("moving", streams.Moving[i])
+		}
+		if i < len(streams.WPrimeBalance) {
+			p.AddField("w_prime_balance", streams.WPrimeBalance[i])
+		}
+		if len(p.FieldList()) == 0 {
+			continue
+		}
+
+		points = append(points, p)
+		if len(points) == streamWriteBatchSize {
+			if err := c.writeAPI.WritePoint(ctx, points...); err != nil {
+				return fmt.Errorf("failed to write activity streams: %w", err)
+			}
+			points = points[:0]
+		}
+	}
+	if len(points) > 0 {
+		if err := c.writeAPI.WritePoint(ctx, points...); err != nil {
+			return fmt.Errorf("failed to write activity streams: %w", err)
+		}
+	}
+
+	slog.Info("Activity streams written to InfluxDB", "activity_id", activity.ID, "samples", samples)
+	return nil
+}
+
+// MarkActivityDeleted flags a stored activity as deleted on Strava. The
+// flag is written to the existing series so the activity keeps its history.
+func (c *InfluxDBClient) MarkActivityDeleted(ctx context.Context, activityID int64) error {
+	query := fmt.Sprintf(`
+		from(bucket: "%s")
+		|> range(start: 0)
+		|> filter(fn: (r) => r._measurement == "activities")
+		|> filter(fn: (r) => r.activity_id == "%d")
+		|> filter(fn: (r) => r._field == "distance")
+		|> last()
+	`, c.bucket, activityID)
+
+	result, err := c.queryAPI.Query(ctx, query)
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+	defer func() { _ = result.Close() }()
+
+	// last() returns one row per series; an activity stored under several
+	// series, e.g. after a type change, is flagged in each of them
+	var points []*write.Point
+	for result.Next() {
+		record := result.Record()
+		p := influxdb2.NewPointWithMeasurement("activities").
+			AddTag("activity_id", fmt.Sprintf("%d", activityID)).
+			AddField("deleted", true).
+			SetTime(record.Time())
+
+		// Reuse the stored tags so the flag lands on the same series
+		for _, tag := range []string{"activity_type", "activity_name"} {
+			if val, ok := record.ValueByKey(tag).(string); ok && val != "" {
+				p.AddTag(tag, val)
+			}
+		}
+		points = append(points, p)
+	}
+	if result.Err() != nil {
+		return fmt.Errorf("query failed: %w", result.Err())
+	}
+	if len(points) == 0 {
+		slog.Info("Deleted activity not found in InfluxDB", "activity_id", activityID)
+		return nil
+	}
+
+	if err := c.writeAPI.WritePoint(ctx, points...); err != nil {
+		return fmt.Errorf("failed to write deleted flag: %w", err)
+	}
+
+	// Deleted activities no longer count towards the power curve bests
+	predicate := fmt.Sprintf(`_measurement="power_curve" AND activity_id="%d"`, activityID)
+	if err := c.client.DeleteAPI().DeleteWithName(ctx, c.org, c.bucket, time.Unix(0, 0), time.Now().Add(time.Hour), predicate); err != nil {
+		return fmt.Errorf("failed to delete power curve of activity %d: %w", activityID, err)
+	}
+
+	slog.Info("Activity marked as deleted in InfluxDB", "activity_id", activityID)
+	return nil
+}
+
+func (c *InfluxDBClient) WriteWeeklySummary(ctx context.Context, summary *strava.WeeklySummary) error {
 	p := influxdb2.NewPointWithMeasurement("weekly_summary").
 		AddTag("week_start", summary.WeekStart.Format("2006-01-02")).
 		AddField("total_tss", summary.TotalTSS).
 		AddField("total_moving_time", summary.TotalMovingTime).
 		AddField("total_distance", summary.TotalDistance).
 		AddField("total_elevation_gain", summary.TotalElevationGain).
+		AddField("total_kilojoules", summary.TotalKilojoules).
+		AddField("activity_count", summary.ActivityCount).
 		SetTime(summary.WeekStart)
+	addZoneFields(p, summary.PowerZoneSeconds, summary.HeartRateZoneSeconds)
+	points := append([]*write.Point{p}, sportSummaryPoints("weekly_summary", "week_start", summary.WeekStart, summary.ByType, summary.BySport)...)
 
-	c.writeAPI.WritePoint(p)
-	c.writeAPI.Flush()
+	if err := c.replaceSummary(ctx, points, "week_start", summary.WeekStart); err != nil {
+		return fmt.Errorf("failed to write weekly summary: %w", err)
+	}
 
-	slog.Info("Weekly summary written to InfluxDB", "week_start", summary.WeekStart)
+	slog.Info("Weekly summary written to InfluxDB", "week_start", summary.WeekStart, "activities", summary.ActivityCount)
 	return nil
 }
 
-func (c *InfluxDBClient) WriteMonthlySummary(summary *strava.MonthlySummary) error {
+func (c *InfluxDBClient) WriteMonthlySummary(ctx context.Context, summary *strava.MonthlySummary) error {
 	p := influxdb2.NewPointWithMeasurement("monthly_summary").
 		AddTag("month_start", summary.MonthStart.Format("2006-01-02")).
 		AddField("total_tss", summary.TotalTSS).
 		AddField("total_moving_time", summary.TotalMovingTime).
 		AddField("total_distance", summary.TotalDistance).
+		AddField("total_elevation_gain", summary.TotalElevationGain).
+		AddField("total_kilojoules", summary.TotalKilojoules).
+		AddField("activity_count", summary.ActivityCount).
 		SetTime(summary.MonthStart)
+	addZoneFields(p, summary.PowerZoneSeconds, summary.HeartRateZoneSeconds)
+	points := append([]*write.Point{p}, sportSummaryPoints("monthly_summary", "month_start", summary.MonthStart, summary.ByType, summary.BySport)...)
 
-	c.writeAPI.WritePoint(p)
-	c.writeAPI.Flush()
+	if err := c.replaceSummary(ctx, points, "month_start", summary.MonthStart); err != nil {
+		return fmt.Errorf("failed to write monthly summary: %w", err)
+	}
 
-	slog.Info("Monthly summary written to InfluxDB", "month_start", summary.MonthStart)
+	slog.Info("Monthly summary written to InfluxDB", "month_start", summary.MonthStart, "activities", summary.ActivityCount)
 	return nil
 }
 
-func (c *InfluxDBClient) WriteYearlySummary(summary *strava.YearlySummary) error {
+func (c *InfluxDBClient) WriteYearlySummary(ctx context.Context, summary *strava.YearlySummary) error {
 	p := influxdb2.NewPointWithMeasurement("yearly_summary").
 		AddTag("year_start", summary.YearStart.Format("2006-01-02")).
 		AddField("total_tss", summary.TotalTSS).
 		AddField("total_moving_time", summary.TotalMovingTime).
 		AddField("total_distance", summary.TotalDistance).
+		AddField("total_elevation_gain", summary.TotalElevationGain).
+		AddField("total_kilojoules", summary.TotalKilojoules).
+		AddField("activity_count", summary.ActivityCount).
 		SetTime(summary.YearStart)
+	addZoneFields(p, summary.PowerZoneSeconds, summary.HeartRateZoneSeconds)
+	points := append([]*write.Point{p}, sportSummaryPoints("yearly_summary", "year_start", summary.YearStart, summary.ByType, summary.BySport)...)
 
-	c.writeAPI.WritePoint(p)
-	c.writeAPI.Flush()
+	if err := c.replaceSummary(ctx, points, "year_start", summary.YearStart); err != nil {
+		return fmt.Errorf("failed to write yearly summary: %w", err)
+	}
 
-	slog.Info("Yearly summary written to InfluxDB", "year_start", summary.YearStart)
+	slog.Info("Yearly summary written to InfluxDB", "year_start", summary.YearStart, "activities", summary.ActivityCount)
 	return nil
 }
 
-func (c *InfluxDBClient) GetLatestActivity() (*strava.ActivityData, error) {
+// replaceSummary deletes the stored points of a summary period before writing
+// the new ones, so that recomputing a period is idempotent and leaves no zone
+// fields, sports or differently timed points of an earlier run behind
+func (c *InfluxDBClient) replaceSummary(ctx context.Context, points []*write.Point, periodTag string, periodStart time.Time) error {
+	predicate := fmt.Sprintf(`_measurement="%s" AND %s="%s"`, points[0].Name(), periodTag, periodStart.Format("2006-01-02"))
+	if err := c.client.DeleteAPI().DeleteWithName(ctx, c.org, c.bucket, time.Unix(0, 0), time.Now().Add(24*time.Hour), predicate); err != nil {
+		return fmt.Errorf("failed to delete previous summary: %w", err)
+	}
+	return c.writeAPI.WritePoint(ctx, points...)
+}
+
+// Tags of the summary points that hold the totals of one activity type or
+// sport group. The point of the combined total has neither tag.
+const (
+	summaryTypeTag  = "activity_type"
+	summarySportTag = "sport_group"
+)
+
+// sportSummaryPoints returns a point for each activity type and sport group
+// of a summary period
+func sportSummaryPoints(measurement, periodTag string, periodStart time.Time, byType, bySport []strava.SportTotals) []*write.Point {
+	points := make([]*write.Point, 0, len(byType)+len(bySport))
+	for _, breakdown := range []struct {
+		tag    string
+		totals []strava.SportTotals
+	}{{summaryTypeTag, byType}, {summarySportTag, bySport}} {
+		for _, sport := range breakdown.totals {
+			points = append(points, influxdb2.NewPointWithMeasurement(measurement).
+				AddTag(periodTag, periodStart.Format("2006-01-02")).
+				AddTag(breakdown.tag, sport.Type).
+				AddField("total_tss", sport.TotalTSS).
+				AddField("total_moving_time", sport.TotalMovingTime).
+				AddField("total_distance", sport.TotalDistance).
+				AddField("total_elevation_gain", sport.TotalElevationGain).
+				AddField("total_kilojoules", sport.TotalKilojoules).
+				AddField("activity_count", sport.ActivityCount).
+				SetTime(periodStart))
+		}
+	}
+	return points
+}
+
+// summaryPeriod is the combined total and the per-sport totals of a period
+// as stored by the summary writers
+type summaryPeriod struct {
+	start                time.Time
+	total                strava.SportTotals
+	powerZoneSeconds     []int
+	heartRateZoneSeconds []int
+	byType               []strava.SportTotals
+	bySport              []strava.SportTotals
+}
+
+// getSummaryPeriods returns the stored periods of a summary measurement that
+// start in [start, end) in date order
+func (c *InfluxDBClient) getSummaryPeriods(ctx context.Context, measurement string, start, end time.Time) ([]*summaryPeriod, error) {
+	query := fmt.Sprintf(`
+		from(bucket: "%s")
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r._measurement == "%s")
+		|> pivot(rowKey:["_time"], columnKey: ["_field"], valueColumn: "_value")
+		|> group()
+		|> sort(columns: ["_time"])
+	`, c.bucket, start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339), measurement)
+
+	result, err := c.queryAPI.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("summary query failed: %w", err)
+	}
+	defer func() { _ = result.Close() }()
+
+	var periods []*summaryPeriod
+	byStart := make(map[int64]*summaryPeriod)
+	for result.Next() {
+		record := result.Record()
+		period, ok := byStart[record.Time().Unix()]
+		if !ok {
+			period = &summaryPeriod{start: record.Time()}
+			byStart[record.Time().Unix()] = period
+			periods = append(periods, period)
+		}
+
+		totals := strava.SportTotals{
+			TotalTSS:           floatValue(record, "total_tss"),
+			TotalMovingTime:    int(floatValue(record, "total_moving_time")),
+			TotalDistance:      floatValue(record, "total_distance"),
+			TotalElevationGain: floatValue(record, "total_elevation_gain"),
+			TotalKilojoules:    floatValue(record, "total_kilojoules"),
+			ActivityCount:      int(floatValue(record, "activity_count")),
+		}
+		switch {
+		case stringValue(record, summaryTypeTag) != "":
+			totals.Type = stringValue(record, summaryTypeTag)
+			period.byType = append(period.byType, totals)
+		case stringValue(record, summarySportTag) != "":
+			totals.Type = stringValue(record, summarySportTag)
+			period.bySport = append(period.bySport, totals)
+		default:
+			period.total = totals
+			period.powerZoneSeconds = zoneSeconds(record, powerZoneField)
+			period.heartRateZoneSeconds = zoneSeconds(record, heartRateZoneField)
+		}
+	}
+	if result.Err() != nil {
+		return nil, fmt.Errorf("summary query failed: %w", result.Err())
+	}
+
+	for _, period := range periods {
+		sort.Slice(period.byType, func(i, j int) bool { return period.byType[i].Type < period.byType[j].Type })
+		sort.Slice(period.bySport, func(i, j int) bool { return period.bySport[i].Type < period.bySport[j].Type })
+	}
+	return periods, nil
+}
+
+// GetWeeklySummaries returns the weekly summaries of the weeks starting in
+// [start, end) with their per-sport totals
+func (c *InfluxDBClient) GetWeeklySummaries(ctx context.Context, start, end time.Time) ([]strava.WeeklySummary, error) {
+	periods, err := c.getSummaryPeriods(ctx, "weekly_summary", start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]strava.WeeklySummary, 0, len(periods))
+	for _, period := range periods {
+		summaries = append(summaries, strava.WeeklySummary{
+			WeekStart:            period.start,
+			TotalTSS:             period.total.TotalTSS,
+			TotalMovingTime:      period.total.TotalMovingTime,
+			TotalDistance:        period.total.TotalDistance,
+			TotalElevationGain:   period.total.TotalElevationGain,
+			TotalKilojoules:      period.total.TotalKilojoules,
+			ActivityCount:        period.total.ActivityCount,
+			PowerZoneSeconds:     period.powerZoneSeconds,
+			HeartRateZoneSeconds: period.heartRateZoneSeconds,
+			ByType:               period.byType,
+			BySport:              period.bySport,
+		})
+	}
+	return summaries, nil
+}
+
+// GetMonthlySummaries returns the monthly summaries of the months starting in
+// [start, end) with their per-sport totals
+func (c *InfluxDBClient) GetMonthlySummaries(ctx context.Context, start, end time.Time) ([]strava.MonthlySummary, error) {
+	periods, err := c.getSummaryPeriods(ctx, "monthly_summary", start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]strava.MonthlySummary, 0, len(periods))
+	for _, period := range periods {
+		summaries = append(summaries, strava.MonthlySummary{
+			MonthStart:           period.start,
+			TotalTSS:             period.total.TotalTSS,
+			TotalMovingTime:      period.total.TotalMovingTime,
+			TotalDistance:        period.total.TotalDistance,
+			TotalElevationGain:   period.total.TotalElevationGain,
+			TotalKilojoules:      period.total.TotalKilojoules,
+			ActivityCount:        period.total.ActivityCount,
+			PowerZoneSeconds:     period.powerZoneSeconds,
+			HeartRateZoneSeconds: period.heartRateZoneSeconds,
+			ByType:               period.byType,
+			BySport:              period.bySport,
+		})
+	}
+	return summaries, nil
+}
+
+// GetYearlySummaries returns the yearly summaries of the years starting in
+// [start, end) with their per-sport totals
+func (c *InfluxDBClient) GetYearlySummaries(ctx context.Context, start, end time.Time) ([]strava.YearlySummary, error) {
+	periods, err := c.getSummaryPeriods(ctx, "yearly_summary", start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]strava.YearlySummary, 0, len(periods))
+	for _, period := range periods {
+		summaries = append(summaries, strava.YearlySummary{
+			YearStart:            period.start,
+			TotalTSS:             period.total.TotalTSS,
+			TotalMovingTime:      period.total.TotalMovingTime,
+			TotalDistance:        period.total.TotalDistance,
+			TotalElevationGain:   period.total.TotalElevationGain,
+			TotalKilojoules:      period.total.TotalKilojoules,
+			ActivityCount:        period.total.ActivityCount,
+			PowerZoneSeconds:     period.powerZoneSeconds,
+			HeartRateZoneSeconds: period.heartRateZoneSeconds,
+			ByType:               period.byType,
+			BySport:              period.bySport,
+		})
+	}
+	return summaries, nil
+}
+
+func (c *InfluxDBClient) GetLatestActivity(ctx context.Context) (*strava.ActivityData, error) {
 	query := fmt.Sprintf(`
 		from(bucket: "%s")
 		|> range(start: -30d)
@@ -154,7 +511,7 @@ func (c *InfluxDBClient) GetLatestActivity() (*strava.ActivityData, error) {
 		|> pivot(rowKey:["_time"], columnKey: ["_field"], valueColumn: "_value")
 	`, c.bucket)
 
-	result, err := c.queryAPI.Query(context.Background(), query)
+	result, err := c.queryAPI.Query(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
@@ -164,151 +521,1097 @@ func (c *InfluxDBClient) GetLatestActivity() (*strava.ActivityData, error) {
 		return nil, nil // No activities found
 	}
 
-	record := result.Record()
-	activity := &strava.ActivityData{}
+	return parseActivityRecord(result.Record()), nil
+}
+
+// GetActivities returns the activities stored with a start date in [start, end).
+// An activity stored under more than one series is returned once per series.
+func (c *InfluxDBClient) GetActivities(ctx context.Context, start, end time.Time) ([]strava.ActivityData, error) {
+	query := fmt.Sprintf(`
+		from(bucket: "%s")
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r._measurement == "activities")
+		|> pivot(rowKey:["_time"], columnKey: ["_field"], valueColumn: "_value")
+	`, c.bucket, start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339))
+
+	result, err := c.queryAPI.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("activities query failed: %w", err)
+	}
+	defer func() { _ = result.Close() }()
+
+	var activities []strava.ActivityData
+	for result.Next() {
+		activities = append(activities, *parseActivityRecord(result.Record()))
+	}
+	if result.Err() != nil {
+		return nil, fmt.Errorf("activities query failed: %w", result.Err())
+	}
+
+	return activities, nil
+}
+
+// GetActivitySeries returns every stored series of an activity. More than
+// one is returned when an edit left a series with an outdated tag behind.
+func (c *InfluxDBClient) GetActivitySeries(ctx context.Context, activityID int64) ([]strava.ActivityData, error) {
+	query := fmt.Sprintf(`
+		from(bucket: "%s")
+		|> range(start: 0)
+		|> filter(fn: (r) => r._measurement == "activities" and r.activity_id == "%d")
+		|> pivot(rowKey:["_time"], columnKey: ["_field"], valueColumn: "_value")
+	`, c.bucket, activityID)
+
+	result, err := c.queryAPI.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("activity series query failed: %w", err)
+	}
+	defer func() { _ = result.Close() }()
+
+	var series []strava.ActivityData
+	for result.Next() {
+		series = append(series, *parseActivityRecord(result.Record()))
+	}
+	if result.Err() != nil {
+		return nil, fmt.Errorf("activity series query failed: %w", result.Err())
+	}
 
-	// Parse activity ID from tag
-	if val := record.ValueByKey("activity_id"); val != nil {
-		if idStr, ok := val.(string); ok {
-			if id, err := strconv.ParseInt(idStr, 10, 64); err == nil {
-				activity.ID = id
+	return series, nil
+}
+
+// activityMeasurements are the measurements holding per-activity points
+var activityMeasurements = []string{"activities", "activity_streams", "laps", "segment_efforts", "power_curve"}
+
+// DeleteActivityPoints removes the points of an activity in [start, stop).
+// With an activity type only the series tagged with that type are removed,
+// which are those of the activities and laps measurements.
+func (c *InfluxDBClient) DeleteActivityPoints(ctx context.Context, activityID int64, activityType string, start, stop time.Time) error {
+	deleteAPI := c.client.DeleteAPI()
+
+	for _, measurement := range activityMeasurements {
+		predicate := fmt.Sprintf(`_measurement="%s" AND activity_id="%d"`, measurement, activityID)
+		if activityType != "" {
+			if measurement != "activities" && measurement != "laps" {
+				continue
 			}
+			predicate += fmt.Sprintf(` AND activity_type="%s"`, activityType)
+		}
+		if err := deleteAPI.DeleteWithName(ctx, c.org, c.bucket, start, stop, predicate); err != nil {
+			return fmt.Errorf("failed to delete %s of activity %d: %w", measurement, activityID, err)
 		}
 	}
 
-	// Parse activity name from tag
-	if val := record.ValueByKey("activity_name"); val != nil {
-		if name, ok := val.(string); ok {
-			activity.Name = name
+	slog.Debug("Activity points deleted from InfluxDB", "activity_id", activityID, "activity_type", activityType, "start", start, "stop", stop)
+	return nil
+}
+
+// DeleteLegacyActivitySeries removes the series of an activity that carry
+// the name as the activity_name tag, as activities were stored before the
+// name became a field
+func (c *InfluxDBClient) DeleteLegacyActivitySeries(ctx context.Context, activityID int64) error {
+	query := fmt.Sprintf(`
+		from(bucket: "%s")
+		|> range(start: 0)
+		|> filter(fn: (r) => r._measurement == "activities" and r.activity_id == "%d" and exists r.activity_name)
+		|> keep(columns: ["activity_name"])
+		|> distinct(column: "activity_name")
+	`, c.bucket, activityID)
+
+	result, err := c.queryAPI.Query(ctx, query)
+	if err != nil {
+		return fmt.Errorf("legacy activity series query failed: %w", err)
+	}
+	defer func() { _ = result.Close() }()
+
+	var names []string
+	for result.Next() {
+		if name, ok := result.Record().Value().(string); ok {
+			names = append(names, name)
 		}
 	}
+	if result.Err() != nil {
+		return fmt.Errorf("legacy activity series query failed: %w", result.Err())
+	}
 
-	// Parse activity type from tag
-	if val := record.ValueByKey("activity_type"); val != nil {
-		if actType, ok := val.(string); ok {
-			activity.Type = actType
+	deleteAPI := c.client.DeleteAPI()
+	for _, name := range names {
+		predicate := fmt.Sprintf(`_measurement="activities" AND activity_id="%d" AND activity_name=%s`, activityID, strconv.Quote(name))
+		if err := deleteAPI.DeleteWithName(ctx, c.org, c.bucket, time.Unix(0, 0), time.Now().Add(time.Hour), predicate); err != nil {
+			return fmt.Errorf("failed to delete legacy series of activity %d: %w", activityID, err)
 		}
 	}
 
-	// Parse numeric fields
-	activity.StartDate = record.Time()
+	if len(names) > 0 {
+		slog.Debug("Legacy activity series deleted from InfluxDB", "activity_id", activityID, "series", len(names))
+	}
+	return nil
+}
 
-	if val := record.ValueByKey("distance"); val != nil {
-		if distance, ok := val.(float64); ok {
-			activity.Distance = distance
-		}
+// parseActivityRecord converts a pivoted activities row into ActivityData
+func parseActivityRecord(record *query.FluxRecord) *strava.ActivityData {
+	activity := &strava.ActivityData{
+		StartDate: record.Time(),
 	}
 
-	if val := record.ValueByKey("moving_time"); val != nil {
-		if movingTime, ok := val.(float64); ok {
-			activity.MovingTime = int(movingTime)
-		}
+	// Parse activity ID and type from tags
+	if id, err := strconv.ParseInt(stringValue(record, "activity_id"), 10, 64); err == nil {
+		activity.ID = id
+	}
+	activity.Type = stringValue(record, "activity_type")
+
+	// The name is a field; older points stored it as the activity_name tag
+	activity.Name = stringValue(record, "name")
+	if activity.Name == "" {
+		activity.Name = stringValue(record, "activity_name")
+	}
+
+	activity.Distance = floatValue(record, "distance")
+	activity.MovingTime = int(floatValue(record, "moving_time"))
+	activity.ElapsedTime = int(floatValue(record, "elapsed_time"))
+	activity.TotalElevationGain = floatValue(record, "total_elevation_gain")
+	activity.AverageSpeed = floatValue(record, "average_speed")
+	activity.MaxSpeed = floatValue(record, "max_speed")
+	activity.Calories = floatValue(record, "calories")
+	activity.AverageHeartrate = floatValue(record, "average_heartrate")
+	activity.MaxHeartrate = floatValue(record, "max_heartrate")
+	activity.AverageWatts = floatValue(record, "average_watts")
+	activity.MaxWatts = floatValue(record, "max_watts")
+	activity.WeightedAverageWatts = floatValue(record, "weighted_average_watts")
+	activity.Kilojoules = floatValue(record, "kilojoules")
+	activity.FTP = floatValue(record, "ftp")
+	activity.TSS = floatValue(record, "tss")
+	activity.NP = floatValue(record, "np")
+	activity.IF = floatValue(record, "intensity_factor")
+	activity.NPSource = stringValue(record, "np_source")
+	activity.LoadModel = stringValue(record, "load_model")
+	activity.TRIMP = floatValue(record, "trimp")
+	activity.LTHR = floatValue(record, "lthr")
+	activity.NGP = floatValue(record, "ngp")
+	activity.ThresholdSpeed = floatValue(record, "threshold_speed")
+	activity.VI = floatValue(record, "variability_index")
+	activity.EF = floatValue(record, "efficiency_factor")
+	activity.Decoupling = floatValue(record, "decoupling")
+	activity.Weight = floatValue(record, "weight")
+	activity.FTPPerKg = floatValue(record, "ftp_per_kg")
+	activity.NPPerKg = floatValue(record, "np_per_kg")
+	activity.CP = floatValue(record, "cp")
+	activity.WPrime = floatValue(record, "w_prime")
+	activity.WPrimeBalanceMin = floatValue(record, "w_prime_balance_min")
+	activity.GearID = stringValue(record, "gear_id")
+	activity.Timezone = stringValue(record, "timezone")
+	if startDateLocal, err := time.Parse(time.RFC3339, stringValue(record, "start_date_local")); err == nil {
+		activity.StartDateLocal = startDateLocal
+	}
+	activity.PowerZoneSeconds = zoneSeconds(record, powerZoneField)
+	activity.HeartRateZoneSeconds = zoneSeconds(record, heartRateZoneField)
+
+	if deleted, ok := record.ValueByKey("deleted").(bool); ok {
+		activity.Deleted = deleted
+	}
+
+	return activity
+}
+
+// Field name formats for the seconds spent in each zone, numbered from 1
+const (
+	powerZoneField     = "power_zone_%d_seconds"
+	heartRateZoneField = "hr_zone_%d_seconds"
+)
+
+// addZoneFields adds the time in each power and heart rate zone to a point
+func addZoneFields(p *write.Point, powerZoneSeconds, heartRateZoneSeconds []int) {
+	for i, seconds := range powerZoneSeconds {
+		p.AddField(fmt.Sprintf(powerZoneField, i+1), seconds)
 	}
+	for i, seconds := range heartRateZoneSeconds {
+		p.AddField(fmt.Sprintf(heartRateZoneField, i+1), seconds)
+	}
+}
 
-	if val := record.ValueByKey("elapsed_time"); val != nil {
-		if elapsedTime, ok := val.(float64); ok {
-			activity.ElapsedTime = int(elapsedTime)
+// zoneSeconds reads the zone fields written by addZoneFields
+func zoneSeconds(record *query.FluxRecord, format string) []int {
+	var seconds []int
+	for i := 1; ; i++ {
+		val := record.ValueByKey(fmt.Sprintf(format, i))
+		if val == nil {
+			return seconds
 		}
+		seconds = append(seconds, int(floatValue(record, fmt.Sprintf(format, i))))
+	}
+}
+
+// floatValue reads a numeric column. Integer fields are returned as int64.
+func floatValue(record *query.FluxRecord, key string) float64 {
+	switch val := record.ValueByKey(key).(type) {
+	case float64:
+		return val
+	case int64:
+		return float64(val)
+	case uint64:
+		return float64(val)
+	}
+	return 0
+}
+
+func stringValue(record *query.FluxRecord, key string) string {
+	val, _ := record.ValueByKey(key).(string)
+	return val
+}
+
+func (c *InfluxDBClient) GetWeeklyTrend(ctx context.Context) ([]strava.WeeklySummary, error) {
+	query := fmt.Sprintf(`
+		from(bucket: "%s")
+		|> range(start: -8w)
+		|> filter(fn: (r) => r._measurement == "weekly_summary" and not exists r.activity_type and not exists r.sport_group)
+		|> pivot(rowKey:["_time"], columnKey: ["_field"], valueColumn: "_value")
+		|> group()
+		|> sort(columns: ["_time"], desc: false)
+	`, c.bucket)
+
+	result, err := c.queryAPI.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer func() { _ = result.Close() }()
+
+	var summaries []strava.WeeklySummary
+	for result.Next() {
+		record := result.Record()
+		summaries = append(summaries, strava.WeeklySummary{
+			WeekStart:            record.Time(),
+			TotalTSS:             floatValue(record, "total_tss"),
+			TotalMovingTime:      int(floatValue(record, "total_moving_time")),
+			TotalDistance:        floatValue(record, "total_distance"),
+			TotalElevationGain:   floatValue(record, "total_elevation_gain"),
+			TotalKilojoules:      floatValue(record, "total_kilojoules"),
+			ActivityCount:        int(floatValue(record, "activity_count")),
+			PowerZoneSeconds:     zoneSeconds(record, powerZoneField),
+			HeartRateZoneSeconds: zoneSeconds(record, heartRateZoneField),
+		})
+	}
+	if result.Err() != nil {
+		return nil, fmt.Errorf("query error: %w", result.Err())
+	}
+
+	return summaries, nil
+}
+
+// WriteFitness writes one fitness point per day. Recomputed days overwrite
+// the stored point of the same day.
+func (c *InfluxDBClient) WriteFitness(ctx context.Context, days []strava.FitnessDay) error {
+	points := make([]*write.Point, 0, len(days))
+	for _, day := range days {
+		points = append(points, influxdb2.NewPointWithMeasurement("fitness").
+			AddField("tss", day.TSS).
+			AddField("ctl", day.CTL).
+			AddField("atl", day.ATL).
+			AddField("tsb", day.TSB).
+			AddField("ctl_days", day.CTLDays).
+			AddField("atl_days", day.ATLDays).
+			SetTime(day.Date))
+	}
+
+	if err := c.writeAPI.WritePoint(ctx, points...); err != nil {
+		return fmt.Errorf("failed to write fitness: %w", err)
+	}
+
+	slog.Debug("Fitness written to InfluxDB", "days", len(days))
+	return nil
+}
+
+// GetFitness returns the fitness days in [start, end) in date order
+func (c *InfluxDBClient) GetFitness(ctx context.Context, start, end time.Time) ([]strava.FitnessDay, error) {
+	query := fmt.Sprintf(`
+		from(bucket: "%s")
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r._measurement == "fitness")
+		|> pivot(rowKey:["_time"], columnKey: ["_field"], valueColumn: "_value")
+		|> sort(columns: ["_time"])
+	`, c.bucket, start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339))
+
+	result, err := c.queryAPI.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("fitness query failed: %w", err)
+	}
+	defer func() { _ = result.Close() }()
+
+	var days []strava.FitnessDay
+	for result.Next() {
+		record := result.Record()
+		days = append(days, strava.FitnessDay{
+			Date:    record.Time(),
+			TSS:     floatValue(record, "tss"),
+			CTL:     floatValue(record, "ctl"),
+			ATL:     floatValue(record, "atl"),
+			TSB:     floatValue(record, "tsb"),
+			CTLDays: int(floatValue(record, "ctl_days")),
+			ATLDays: int(floatValue(record, "atl_days")),
+		})
+	}
+	if result.Err() != nil {
+		return nil, fmt.Errorf("fitness query failed: %w", result.Err())
+	}
+
+	return days, nil
+}
+
+// WriteWorkload writes the workload days, overwriting stored days
+func (c *InfluxDBClient) WriteWorkload(ctx context.Context, days []strava.WorkloadDay) error {
+	points := make([]*write.Point, 0, len(days))
+	for _, day := range days {
+		points = append(points, influxdb2.NewPointWithMeasurement("workload").
+			AddField("acute_load", day.AcuteLoad).
+			AddField("chronic_load", day.ChronicLoad).
+			AddField("acwr", day.ACWR).
+			AddField("monotony", day.Monotony).
+			AddField("strain", day.Strain).
+			AddField("tsb", day.TSB).
+			SetTime(day.Date))
+	}
+
+	if err := c.writeAPI.WritePoint(ctx, points...); err != nil {
+		return fmt.Errorf("failed to write workload: %w", err)
+	}
+
+	slog.Debug("Workload written to InfluxDB", "days", len(days))
+	return nil
+}
+
+// GetWorkload returns the workload days in [start, end) in date order
+func (c *InfluxDBClient) GetWorkload(ctx context.Context, start, end time.Time) ([]strava.WorkloadDay, error) {
+	query := fmt.Sprintf(`
+		from(bucket: "%s")
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r._measurement == "workload")
+		|> pivot(rowKey:["_time"], columnKey: ["_field"], valueColumn: "_value")
+		|> sort(columns: ["_time"])
+	`, c.bucket, start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339))
+
+	result, err := c.queryAPI.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("workload query failed: %w", err)
+	}
+	defer func() { _ = result.Close() }()
+
+	var days []strava.WorkloadDay
+	for result.Next() {
+		record := result.Record()
+		days = append(days, strava.WorkloadDay{
+			Date:        record.Time(),
+			AcuteLoad:   floatValue(record, "acute_load"),
+			ChronicLoad: floatValue(record, "chronic_load"),
+			ACWR:        floatValue(record, "acwr"),
+			Monotony:    floatValue(record, "monotony"),
+			Strain:      floatValue(record, "strain"),
+			TSB:         floatValue(record, "tsb"),
+		})
+	}
+	if result.Err() != nil {
+		return nil, fmt.Errorf("workload query failed: %w", result.Err())
 	}
 
-	if val := record.ValueByKey("total_elevation_gain"); val != nil {
-		if elevation, ok := val.(float64); ok {
-			activity.TotalElevationGain = elevation
+	return days, nil
+}
+
+// WriteRollingSummaries writes the rolling summaries, each at the start of
+// the last day of its window and tagged with the window length. Rewriting
+// the same day replaces the earlier values.
+func (c *InfluxDBClient) WriteRollingSummaries(ctx context.Context, summaries []strava.RollingSummary) error {
+	points := make([]*write.Point, 0, len(summaries))
+	for _, summary := range summaries {
+		points = append(points, influxdb2.NewPointWithMeasurement("rolling_summary").
+			AddTag("window_days", strconv.Itoa(summary.Days)).
+			AddField("total_tss", summary.TotalTSS).
+			AddField("total_moving_time", summary.TotalMovingTime).
+			AddField("total_distance", summary.TotalDistance).
+			AddField("total_elevation_gain", summary.TotalElevationGain).
+			AddField("activity_count", summary.ActivityCount).
+			SetTime(summary.End.AddDate(0, 0, -1)))
+	}
+
+	if err := c.writeAPI.WritePoint(ctx, points...); err != nil {
+		return fmt.Errorf("failed to write rolling summaries: %w", err)
+	}
+
+	slog.Debug("Rolling summaries written to InfluxDB", "windows", len(summaries))
+	return nil
+}
+
+// GetRollingSummaries returns the rolling summaries of the days in
+// [start, end) in date order, shorter windows first within a day
+func (c *InfluxDBClient) GetRollingSummaries(ctx context.Context, start, end time.Time) ([]strava.RollingSummary, error) {
+	query := fmt.Sprintf(`
+		from(bucket: "%s")
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r._measurement == "rolling_summary")
+		|> pivot(rowKey:["_time"], columnKey: ["_field"], valueColumn: "_value")
+		|> group()
+		|> sort(columns: ["_time"])
+	`, c.bucket, start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339))
+
+	result, err := c.queryAPI.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("rolling summary query failed: %w", err)
+	}
+	defer func() { _ = result.Close() }()
+
+	var summaries []strava.RollingSummary
+	for result.Next() {
+		record := result.Record()
+		days, err := strconv.Atoi(stringValue(record, "window_days"))
+		if err != nil {
+			slog.Warn("Skipping rolling summary with invalid window", "window_days", record.ValueByKey("window_days"))
+			continue
 		}
+		windowEnd := record.Time().AddDate(0, 0, 1)
+		summaries = append(summaries, strava.RollingSummary{
+			Days:               days,
+			Start:              windowEnd.AddDate(0, 0, -days),
+			End:                windowEnd,
+			TotalTSS:           floatValue(record, "total_tss"),
+			TotalMovingTime:    int(floatValue(record, "total_moving_time")),
+			TotalDistance:      floatValue(record, "total_distance"),
+			TotalElevationGain: floatValue(record, "total_elevation_gain"),
+			ActivityCount:      int(floatValue(record, "activity_count")),
+		})
+	}
+	if result.Err() != nil {
+		return nil, fmt.Errorf("rolling summary query failed: %w", result.Err())
 	}
 
-	if val := record.ValueByKey("calories"); val != nil {
-		if calories, ok := val.(float64); ok {
-			activity.Calories = calories
+	sort.SliceStable(summaries, func(i, j int) bool {
+		if !summaries[i].End.Equal(summaries[j].End) {
+			return summaries[i].End.Before(summaries[j].End)
+		}
+		return summaries[i].Days < summaries[j].Days
+	})
+	return summaries, nil
+}
+
+// powerCurveField is the field name format of the best power for a duration
+// in seconds
+const powerCurveField = "best_%ds_watts"
+
+// WritePowerCurve writes the mean-maximal power of an activity at its start
+// time
+func (c *InfluxDBClient) WritePowerCurve(ctx context.Context, curve *strava.PowerCurve) error {
+	p := influxdb2.NewPointWithMeasurement("power_curve").
+		AddTag("activity_id", fmt.Sprintf("%d", curve.ActivityID)).
+		AddField("weight", curve.Weight).
+		SetTime(curve.StartDate)
+	for i, watts := range curve.Watts {
+		if i < len(strava.PowerCurveDurations) {
+			p.AddField(fmt.Sprintf(powerCurveField, strava.PowerCurveDurations[i]), watts)
 		}
 	}
 
-	if val := record.ValueByKey("average_watts"); val != nil {
-		if watts, ok := val.(float64); ok {
-			activity.AverageWatts = watts
+	if err := c.writeAPI.WritePoint(ctx, p); err != nil {
+		return fmt.Errorf("failed to write power curve: %w", err)
+	}
+
+	slog.Debug("Power curve written to InfluxDB", "activity_id", curve.ActivityID)
+	return nil
+}
+
+// GetPowerCurves returns the power curves of the activities that started in
+// [start, end) in date order
+func (c *InfluxDBClient) GetPowerCurves(ctx context.Context, start, end time.Time) ([]strava.PowerCurve, error) {
+	query := fmt.Sprintf(`
+		from(bucket: "%s")
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r._measurement == "power_curve")
+		|> pivot(rowKey:["_time"], columnKey: ["_field"], valueColumn: "_value")
+		|> group()
+		|> sort(columns: ["_time"])
+	`, c.bucket, start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339))
+
+	result, err := c.queryAPI.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("power curve query failed: %w", err)
+	}
+	defer func() { _ = result.Close() }()
+
+	var curves []strava.PowerCurve
+	for result.Next() {
+		record := result.Record()
+		activityID, _ := strconv.ParseInt(stringValue(record, "activity_id"), 10, 64)
+		curve := strava.PowerCurve{
+			ActivityID: activityID,
+			StartDate:  record.Time(),
+			Watts:      make([]float64, len(strava.PowerCurveDurations)),
+			Weight:     floatValue(record, "weight"),
 		}
+		for i, duration := range strava.PowerCurveDurations {
+			curve.Watts[i] = floatValue(record, fmt.Sprintf(powerCurveField, duration))
+		}
+		curves = append(curves, curve)
+	}
+	if result.Err() != nil {
+		return nil, fmt.Errorf("power curve query failed: %w", result.Err())
+	}
+
+	return curves, nil
+}
+
+// WriteCriticalPower writes a critical power fit. A refit on the same day
+// overwrites the stored point of that day.
+func (c *InfluxDBClient) WriteCriticalPower(ctx context.Context, fit *strava.CriticalPower) error {
+	p := influxdb2.NewPointWithMeasurement("critical_power").
+		AddField("cp", fit.CP).
+		AddField("w_prime", fit.WPrime).
+		AddField("window_days", fit.WindowDays).
+		AddField("efforts", fit.Efforts).
+		AddField("ftp", fit.FTP).
+		SetTime(fit.Date)
+
+	if err := c.writeAPI.WritePoint(ctx, p); err != nil {
+		return fmt.Errorf("failed to write critical power: %w", err)
+	}
+
+	slog.Debug("Critical power written to InfluxDB", "date", fit.Date, "cp", fit.CP, "w_prime", fit.WPrime)
+	return nil
+}
+
+// GetCriticalPower returns the critical power fits in [start, end) in date
+// order
+func (c *InfluxDBClient) GetCriticalPower(ctx context.Context, start, end time.Time) ([]strava.CriticalPower, error) {
+	query := fmt.Sprintf(`
+		from(bucket: "%s")
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r._measurement == "critical_power")
+		|> pivot(rowKey:["_time"], columnKey: ["_field"], valueColumn: "_value")
+		|> sort(columns: ["_time"])
+	`, c.bucket, start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339))
+
+	result, err := c.queryAPI.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("critical power query failed: %w", err)
+	}
+	defer func() { _ = result.Close() }()
+
+	var fits []strava.CriticalPower
+	for result.Next() {
+		record := result.Record()
+		fits = append(fits, strava.CriticalPower{
+			Date:       record.Time(),
+			CP:         floatValue(record, "cp"),
+			WPrime:     floatValue(record, "w_prime"),
+			WindowDays: int(floatValue(record, "window_days")),
+			Efforts:    int(floatValue(record, "efforts")),
+			FTP:        floatValue(record, "ftp"),
+		})
+	}
+	if result.Err() != nil {
+		return nil, fmt.Errorf("critical power query failed: %w", result.Err())
+	}
+
+	return fits, nil
+}
+
+// WriteWeight writes a body weight record. A record of the same source on
+// the same day overwrites the stored one.
+func (c *InfluxDBClient) WriteWeight(ctx context.Context, record *strava.WeightRecord) error {
+	p := influxdb2.NewPointWithMeasurement("athlete_weight").
+		AddTag("source", record.Source).
+		AddField("weight", record.Weight).
+		SetTime(record.Date)
+
+	if err := c.writeAPI.WritePoint(ctx, p); err != nil {
+		return fmt.Errorf("failed to write weight: %w", err)
+	}
+
+	slog.Debug("Weight written to InfluxDB", "date", record.Date, "weight", record.Weight, "source", record.Source)
+	return nil
+}
+
+// GetWeights returns every stored body weight record in date order
+func (c *InfluxDBClient) GetWeights(ctx context.Context) ([]strava.WeightRecord, error) {
+	query := fmt.Sprintf(`
+		from(bucket: "%s")
+		|> range(start: 0)
+		|> filter(fn: (r) => r._measurement == "athlete_weight" and r._field == "weight")
+		|> group()
+		|> sort(columns: ["_time"])
+	`, c.bucket)
+
+	result, err := c.queryAPI.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("weight query failed: %w", err)
+	}
+	defer func() { _ = result.Close() }()
+
+	var records []strava.WeightRecord
+	for result.Next() {
+		record := result.Record()
+		records = append(records, strava.WeightRecord{
+			Date:   record.Time(),
+			Weight: floatValue(record, "_value"),
+			Source: stringValue(record, "source"),
+		})
+	}
+	if result.Err() != nil {
+		return nil, fmt.Errorf("weight query failed: %w", result.Err())
+	}
+
+	return records, nil
+}
+
+// WriteFTPSuggestion writes an FTP suggestion. Writing it again with a new
+// status overwrites the stored one.
+func (c *InfluxDBClient) WriteFTPSuggestion(ctx context.Context, suggestion *strava.FTPSuggestion) error {
+	p := influxdb2.NewPointWithMeasurement("ftp_suggestions").
+		AddTag("suggestion_id", suggestion.ID).
+		AddField("ftp", suggestion.FTP).
+		AddField("current_ftp", suggestion.CurrentFTP).
+		AddField("source", suggestion.Source).
+		AddField("evidence_watts", suggestion.EvidenceWatts).
+		AddField("activity_id", suggestion.ActivityID).
+		AddField("status", suggestion.Status).
+		SetTime(suggestion.Date)
+
+	if err := c.writeAPI.WritePoint(ctx, p); err != nil {
+		return fmt.Errorf("failed to write FTP suggestion: %w", err)
+	}
+
+	slog.Debug("FTP suggestion written to InfluxDB", "id", suggestion.ID, "ftp", suggestion.FTP, "status", suggestion.Status)
+	return nil
+}
+
+// GetFTPSuggestions returns every FTP suggestion in date order
+func (c *InfluxDBClient) GetFTPSuggestions(ctx context.Context) ([]strava.FTPSuggestion, error) {
+	query := fmt.Sprintf(`
+		from(bucket: "%s")
+		|> range(start: 0)
+		|> filter(fn: (r) => r._measurement == "ftp_suggestions")
+		|> pivot(rowKey:["_time"], columnKey: ["_field"], valueColumn: "_value")
+		|> group()
+		|> sort(columns: ["_time"])
+	`, c.bucket)
+
+	result, err := c.queryAPI.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("FTP suggestion query failed: %w", err)
+	}
+	defer func() { _ = result.Close() }()
+
+	var suggestions []strava.FTPSuggestion
+	for result.Next() {
+		record := result.Record()
+		suggestions = append(suggestions, strava.FTPSuggestion{
+			ID:            stringValue(record, "suggestion_id"),
+			Date:          record.Time(),
+			FTP:           floatValue(record, "ftp"),
+			CurrentFTP:    floatValue(record, "current_ftp"),
+			Source:        stringValue(record, "source"),
+			EvidenceWatts: floatValue(record, "evidence_watts"),
+			ActivityID:    int64(floatValue(record, "activity_id")),
+			Status:        stringValue(record, "status"),
+		})
+	}
+	if result.Err() != nil {
+		return nil, fmt.Errorf("FTP suggestion query failed: %w", result.Err())
+	}
+
+	return suggestions, nil
+}
+
+// SaveBackfillState records how far the history backfill has progressed
+func (c *InfluxDBClient) SaveBackfillState(ctx context.Context, state *strava.BackfillState) error {
+	p := influxdb2.NewPointWithMeasurement("backfill_state").
+		AddField("before", state.Before.Unix()).
+		AddField("imported", state.Imported).
+		AddField("completed", state.Completed).
+		SetTime(time.Now())
+
+	if err := c.writeAPI.WritePoint(ctx, p); err != nil {
+		return fmt.Errorf("failed to save backfill state: %w", err)
+	}
+
+	slog.Debug("Backfill state saved to InfluxDB", "before", state.Before, "imported", state.Imported, "completed", state.Completed)
+	return nil
+}
+
+// LoadBackfillState returns the most recently saved backfill state, or nil if
+// no backfill has been started yet
+func (c *InfluxDBClient) LoadBackfillState(ctx context.Context) (*strava.BackfillState, error) {
+	query := fmt.Sprintf(`
+		from(bucket: "%s")
+		|> range(start: 0)
+		|> filter(fn: (r) => r._measurement == "backfill_state")
+		|> last()
+		|> pivot(rowKey:["_time"], columnKey: ["_field"], valueColumn: "_value")
+	`, c.bucket)
+
+	result, err := c.queryAPI.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("backfill state query failed: %w", err)
+	}
+	defer func() { _ = result.Close() }()
+
+	if !result.Next() {
+		return nil, nil // No backfill has run yet
+	}
+
+	record := result.Record()
+	state := &strava.BackfillState{
+		UpdatedAt: record.Time(),
 	}
 
-	if val := record.ValueByKey("max_watts"); val != nil {
-		if watts, ok := val.(float64); ok {
-			activity.MaxWatts = watts
+	if val := record.ValueByKey("before"); val != nil {
+		if before, ok := val.(int64); ok {
+			state.Before = time.Unix(before, 0)
 		}
 	}
 
-	if val := record.ValueByKey("tss"); val != nil {
-		if tss, ok := val.(float64); ok {
-			activity.TSS = tss
+	if val := record.ValueByKey("imported"); val != nil {
+		if imported, ok := val.(int64); ok {
+			state.Imported = int(imported)
 		}
 	}
 
-	if val := record.ValueByKey("np"); val != nil {
-		if np, ok := val.(float64); ok {
-			activity.NP = np
+	if val := record.ValueByKey("completed"); val != nil {
+		if completed, ok := val.(bool); ok {
+			state.Completed = completed
 		}
 	}
 
-	if val := record.ValueByKey("ftp"); val != nil {
-		if ftp, ok := val.(float64); ok {
-			activity.FTP = ftp
+	return state, nil
+}
+
+// SaveWebhookSubscription records the ID of the registered push
+// subscription. Zero records that no subscription is registered.
+func (c *InfluxDBClient) SaveWebhookSubscription(ctx context.Context, subscriptionID int64) error {
+	p := influxdb2.NewPointWithMeasurement("webhook_subscription").
+		AddField("subscription_id", subscriptionID).
+		SetTime(time.Now())
+
+	if err := c.writeAPI.WritePoint(ctx, p); err != nil {
+		return fmt.Errorf("failed to save webhook subscription: %w", err)
+	}
+
+	slog.Debug("Webhook subscription saved to InfluxDB", "subscription_id", subscriptionID)
+	return nil
+}
+
+// LoadWebhookSubscription returns the ID of the registered push
+// subscription, or 0 if none has been recorded
+func (c *InfluxDBClient) LoadWebhookSubscription(ctx context.Context) (int64, error) {
+	query := fmt.Sprintf(`
+		from(bucket: "%s")
+		|> range(start: 0)
+		|> filter(fn: (r) => r._measurement == "webhook_subscription" and r._field == "subscription_id")
+		|> last()
+	`, c.bucket)
+
+	result, err := c.queryAPI.Query(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("webhook subscription query failed: %w", err)
+	}
+	defer func() { _ = result.Close() }()
+
+	var subscriptionID int64
+	if result.Next() {
+		subscriptionID, _ = result.Record().Value().(int64)
+	}
+	if result.Err() != nil {
+		return 0, fmt.Errorf("webhook subscription query failed: %w", result.Err())
+	}
+
+	return subscriptionID, nil
+}
+
+// WriteLaps writes one laps point per lap, timestamped at the lap start
+func (c *InfluxDBClient) WriteLaps(ctx context.Context, laps []strava.LapData) error {
+	points := make([]*write.Point, 0, len(laps))
+	for _, lap := range laps {
+		points = append(points, influxdb2.NewPointWithMeasurement("laps").
+			AddTag("activity_id", fmt.Sprintf("%d", lap.ActivityID)).
+			AddTag("activity_type", lap.ActivityType).
+			AddTag("lap_index", strconv.Itoa(lap.LapIndex)).
+			AddField("name", lap.Name).
+			AddField("elapsed_time", lap.ElapsedTime).
+			AddField("moving_time", lap.MovingTime).
+			AddField("distance", lap.Distance).
+			AddField("average_watts", lap.AverageWatts).
+			AddField("np", lap.NP).
+			AddField("intensity_factor", lap.IF).
+			AddField("average_heartrate", lap.AverageHeartrate).
+			AddField("max_heartrate", lap.MaxHeartrate).
+			AddField("average_speed", lap.AverageSpeed).
+			AddField("average_cadence", lap.AverageCadence).
+			SetTime(lap.StartDate))
+	}
+	if len(points) == 0 {
+		return nil
+	}
+
+	if err := c.writeAPI.WritePoint(ctx, points...); err != nil {
+		return fmt.Errorf("failed to write laps: %w", err)
+	}
+
+	slog.Debug("Laps written to InfluxDB", "laps", len(laps))
+	return nil
+}
+
+// WriteSegmentEfforts writes one segment_efforts point per effort, tagged
+// with the segment so that efforts on the same climb can be compared
+func (c *InfluxDBClient) WriteSegmentEfforts(ctx context.Context, efforts []strava.SegmentEffortData) error {
+	points := make([]*write.Point, 0, len(efforts))
+	for _, effort := range efforts {
+		points = append(points, influxdb2.NewPointWithMeasurement("segment_efforts").
+			AddTag("activity_id", fmt.Sprintf("%d", effort.ActivityID)).
+			AddTag("segment_id", fmt.Sprintf("%d", effort.SegmentID)).
+			AddField("effort_id", effort.ID).
+			AddField("segment_name", effort.SegmentName).
+			AddField("elapsed_time", effort.ElapsedTime).
+			AddField("moving_time", effort.MovingTime).
+			AddField("distance", effort.Distance).
+			AddField("average_grade", effort.AverageGrade).
+			AddField("average_watts", effort.AverageWatts).
+			AddField("average_heartrate", effort.AverageHeartrate).
+			AddField("max_heartrate", effort.MaxHeartrate).
+			AddField("pr_rank", effort.PRRank).
+			AddField("kom_rank", effort.KOMRank).
+			SetTime(effort.StartDate))
+	}
+	if len(points) == 0 {
+		return nil
+	}
+
+	if err := c.writeAPI.WritePoint(ctx, points...); err != nil {
+		return fmt.Errorf("failed to write segment efforts: %w", err)
+	}
+
+	slog.Debug("Segment efforts written to InfluxDB", "efforts", len(efforts))
+	return nil
+}
+
+// WriteAthleteZones records the athlete's zones as synced from Strava
+func (c *InfluxDBClient) WriteAthleteZones(ctx context.Context, zones *strava.AthleteZones) error {
+	now := time.Now()
+	var points []*write.Point
+	for zoneType, ranges := range map[string]*strava.ZoneRanges{"heartrate": zones.HeartRate, "power": zones.Power} {
+		if ranges == nil {
+			continue
+		}
+		for i, zone := range ranges.Zones {
+			points = append(points, influxdb2.NewPointWithMeasurement("athlete_zones").
+				AddTag("zone_type", zoneType).
+				AddTag("zone", strconv.Itoa(i+1)).
+				AddField("min", zone.Min).
+				AddField("max", zone.Max).
+				SetTime(now))
 		}
 	}
+	if len(points) == 0 {
+		return nil
+	}
 
-	return activity, nil
+	if err := c.writeAPI.WritePoint(ctx, points...); err != nil {
+		return fmt.Errorf("failed to write athlete zones: %w", err)
+	}
+
+	slog.Debug("Athlete zones written to InfluxDB", "zones", len(points))
+	return nil
 }
 
-func (c *InfluxDBClient) GetWeeklyTrend() ([]strava.WeeklySummary, error) {
+// LoadAthleteZones returns the most recently synced zones, or nil if the
+// zones were never synced
+func (c *InfluxDBClient) LoadAthleteZones(ctx context.Context) (*strava.AthleteZones, error) {
 	query := fmt.Sprintf(`
 		from(bucket: "%s")
-		|> range(start: -8w)
-		|> filter(fn: (r) => r._measurement == "weekly_summary")
-		|> sort(columns: ["_time"], desc: false)
+		|> range(start: 0)
+		|> filter(fn: (r) => r._measurement == "athlete_zones")
+		|> last()
+		|> pivot(rowKey:["_time"], columnKey: ["_field"], valueColumn: "_value")
 	`, c.bucket)
 
-	result, err := c.queryAPI.Query(context.Background(), query)
+	result, err := c.queryAPI.Query(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("query failed: %w", err)
+		return nil, fmt.Errorf("athlete zones query failed: %w", err)
 	}
 	defer func() { _ = result.Close() }()
 
-	var summaries []strava.WeeklySummary
+	// Zones that no longer exist keep their last point, so only the zones
+	// of the latest sync of each type are used
+	byType := make(map[string]map[int]strava.ZoneRange)
+	syncedAt := make(map[string]time.Time)
 	for result.Next() {
 		record := result.Record()
-		summary := strava.WeeklySummary{
-			WeekStart: record.Time(),
+		zone, err := strconv.Atoi(stringValue(record, "zone"))
+		if err != nil {
+			continue
 		}
-
-		if val := record.ValueByKey("total_tss"); val != nil {
-			if tss, ok := val.(float64); ok {
-				summary.TotalTSS = tss
-			}
+		zoneType := stringValue(record, "zone_type")
+		if record.Time().Before(syncedAt[zoneType]) {
+			continue
 		}
-
-		if val := record.ValueByKey("total_moving_time"); val != nil {
-			if movingTime, ok := val.(int); ok {
-				summary.TotalMovingTime = movingTime
-			}
+		if record.Time().After(syncedAt[zoneType]) {
+			syncedAt[zoneType] = record.Time()
+			byType[zoneType] = make(map[int]strava.ZoneRange)
 		}
+		byType[zoneType][zone] = strava.ZoneRange{
+			Min: int(floatValue(record, "min")),
+			Max: int(floatValue(record, "max")),
+		}
+	}
+	if result.Err() != nil {
+		return nil, fmt.Errorf("athlete zones query failed: %w", result.Err())
+	}
+	if len(byType) == 0 {
+		return nil, nil
+	}
 
-		if val := record.ValueByKey("total_distance"); val != nil {
-			if distance, ok := val.(float64); ok {
-				summary.TotalDistance = distance
-			}
+	toRanges := func(zones map[int]strava.ZoneRange) *strava.ZoneRanges {
+		if len(zones) == 0 {
+			return nil
 		}
+		ranges := &strava.ZoneRanges{}
+		for i := 1; i <= len(zones); i++ {
+			ranges.Zones = append(ranges.Zones, zones[i])
+		}
+		return ranges
+	}
+	return &strava.AthleteZones{
+		HeartRate: toRanges(byType["heartrate"]),
+		Power:     toRanges(byType["power"]),
+	}, nil
+}
 
-		if val := record.ValueByKey("total_elevation_gain"); val != nil {
-			if elevation, ok := val.(float64); ok {
-				summary.TotalElevationGain = elevation
-			}
+// WriteGearStats records the current cumulative usage of each piece of gear
+func (c *InfluxDBClient) WriteGearStats(ctx context.Context, stats []strava.GearStats) error {
+	now := time.Now()
+	points := make([]*write.Point, 0, len(stats))
+	for _, gear := range stats {
+		points = append(points, influxdb2.NewPointWithMeasurement("gear").
+			AddTag("gear_id", gear.GearID).
+			AddTag("kind", gear.Kind).
+			AddField("name", gear.Name).
+			AddField("distance", gear.Distance).
+			AddField("moving_time", gear.MovingTime).
+			AddField("activity_count", gear.ActivityCount).
+			AddField("retired", gear.Retired).
+			SetTime(now))
+	}
+	if len(points) == 0 {
+		return nil
+	}
+
+	if err := c.writeAPI.WritePoint(ctx, points...); err != nil {
+		return fmt.Errorf("failed to write gear stats: %w", err)
+	}
+
+	slog.Debug("Gear stats written to InfluxDB", "gear", len(stats))
+	return nil
+}
+
+// GetGearStats returns the most recent usage of each piece of gear
+func (c *InfluxDBClient) GetGearStats(ctx context.Context) ([]strava.GearStats, error) {
+	query := fmt.Sprintf(`
+		from(bucket: "%s")
+		|> range(start: 0)
+		|> filter(fn: (r) => r._measurement == "gear")
+		|> last()
+		|> pivot(rowKey:["_time"], columnKey: ["_field"], valueColumn: "_value")
+	`, c.bucket)
+
+	result, err := c.queryAPI.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("gear query failed: %w", err)
+	}
+	defer func() { _ = result.Close() }()
+
+	var stats []strava.GearStats
+	for result.Next() {
+		record := result.Record()
+		gear := strava.GearStats{
+			GearID:        stringValue(record, "gear_id"),
+			Name:          stringValue(record, "name"),
+			Kind:          stringValue(record, "kind"),
+			Distance:      floatValue(record, "distance"),
+			MovingTime:    int(floatValue(record, "moving_time")),
+			ActivityCount: int(floatValue(record, "activity_count")),
+		}
+		if retired, ok := record.ValueByKey("retired").(bool); ok {
+			gear.Retired = retired
 		}
+		stats = append(stats, gear)
+	}
+	if result.Err() != nil {
+		return nil, fmt.Errorf("gear query failed: %w", result.Err())
+	}
+
+	return stats, nil
+}
 
-		summaries = append(summaries, summary)
+// WriteMaintenanceStatus records the state of each configured maintenance item
+func (c *InfluxDBClient) WriteMaintenanceStatus(ctx context.Context, statuses []strava.MaintenanceStatus) error {
+	now := time.Now()
+	points := make([]*write.Point, 0, len(statuses))
+	for _, status := range statuses {
+		points = append(points, influxdb2.NewPointWithMeasurement("gear_maintenance").
+			AddTag("gear_id", status.GearID).
+			AddTag("item", status.Item).
+			AddField("gear_name", status.GearName).
+			AddField("interval_km", status.IntervalKm).
+			AddField("distance_km", status.DistanceKm).
+			AddField("intervals", status.Intervals).
+			AddField("due", status.Due).
+			SetTime(now))
+	}
+	if len(points) == 0 {
+		return nil
 	}
 
-	return summaries, nil
+	if err := c.writeAPI.WritePoint(ctx, points...); err != nil {
+		return fmt.Errorf("failed to write maintenance status: %w", err)
+	}
+
+	slog.Debug("Maintenance status written to InfluxDB", "items", len(statuses))
+	return nil
+}
+
+// GetMaintenanceStatus returns the most recently recorded state of each
+// maintenance item
+func (c *InfluxDBClient) GetMaintenanceStatus(ctx context.Context) ([]strava.MaintenanceStatus, error) {
+	query := fmt.Sprintf(`
+		from(bucket: "%s")
+		|> range(start: 0)
+		|> filter(fn: (r) => r._measurement == "gear_maintenance")
+		|> last()
+		|> pivot(rowKey:["_time"], columnKey: ["_field"], valueColumn: "_value")
+	`, c.bucket)
+
+	result, err := c.queryAPI.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("maintenance query failed: %w", err)
+	}
+	defer func() { _ = result.Close() }()
+
+	var statuses []strava.MaintenanceStatus
+	for result.Next() {
+		record := result.Record()
+		status := strava.MaintenanceStatus{
+			GearID:     stringValue(record, "gear_id"),
+			GearName:   stringValue(record, "gear_name"),
+			Item:       stringValue(record, "item"),
+			IntervalKm: floatValue(record, "interval_km"),
+			DistanceKm: floatValue(record, "distance_km"),
+			Intervals:  int(floatValue(record, "intervals")),
+		}
+		if due, ok := record.ValueByKey("due").(bool); ok {
+			status.Due = due
+		}
+		statuses = append(statuses, status)
+	}
+	if result.Err() != nil {
+		return nil, fmt.Errorf("maintenance query failed: %w", result.Err())
+	}
+
+	return statuses, nil
 }
 
 // Token management methods
-func (c *InfluxDBClient) SaveToken(token *strava.TokenData) error {
+func (c *InfluxDBClient) SaveToken(ctx context.Context, token *strava.TokenData) error {
 	p := influxdb2.NewPointWithMeasurement("tokens").
 		AddTag("token_type", "strava_access").
 		AddField("access_token", token.AccessToken).
@@ -317,25 +1620,18 @@ func (c *InfluxDBClient) SaveToken(token *strava.TokenData) error {
 		AddField("athlete_id", token.AthleteID).
 		SetTime(time.Now())
 
-	// Check for write errors
-	errorsCh := c.writeAPI.Errors()
-	go func() {
-		for err := range errorsCh {
-			slog.Error("InfluxDB token write error", "error", err)
-		}
-	}()
-
-	c.writeAPI.WritePoint(p)
-	c.writeAPI.Flush()
+	if err := c.writeAPI.WritePoint(ctx, p); err != nil {
+		return fmt.Errorf("failed to write token: %w", err)
+	}
 
 	slog.Info("Token saved to InfluxDB", "athlete_id", token.AthleteID)
 	return nil
 }
 
-func (c *InfluxDBClient) LoadToken() (*strava.TokenData, error) {
+func (c *InfluxDBClient) LoadToken(ctx context.Context) (*strava.TokenData, error) {
 	query := fmt.Sprintf(`
 		from(bucket: "%s")
-		|> range(start: -7d)
+		|> range(start: 0)
 		|> filter(fn: (r) => r._measurement == "tokens")
 		|> filter(fn: (r) => r.token_type == "strava_access")
 		|> sort(columns: ["_time"], desc: true)
@@ -344,7 +1640,7 @@ func (c *InfluxDBClient) LoadToken() (*strava.TokenData, error) {
 	`, c.bucket)
 
 	slog.Debug("Executing token query", "bucket", c.bucket)
-	result, err := c.queryAPI.Query(context.Background(), query)
+	result, err := c.queryAPI.Query(ctx, query)
 	if err != nil {
 		slog.Error("Token query failed", "error", err)
 		return nil, fmt.Errorf("token query failed: %w", err)
@@ -378,26 +1674,39 @@ func (c *InfluxDBClient) LoadToken() (*strava.TokenData, error) {
 	}
 
 	// Parse expires_at
+	// Integer fields are returned as int64, older points may hold float64
 	if val := record.ValueByKey("expires_at"); val != nil {
-		if expiresAt, ok := val.(float64); ok {
+		switch expiresAt := val.(type) {
+		case int64:
+			token.ExpiresAt = time.Unix(expiresAt, 0)
+		case float64:
 			token.ExpiresAt = time.Unix(int64(expiresAt), 0)
-			slog.Debug("Parsed expires_at", "expires_at", token.ExpiresAt)
 		}
+		slog.Debug("Parsed expires_at", "expires_at", token.ExpiresAt)
 	}
 
 	// Parse athlete_id
 	if val := record.ValueByKey("athlete_id"); val != nil {
-		if athleteID, ok := val.(float64); ok {
+		switch athleteID := val.(type) {
+		case int64:
+			token.AthleteID = athleteID
+		case float64:
 			token.AthleteID = int64(athleteID)
-			slog.Debug("Parsed athlete_id", "athlete_id", token.AthleteID)
 		}
+		slog.Debug("Parsed athlete_id", "athlete_id", token.AthleteID)
+	}
+
+	// ClearToken writes an invalidated token with empty credentials
+	if token.AccessToken == "" {
+		slog.Debug("Latest token in InfluxDB has been invalidated")
+		return nil, nil
 	}
 
 	slog.Info("Token loaded from InfluxDB", "athlete_id", token.AthleteID)
 	return token, nil
 }
 
-func (c *InfluxDBClient) ClearToken() error {
+func (c *InfluxDBClient) ClearToken(ctx context.Context) error {
 	// InfluxDBでは過去のデータを直接削除するのは複雑なので、
 	// 代わりに無効化フラグを立てるアプローチを取ります
 	p := influxdb2.NewPointWithMeasurement("tokens").
@@ -409,8 +1718,9 @@ func (c *InfluxDBClient) ClearToken() error {
 		AddField("invalidated", true).
 		SetTime(time.Now())
 
-	c.writeAPI.WritePoint(p)
-	c.writeAPI.Flush()
+	if err := c.writeAPI.WritePoint(ctx, p); err != nil {
+		return fmt.Errorf("failed to invalidate token: %w", err)
+	}
 
 	slog.Info("Token invalidated in InfluxDB")
 	return nil
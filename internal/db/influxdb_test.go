@@ -1,11 +1,19 @@
 package db
 
 import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"stravaDataImporter/internal/config"
 	"stravaDataImporter/internal/strava"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
 )
 
 func TestNewInfluxDBClient(t *testing.T) {
@@ -18,7 +26,7 @@ func TestNewInfluxDBClient(t *testing.T) {
 
 	// This test will fail if InfluxDB is not running
 	// In a real test environment, you would use a test container
-	_, err := NewInfluxDBClient(cfg)
+	_, err := NewInfluxDBClient(context.Background(), cfg)
 	if err != nil {
 		t.Logf("InfluxDB connection failed (expected if not running): %v", err)
 		// Don't fail the test if InfluxDB is not available
@@ -54,3 +62,73 @@ func TestWriteActivity(t *testing.T) {
 	// This would be a real test if InfluxDB was available
 	t.Logf("Activity test data: %+v", activity)
 }
+
+// activitySeriesCSV answers the deleted flag query with two series of
+// activity 1002, one of them from before a type change
+const activitySeriesCSV = `#datatype,string,long,dateTime:RFC3339,double,string,string,string,string
+#group,false,false,false,false,true,true,true,true
+#default,_result,,,,,,,
+,result,table,_time,_value,_field,_measurement,activity_id,activity_type
+,,0,2024-06-02T21:30:00Z,5020,distance,activities,1002,Run
+
+#datatype,string,long,dateTime:RFC3339,double,string,string,string,string,string
+#group,false,false,false,false,true,true,true,true,true
+#default,_result,,,,,,,,
+,result,table,_time,_value,_field,_measurement,activity_id,activity_type,activity_name
+,,1,2024-06-02T21:30:00Z,5020,distance,activities,1002,TrailRun,Easy Run
+
+`
+
+func TestMarkActivityDeletedFlagsEverySeries(t *testing.T) {
+	var mu sync.Mutex
+	var written []string
+	deletes := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/api/v2/query":
+			w.Header().Set("Content-Type", "text/csv")
+			_, _ = io.WriteString(w, activitySeriesCSV)
+		case "/api/v2/write":
+			body, _ := io.ReadAll(r.Body)
+			written = append(written, strings.Split(strings.TrimSpace(string(body)), "\n")...)
+			w.WriteHeader(http.StatusNoContent)
+		case "/api/v2/delete":
+			deletes++
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := influxdb2.NewClient(server.URL, "test-token")
+	defer client.Close()
+	c := &InfluxDBClient{
+		client:   client,
+		writeAPI: client.WriteAPIBlocking("test-org", "test-bucket"),
+		queryAPI: client.QueryAPI("test-org"),
+		bucket:   "test-bucket",
+		org:      "test-org",
+	}
+
+	if err := c.MarkActivityDeleted(context.Background(), 1002); err != nil {
+		t.Fatalf("MarkActivityDeleted() error = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(written) != 2 {
+		t.Fatalf("written = %q, want a deleted flag for each series", written)
+	}
+	if !strings.Contains(written[0], "activity_type=Run") || !strings.Contains(written[0], "deleted=true") {
+		t.Errorf("first flag = %q, want the Run series flagged", written[0])
+	}
+	if !strings.Contains(written[1], `activity_name=Easy\ Run`) || !strings.Contains(written[1], "activity_type=TrailRun") {
+		t.Errorf("second flag = %q, want the TrailRun series with its name tag", written[1])
+	}
+	if deletes != 1 {
+		t.Errorf("deletes = %d, want the power curve removed once", deletes)
+	}
+}
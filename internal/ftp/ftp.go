@@ -2,10 +2,13 @@ package ftp
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -14,19 +17,31 @@ type FTPRecord struct {
 	FTP  float64
 }
 
+// FTPManager holds the dated FTP history from the FTP CSV file. Dates are
+// days in the athlete location. It is safe for concurrent use.
 type FTPManager struct {
 	filePath string
-	records  []FTPRecord
+	location *time.Location
+
+	mu      sync.RWMutex
+	records []FTPRecord
 }
 
-func NewFTPManager(filePath string) *FTPManager {
+// NewFTPManager creates a manager whose file dates are days in loc
+func NewFTPManager(filePath string, loc *time.Location) *FTPManager {
 	return &FTPManager{
 		filePath: filePath,
+		location: loc,
 		records:  make([]FTPRecord, 0),
 	}
 }
 
 func (f *FTPManager) LoadFTPData() error {
+	// Appends write the file under the same lock, so a reload never drops
+	// a record appended while the file is read
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	file, err := os.Open(f.filePath)
 	if err != nil {
 		return fmt.Errorf("failed to open FTP file: %w", err)
@@ -39,8 +54,7 @@ func (f *FTPManager) LoadFTPData() error {
 		return fmt.Errorf("failed to read CSV: %w", err)
 	}
 
-	f.records = make([]FTPRecord, 0, len(records))
-
+	loaded := make([]FTPRecord, 0, len(records))
 	for i, record := range records {
 		if i == 0 {
 			// Skip header row if it exists
@@ -53,7 +67,7 @@ func (f *FTPManager) LoadFTPData() error {
 			continue
 		}
 
-		date, err := time.Parse("2006-01-02", record[0])
+		date, err := time.ParseInLocation("2006-01-02", record[0], f.location)
 		if err != nil {
 			slog.Warn("Failed to parse date", "date", record[0], "error", err)
 			continue
@@ -65,17 +79,21 @@ func (f *FTPManager) LoadFTPData() error {
 			continue
 		}
 
-		f.records = append(f.records, FTPRecord{
+		loaded = append(loaded, FTPRecord{
 			Date: date,
 			FTP:  ftp,
 		})
 	}
 
-	slog.Info("Loaded FTP data", "records", len(f.records))
+	f.records = loaded
+	slog.Info("Loaded FTP data", "records", len(loaded))
 	return nil
 }
 
 func (f *FTPManager) GetFTPForDate(date time.Time) float64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
 	if len(f.records) == 0 {
 		return 0
 	}
@@ -96,10 +114,52 @@ func (f *FTPManager) GetFTPForDate(date time.Time) float64 {
 	return latestFTP
 }
 
+// AppendRecord adds a dated FTP to the end of the CSV file, creating the
+// file with a header when it does not exist, and to the loaded history
+func (f *FTPManager) AppendRecord(date time.Time, ftp float64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	content, err := os.ReadFile(f.filePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read FTP file: %w", err)
+	}
+
+	var line string
+	switch {
+	case len(content) == 0:
+		line = "date,ftp\n"
+	case content[len(content)-1] != '\n':
+		line = "\n"
+	}
+	day := date.In(f.location).Format("2006-01-02")
+	line += day + "," + strconv.FormatFloat(ftp, 'f', -1, 64) + "\n"
+
+	file, err := os.OpenFile(f.filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open FTP file: %w", err)
+	}
+	if _, err := file.WriteString(line); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to append FTP record: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to append FTP record: %w", err)
+	}
+
+	recordDate, _ := time.ParseInLocation("2006-01-02", day, f.location)
+	f.records = append(f.records, FTPRecord{Date: recordDate, FTP: ftp})
+	slog.Info("Appended FTP record", "date", day, "ftp", ftp)
+	return nil
+}
+
 func (f *FTPManager) GetCurrentFTP() float64 {
 	return f.GetFTPForDate(time.Now())
 }
 
 func (f *FTPManager) GetAllRecords() []FTPRecord {
-	return f.records
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return append([]FTPRecord(nil), f.records...)
 }
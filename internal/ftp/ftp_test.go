@@ -3,6 +3,8 @@ package ftp
 import (
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -23,7 +25,7 @@ func TestFTPManager(t *testing.T) {
 		t.Fatalf("Failed to create test CSV file: %v", err)
 	}
 
-	manager := NewFTPManager(csvFile)
+	manager := NewFTPManager(csvFile, time.UTC)
 
 	// Test LoadFTPData
 	err = manager.LoadFTPData()
@@ -67,7 +69,7 @@ func TestFTPManagerEmptyFile(t *testing.T) {
 		t.Fatalf("Failed to create empty CSV file: %v", err)
 	}
 
-	manager := NewFTPManager(csvFile)
+	manager := NewFTPManager(csvFile, time.UTC)
 	err = manager.LoadFTPData()
 	if err != nil {
 		t.Fatalf("LoadFTPData() error = %v", err)
@@ -80,9 +82,118 @@ func TestFTPManagerEmptyFile(t *testing.T) {
 }
 
 func TestFTPManagerInvalidFile(t *testing.T) {
-	manager := NewFTPManager("nonexistent.csv")
+	manager := NewFTPManager("nonexistent.csv", time.UTC)
 	err := manager.LoadFTPData()
 	if err == nil {
 		t.Error("Expected error for nonexistent file, got nil")
 	}
 }
+
+func TestFTPManagerAppendRecord(t *testing.T) {
+	csvFile := filepath.Join(t.TempDir(), "ftp.csv")
+	if err := os.WriteFile(csvFile, []byte("date,ftp\n2024-01-01,170"), 0644); err != nil {
+		t.Fatalf("Failed to create test CSV file: %v", err)
+	}
+
+	manager := NewFTPManager(csvFile, time.UTC)
+	if err := manager.LoadFTPData(); err != nil {
+		t.Fatalf("LoadFTPData() error = %v", err)
+	}
+	if err := manager.AppendRecord(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), 205); err != nil {
+		t.Fatalf("AppendRecord() error = %v", err)
+	}
+	if ftp := manager.GetFTPForDate(time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)); ftp != 205 {
+		t.Errorf("GetFTPForDate() = %v after append, want 205", ftp)
+	}
+
+	content, _ := os.ReadFile(csvFile)
+	if string(content) != "date,ftp\n2024-01-01,170\n2024-06-01,205\n" {
+		t.Errorf("FTP file = %q", content)
+	}
+
+	// The appended record survives a reload
+	reloaded := NewFTPManager(csvFile, time.UTC)
+	if err := reloaded.LoadFTPData(); err != nil || len(reloaded.GetAllRecords()) != 2 {
+		t.Errorf("reloaded records = %v, error = %v, want 2 records", reloaded.GetAllRecords(), err)
+	}
+
+	// A missing file is created with a header
+	created := NewFTPManager(filepath.Join(t.TempDir(), "new.csv"), time.UTC)
+	if err := created.AppendRecord(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), 205.5); err != nil {
+		t.Fatalf("AppendRecord() error = %v", err)
+	}
+	if err := created.LoadFTPData(); err != nil || created.GetFTPForDate(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)) != 205.5 {
+		t.Errorf("created records = %v, error = %v", created.GetAllRecords(), err)
+	}
+}
+
+func TestFTPManagerConcurrentUse(t *testing.T) {
+	csvFile := filepath.Join(t.TempDir(), "ftp.csv")
+	if err := os.WriteFile(csvFile, []byte("date,ftp\n2024-01-01,170\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test CSV file: %v", err)
+	}
+
+	manager := NewFTPManager(csvFile, time.UTC)
+	if err := manager.LoadFTPData(); err != nil {
+		t.Fatalf("LoadFTPData() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			if err := manager.AppendRecord(time.Date(2024, 2, i+1, 0, 0, 0, 0, time.UTC), float64(200+i)); err != nil {
+				t.Errorf("AppendRecord() error = %v", err)
+			}
+			if err := manager.LoadFTPData(); err != nil {
+				t.Errorf("LoadFTPData() error = %v", err)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			if ftp := manager.GetFTPForDate(time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)); ftp != 170 {
+				t.Errorf("GetFTPForDate() = %v, want 170", ftp)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if records := manager.GetAllRecords(); len(records) != 11 {
+		t.Errorf("records = %d, want all 11 after concurrent appends", len(records))
+	}
+}
+
+func TestFTPManagerLocation(t *testing.T) {
+	csvFile := filepath.Join(t.TempDir(), "ftp.csv")
+	if err := os.WriteFile(csvFile, []byte("date,ftp\n2024-01-01,250\n2024-06-01,300\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test CSV file: %v", err)
+	}
+
+	tokyo := time.FixedZone("Asia/Tokyo", 9*60*60)
+	manager := NewFTPManager(csvFile, tokyo)
+	if err := manager.LoadFTPData(); err != nil {
+		t.Fatalf("LoadFTPData() error = %v", err)
+	}
+
+	// The day starts at midnight in Tokyo, not at midnight UTC
+	morning := time.Date(2024, 6, 1, 7, 0, 0, 0, tokyo)
+	if ftp := manager.GetFTPForDate(morning); ftp != 300 {
+		t.Errorf("GetFTPForDate(%v) = %v, want 300", morning, ftp)
+	}
+	if ftp := manager.GetFTPForDate(morning.Add(-8 * time.Hour)); ftp != 250 {
+		t.Errorf("GetFTPForDate() the evening before = %v, want 250", ftp)
+	}
+
+	// An appended day is written as the day in Tokyo
+	if err := manager.AppendRecord(time.Date(2024, 7, 1, 0, 0, 0, 0, tokyo), 310); err != nil {
+		t.Fatalf("AppendRecord() error = %v", err)
+	}
+	content, _ := os.ReadFile(csvFile)
+	if !strings.HasSuffix(string(content), "2024-07-01,310\n") {
+		t.Errorf("FTP file = %q, want the Tokyo day appended", content)
+	}
+	if ftp := manager.GetFTPForDate(time.Date(2024, 7, 1, 1, 0, 0, 0, tokyo)); ftp != 310 {
+		t.Errorf("GetFTPForDate() after append = %v, want 310", ftp)
+	}
+}
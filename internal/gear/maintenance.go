@@ -0,0 +1,167 @@
+package gear
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"stravaDataImporter/internal/strava"
+)
+
+// MaintenanceRule is a maintenance interval such as "chain every 3000 km".
+// Gear is matched by Strava gear id or by name.
+type MaintenanceRule struct {
+	Gear        string
+	Item        string
+	IntervalKm  float64
+	LastService time.Time // zero when the whole gear distance counts
+}
+
+// MaintenanceManager holds the maintenance rules from the maintenance CSV
+// file. Service dates are days in the athlete location. It is safe for
+// concurrent use.
+type MaintenanceManager struct {
+	filePath string
+	location *time.Location
+
+	mu    sync.RWMutex
+	rules []MaintenanceRule
+}
+
+// NewMaintenanceManager creates a manager whose service dates are days in loc
+func NewMaintenanceManager(filePath string, loc *time.Location) *MaintenanceManager {
+	return &MaintenanceManager{
+		filePath: filePath,
+		location: loc,
+		rules:    make([]MaintenanceRule, 0),
+	}
+}
+
+// LoadMaintenanceData reads the rules from the CSV file. A missing file
+// means no reminders are configured.
+func (m *MaintenanceManager) LoadMaintenanceData() error {
+	file, err := os.Open(m.filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		slog.Info("No maintenance file found, gear reminders disabled", "path", m.filePath)
+		m.mu.Lock()
+		m.rules = make([]MaintenanceRule, 0)
+		m.mu.Unlock()
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open maintenance file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return fmt.Errorf("failed to read CSV: %w", err)
+	}
+
+	loaded := make([]MaintenanceRule, 0, len(records))
+
+	for i, record := range records {
+		if i == 0 && len(record) > 0 && record[0] == "gear" {
+			// Skip header row
+			continue
+		}
+
+		if len(record) < 3 {
+			continue
+		}
+
+		interval, err := strconv.ParseFloat(strings.TrimSpace(record[2]), 64)
+		if err != nil || interval <= 0 {
+			slog.Warn("Failed to parse maintenance interval", "interval_km", record[2], "error", err)
+			continue
+		}
+
+		rule := MaintenanceRule{
+			Gear:       strings.TrimSpace(record[0]),
+			Item:       strings.TrimSpace(record[1]),
+			IntervalKm: interval,
+		}
+
+		if len(record) >= 4 && strings.TrimSpace(record[3]) != "" {
+			lastService, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(record[3]), m.location)
+			if err != nil {
+				slog.Warn("Failed to parse last service date", "last_service", record[3], "error", err)
+				continue
+			}
+			rule.LastService = lastService
+		}
+
+		loaded = append(loaded, rule)
+	}
+
+	m.mu.Lock()
+	m.rules = loaded
+	m.mu.Unlock()
+
+	slog.Info("Loaded maintenance rules", "rules", len(loaded))
+	return nil
+}
+
+func (m *MaintenanceManager) GetAllRules() []MaintenanceRule {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.rules
+}
+
+// Evaluate computes the distance and the number of whole intervals covered
+// since the last service for every rule that matches one of the given gear.
+// Distance since a service date is summed from the imported activities;
+// without a date the total gear distance reported by Strava is used.
+func (m *MaintenanceManager) Evaluate(stats []strava.GearStats, activities []strava.ActivityData) []strava.MaintenanceStatus {
+	activities = strava.UniqueActivities(activities)
+
+	var statuses []strava.MaintenanceStatus
+	for _, rule := range m.GetAllRules() {
+		gear, ok := findGear(stats, rule.Gear)
+		if !ok {
+			slog.Debug("No gear matches maintenance rule", "gear", rule.Gear, "item", rule.Item)
+			continue
+		}
+
+		distance := gear.Distance
+		if !rule.LastService.IsZero() {
+			distance = 0
+			for _, activity := range activities {
+				if activity.GearID == gear.GearID && !activity.StartDate.Before(rule.LastService) {
+					distance += activity.Distance
+				}
+			}
+		}
+
+		intervals := int(math.Floor(distance / 1000 / rule.IntervalKm))
+		statuses = append(statuses, strava.MaintenanceStatus{
+			GearID:     gear.GearID,
+			GearName:   gear.Name,
+			Item:       rule.Item,
+			IntervalKm: rule.IntervalKm,
+			DistanceKm: distance / 1000,
+			Intervals:  intervals,
+			Due:        !gear.Retired && intervals >= 1,
+		})
+	}
+	return statuses
+}
+
+func findGear(stats []strava.GearStats, key string) (strava.GearStats, bool) {
+	for _, gear := range stats {
+		if gear.GearID == key || strings.EqualFold(gear.Name, key) {
+			return gear, true
+		}
+	}
+	return strava.GearStats{}, false
+}
@@ -0,0 +1,119 @@
+package gear
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"stravaDataImporter/internal/strava"
+)
+
+func TestMaintenanceManager(t *testing.T) {
+	csvFile := filepath.Join(t.TempDir(), "maintenance.csv")
+
+	csvContent := `gear,item,interval_km,last_service
+b1111,chain,3000,2024-06-01
+Pegasus,shoes,700,
+b1111,cassette,abc,
+b1111,tires,4000,not-a-date`
+
+	if err := os.WriteFile(csvFile, []byte(csvContent), 0644); err != nil {
+		t.Fatalf("Failed to create test CSV file: %v", err)
+	}
+
+	manager := NewMaintenanceManager(csvFile, time.UTC)
+	if err := manager.LoadMaintenanceData(); err != nil {
+		t.Fatalf("LoadMaintenanceData() error = %v", err)
+	}
+
+	rules := manager.GetAllRules()
+	if len(rules) != 2 {
+		t.Fatalf("Expected 2 valid rules, got %d", len(rules))
+	}
+	if !rules[0].LastService.Equal(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("LastService = %v, want 2024-06-01", rules[0].LastService)
+	}
+	if !rules[1].LastService.IsZero() {
+		t.Errorf("LastService = %v, want zero", rules[1].LastService)
+	}
+}
+
+func TestMaintenanceManagerMissingFile(t *testing.T) {
+	manager := NewMaintenanceManager(filepath.Join(t.TempDir(), "missing.csv"), time.UTC)
+	if err := manager.LoadMaintenanceData(); err != nil {
+		t.Fatalf("LoadMaintenanceData() error = %v, want nil for a missing file", err)
+	}
+	if len(manager.GetAllRules()) != 0 {
+		t.Error("Expected no rules for a missing file")
+	}
+}
+
+func TestMaintenanceManagerLocation(t *testing.T) {
+	csvFile := filepath.Join(t.TempDir(), "maintenance.csv")
+	if err := os.WriteFile(csvFile, []byte("b1111,chain,3000,2024-06-01\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test CSV file: %v", err)
+	}
+
+	tokyo := time.FixedZone("JST", 9*60*60)
+	manager := NewMaintenanceManager(csvFile, tokyo)
+	if err := manager.LoadMaintenanceData(); err != nil {
+		t.Fatalf("LoadMaintenanceData() error = %v", err)
+	}
+
+	rules := manager.GetAllRules()
+	if len(rules) != 1 || !rules[0].LastService.Equal(time.Date(2024, 6, 1, 0, 0, 0, 0, tokyo)) {
+		t.Fatalf("rules = %+v, want the service on 2024-06-01 in Tokyo", rules)
+	}
+
+	// A ride early on the service day in Tokyo is still on the previous UTC day
+	stats := []strava.GearStats{{GearID: "b1111", Name: "Tarmac", Distance: 10000000}}
+	activities := []strava.ActivityData{{ID: 1, GearID: "b1111", Distance: 3000000, StartDate: time.Date(2024, 5, 31, 22, 0, 0, 0, time.UTC)}}
+	statuses := manager.Evaluate(stats, activities)
+	if len(statuses) != 1 || statuses[0].DistanceKm != 3000 || !statuses[0].Due {
+		t.Errorf("statuses = %+v, want the ride counted since the service", statuses)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	manager := &MaintenanceManager{rules: []MaintenanceRule{
+		{Gear: "b1111", Item: "chain", IntervalKm: 3000, LastService: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{Gear: "pegasus", Item: "shoes", IntervalKm: 700},
+		{Gear: "b9999", Item: "chain", IntervalKm: 3000},
+		{Gear: "Old Bike", Item: "chain", IntervalKm: 1},
+	}}
+
+	stats := []strava.GearStats{
+		{GearID: "b1111", Name: "Tarmac", Distance: 9000000},
+		{GearID: "g2222", Name: "Pegasus", Distance: 701000},
+		{GearID: "b3333", Name: "Old Bike", Distance: 5000000, Retired: true},
+	}
+	activities := []strava.ActivityData{
+		{GearID: "b1111", Distance: 2000000, StartDate: time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC)},
+		{ID: 2, GearID: "b1111", Distance: 1500000, StartDate: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{ID: 2, GearID: "b1111", Distance: 1500000, StartDate: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)}, // stale series
+		{GearID: "b1111", Distance: 1000000, StartDate: time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC), Deleted: true},
+		{GearID: "g2222", Distance: 5000, StartDate: time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC)},
+	}
+
+	statuses := manager.Evaluate(stats, activities)
+	if len(statuses) != 3 {
+		t.Fatalf("Expected 3 statuses, got %d", len(statuses))
+	}
+
+	if statuses[0].DistanceKm != 1500 || statuses[0].Intervals != 0 || statuses[0].Due {
+		t.Errorf("chain status = %+v, want 1500 km since service and not due", statuses[0])
+	}
+	if statuses[1].GearID != "g2222" || statuses[1].DistanceKm != 701 || statuses[1].Intervals != 1 || !statuses[1].Due {
+		t.Errorf("shoes status = %+v, want 701 km, one interval and due", statuses[1])
+	}
+	if statuses[2].Due {
+		t.Errorf("retired gear status = %+v, want not due", statuses[2])
+	}
+
+	// Every further interval is counted
+	stats[1].Distance = 1450000
+	if statuses := manager.Evaluate(stats, activities); statuses[1].Intervals != 2 || !statuses[1].Due {
+		t.Errorf("shoes status = %+v, want two intervals at 1450 km", statuses[1])
+	}
+}
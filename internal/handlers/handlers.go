@@ -1,55 +1,142 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
 
 	"stravaDataImporter/internal/auth"
 	"stravaDataImporter/internal/config"
 	"stravaDataImporter/internal/db"
-	"stravaDataImporter/internal/ftp"
 	"stravaDataImporter/internal/strava"
 
 	"github.com/gin-gonic/gin"
 )
 
-type Handler struct {
-	config       *config.Config
-	stravaClient *strava.Client
-	tokenStore   *auth.TokenStore
-	stateStore   *auth.StateStore
-	ftpManager   *ftp.FTPManager
-	influxClient *db.InfluxDBClient
+// WebhookProcessor applies Strava push subscription events to the stored data
+type WebhookProcessor interface {
+	ImportActivity(ctx context.Context, activityID int64, created bool) error
+	DeleteActivity(ctx context.Context, activityID int64) error
+	RevokeAuthorization(ctx context.Context, athleteID int64) error
 }
 
-func NewHandler(cfg *config.Config, influxClient *db.InfluxDBClient) *Handler {
-	var tokenStore *auth.TokenStore
-	var ftpManager *ftp.FTPManager
+// WebhookSubscriptionStore keeps the ID of the registered push subscription
+type WebhookSubscriptionStore interface {
+	SaveWebhookSubscription(ctx context.Context, subscriptionID int64) error
+	LoadWebhookSubscription(ctx context.Context) (int64, error)
+}
 
-	if influxClient != nil {
-		tokenStore = auth.NewTokenStore(influxClient)
-		ftpManager = ftp.NewFTPManager(cfg.FTPFilePath)
+// Reconciler compares stored activities with Strava and repairs differences
+type Reconciler interface {
+	Reconcile(ctx context.Context, since time.Time) (*strava.ReconcileReport, error)
+}
+
+// FTPSuggester manages the FTP values proposed from new power evidence
+type FTPSuggester interface {
+	PendingFTPSuggestions(ctx context.Context) ([]strava.FTPSuggestion, error)
+	AcceptFTPSuggestion(ctx context.Context, id string) (*strava.FTPSuggestion, error)
+	DismissFTPSuggestion(ctx context.Context, id string) (*strava.FTPSuggestion, error)
+}
+
+type Handler struct {
+	config           *config.Config
+	stravaClient     *strava.Client
+	tokenStore       *auth.TokenStore
+	stateStore       *auth.StateStore
+	sessionStore     *auth.SessionStore
+	influxClient     *db.InfluxDBClient
+	webhookProcessor WebhookProcessor
+	subscriptions    WebhookSubscriptionStore
+	reconciler       Reconciler
+	ftpSuggester     FTPSuggester
+}
+
+func NewHandler(cfg *config.Config, influxClient *db.InfluxDBClient, tokenStore *auth.TokenStore) *Handler {
+	stravaClient := strava.NewClient(cfg)
+	if tokenStore != nil {
+		stravaClient.SetTokenSource(tokenStore)
 	}
 
-	return &Handler{
+	h := &Handler{
 		config:       cfg,
-		stravaClient: strava.NewClient(cfg),
+		stravaClient: stravaClient,
 		tokenStore:   tokenStore,
 		stateStore:   auth.NewStateStore(),
-		ftpManager:   ftpManager,
+		sessionStore: auth.NewSessionStore(),
 		influxClient: influxClient,
 	}
+	if influxClient != nil {
+		h.subscriptions = influxClient
+	}
+	return h
+}
+
+// SetWebhookProcessor sets the processor that handles Strava webhook events
+func (h *Handler) SetWebhookProcessor(processor WebhookProcessor) {
+	h.webhookProcessor = processor
+}
+
+// SetReconciler sets the reconciler used by the reconcile endpoint
+func (h *Handler) SetReconciler(reconciler Reconciler) {
+	h.reconciler = reconciler
+}
+
+// SetFTPSuggester sets the suggester used by the FTP suggestion endpoints
+func (h *Handler) SetFTPSuggester(suggester FTPSuggester) {
+	h.ftpSuggester = suggester
+}
+
+// IsAuthenticated reports whether a usable Strava token is stored
+func (h *Handler) IsAuthenticated(ctx context.Context) bool {
+	return h.tokenStore != nil && h.tokenStore.HasValidToken(ctx)
+}
+
+// sessionCookie is the name of the cookie holding the client's session ID
+const sessionCookie = "session"
+
+// HasSession reports whether the request carries a session issued by the
+// OAuth callback
+func (h *Handler) HasSession(c *gin.Context) bool {
+	id, err := c.Cookie(sessionCookie)
+	return err == nil && h.sessionStore.Validate(id)
+}
+
+// IsAuthorized reports whether the client signed in through Strava and the
+// server still holds a usable token
+func (h *Handler) IsAuthorized(c *gin.Context) bool {
+	return h.HasSession(c) && h.IsAuthenticated(c.Request.Context())
+}
+
+// StartSession issues a new session cookie to the client
+func (h *Handler) StartSession(c *gin.Context) error {
+	id, err := h.sessionStore.Create()
+	if err != nil {
+		return err
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(sessionCookie, id, int(auth.SessionTTL.Seconds()), "/", "", c.Request.TLS != nil, true)
+	return nil
+}
+
+// endSession removes the client's session and its cookie
+func (h *Handler) endSession(c *gin.Context) {
+	if id, err := c.Cookie(sessionCookie); err == nil {
+		h.sessionStore.Remove(id)
+	}
+	c.SetCookie(sessionCookie, "", -1, "/", "", c.Request.TLS != nil, true)
 }
 
 func (h *Handler) Home(c *gin.Context) {
 	slog.Info("Home endpoint called")
-	if h.tokenStore != nil && h.tokenStore.HasValidToken() {
-		slog.Info("Valid token found, redirecting to portal")
+	if h.IsAuthorized(c) {
+		slog.Info("Valid session found, redirecting to portal")
 		c.Redirect(http.StatusFound, "/portal")
 		return
 	}
-	slog.Info("No valid token found, redirecting to login")
+	slog.Info("No valid session found, redirecting to login")
 	c.Redirect(http.StatusFound, "/login")
 }
 
@@ -66,13 +153,13 @@ func (h *Handler) Portal(c *gin.Context) {
 		c.Redirect(http.StatusFound, "/login")
 		return
 	}
-	
-	if !h.tokenStore.HasValidToken() {
+
+	if !h.tokenStore.HasValidToken(c.Request.Context()) {
 		slog.Error("No valid token found, redirecting to login")
 		c.Redirect(http.StatusFound, "/login")
 		return
 	}
-	
+
 	slog.Info("Valid token found, proceeding to portal")
 
 	// Get latest activity
@@ -86,7 +173,7 @@ func (h *Handler) Portal(c *gin.Context) {
 		return
 	}
 
-	latestActivity, err := h.influxClient.GetLatestActivity()
+	latestActivity, err := h.influxClient.GetLatestActivity(c.Request.Context())
 	if err != nil {
 		slog.Error("Failed to get latest activity", "error", err)
 		c.HTML(http.StatusOK, "portal.html", gin.H{
@@ -106,11 +193,46 @@ func (h *Handler) Portal(c *gin.Context) {
 		return
 	}
 
-	c.HTML(http.StatusOK, "portal.html", gin.H{
+	data := gin.H{
 		"title":    "Strava Data Importer - Portal",
 		"loading":  false,
 		"activity": latestActivity,
-	})
+	}
+
+	h.addCurrentSummaries(c.Request.Context(), data)
+
+	if gearStats, err := h.influxClient.GetGearStats(c.Request.Context()); err != nil {
+		slog.Warn("Failed to get gear stats", "error", err)
+	} else {
+		data["gear"] = gearStats
+	}
+
+	if maintenance, err := h.influxClient.GetMaintenanceStatus(c.Request.Context()); err != nil {
+		slog.Warn("Failed to get maintenance status", "error", err)
+	} else {
+		data["maintenance"] = maintenance
+	}
+
+	if fitness, err := h.recentFitness(c.Request.Context(), defaultFitnessDays); err != nil {
+		slog.Warn("Failed to get fitness", "error", err)
+	} else if len(fitness) > 0 {
+		data["fitness"] = fitness
+		data["fitnessToday"] = fitness[len(fitness)-1]
+	}
+
+	if bests, err := h.powerCurveBests(c.Request.Context()); err != nil {
+		slog.Warn("Failed to get power curve", "error", err)
+	} else if bests.AllTime[0].Watts > 0 {
+		data["powerCurve"] = bests
+	}
+
+	if fits, err := h.recentCriticalPower(c.Request.Context(), defaultCriticalPowerDays); err != nil {
+		slog.Warn("Failed to get critical power", "error", err)
+	} else if len(fits) > 0 {
+		data["criticalPower"] = &fits[len(fits)-1]
+	}
+
+	c.HTML(http.StatusOK, "portal.html", data)
 }
 
 func (h *Handler) AuthLogin(c *gin.Context) {
@@ -148,7 +270,7 @@ func (h *Handler) AuthCallback(c *gin.Context) {
 		return
 	}
 
-	token, err := h.stravaClient.ExchangeCodeForToken(code)
+	token, err := h.stravaClient.ExchangeCodeForToken(c.Request.Context(), code)
 	if err != nil {
 		slog.Error("Failed to exchange code for token", "error", err)
 		c.Redirect(http.StatusFound, "/login?error=token_exchange_failed")
@@ -157,24 +279,25 @@ func (h *Handler) AuthCallback(c *gin.Context) {
 
 	slog.Info("Token exchange successful", "athlete_id", token.AthleteID, "expires_at", token.ExpiresAt)
 
-	if err := h.tokenStore.SaveToken(token); err != nil {
+	if err := h.tokenStore.SaveToken(c.Request.Context(), token); err != nil {
 		slog.Error("Failed to save token", "error", err)
 		c.Redirect(http.StatusFound, "/login?error=token_save_failed")
 		return
 	}
 
 	slog.Info("Token saved successfully, checking validity")
-	
+
 	// Verify token was saved correctly
-	if h.tokenStore.HasValidToken() {
+	if h.tokenStore.HasValidToken(c.Request.Context()) {
 		slog.Info("Token validation successful after save")
 	} else {
 		slog.Error("Token validation failed immediately after save")
 	}
 
-	// Load FTP data
-	if err := h.ftpManager.LoadFTPData(); err != nil {
-		slog.Warn("Failed to load FTP data", "error", err)
+	if err := h.StartSession(c); err != nil {
+		slog.Error("Failed to start session", "error", err)
+		c.Redirect(http.StatusFound, "/login?error=session_failed")
+		return
 	}
 
 	slog.Info("Authentication successful")
@@ -182,7 +305,8 @@ func (h *Handler) AuthCallback(c *gin.Context) {
 }
 
 func (h *Handler) AuthLogout(c *gin.Context) {
-	if err := h.tokenStore.ClearToken(); err != nil {
+	h.endSession(c)
+	if err := h.tokenStore.ClearToken(c.Request.Context()); err != nil {
 		slog.Error("Failed to clear token", "error", err)
 	}
 
@@ -190,30 +314,23 @@ func (h *Handler) AuthLogout(c *gin.Context) {
 }
 
 func (h *Handler) RefreshToken(c *gin.Context) {
-	token, err := h.tokenStore.LoadToken()
+	token, err := h.tokenStore.LoadToken(c.Request.Context())
 	if err != nil || token == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "No token found"})
 		return
 	}
 
-	newToken, err := h.stravaClient.RefreshToken(token.RefreshToken)
-	if err != nil {
+	if _, err := h.tokenStore.Refresh(c.Request.Context(), token); err != nil {
 		slog.Error("Failed to refresh token", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
 		return
 	}
 
-	if err := h.tokenStore.SaveToken(newToken); err != nil {
-		slog.Error("Failed to save refreshed token", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save token"})
-		return
-	}
-
 	c.JSON(http.StatusOK, gin.H{"message": "Token refreshed successfully"})
 }
 
 func (h *Handler) GetActivities(c *gin.Context) {
-	token, err := h.tokenStore.LoadToken()
+	token, err := h.tokenStore.LoadToken(c.Request.Context())
 	if err != nil || token == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "No token found"})
 		return
@@ -225,7 +342,7 @@ func (h *Handler) GetActivities(c *gin.Context) {
 		perPage = 30
 	}
 
-	activities, err := h.stravaClient.GetActivities(token.AccessToken, token.ExpiresAt.AddDate(0, 0, -30), perPage)
+	activities, err := h.stravaClient.GetActivities(c.Request.Context(), token.ExpiresAt.AddDate(0, 0, -30), perPage)
 	if err != nil {
 		slog.Error("Failed to get activities", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch activities"})
@@ -238,6 +355,499 @@ func (h *Handler) GetActivities(c *gin.Context) {
 	})
 }
 
+// Reconcile compares the activities of the last days (30 unless the days
+// query parameter says otherwise) with Strava and returns what was repaired
+func (h *Handler) Reconcile(c *gin.Context) {
+	if h.reconciler == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Reconciliation is not available"})
+		return
+	}
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(h.config.ReconcileDays)))
+	if err != nil || days < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days"})
+		return
+	}
+
+	report, err := h.reconciler.Reconcile(c.Request.Context(), time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		slog.Error("Failed to reconcile activities", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile activities"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetFTPSuggestions returns the FTP suggestions awaiting a decision
+func (h *Handler) GetFTPSuggestions(c *gin.Context) {
+	if h.ftpSuggester == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "FTP suggestions are not available"})
+		return
+	}
+
+	suggestions, err := h.ftpSuggester.PendingFTPSuggestions(c.Request.Context())
+	if err != nil {
+		slog.Error("Failed to get FTP suggestions", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get FTP suggestions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, suggestions)
+}
+
+// AcceptFTPSuggestion adds a suggested FTP to the FTP history, which
+// recalculates the TSS of the activities from its date on
+func (h *Handler) AcceptFTPSuggestion(c *gin.Context) {
+	h.decideFTPSuggestion(c, func(ctx context.Context, id string) (*strava.FTPSuggestion, error) {
+		return h.ftpSuggester.AcceptFTPSuggestion(ctx, id)
+	})
+}
+
+// DismissFTPSuggestion rejects a suggested FTP
+func (h *Handler) DismissFTPSuggestion(c *gin.Context) {
+	h.decideFTPSuggestion(c, func(ctx context.Context, id string) (*strava.FTPSuggestion, error) {
+		return h.ftpSuggester.DismissFTPSuggestion(ctx, id)
+	})
+}
+
+// decideFTPSuggestion applies a decision to the suggestion in the id path
+// parameter and maps the outcome to a response
+func (h *Handler) decideFTPSuggestion(c *gin.Context, decide func(ctx context.Context, id string) (*strava.FTPSuggestion, error)) {
+	if h.ftpSuggester == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "FTP suggestions are not available"})
+		return
+	}
+
+	suggestion, err := decide(c.Request.Context(), c.Param("id"))
+	switch {
+	case errors.Is(err, strava.ErrFTPSuggestionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "FTP suggestion not found"})
+	case errors.Is(err, strava.ErrFTPSuggestionNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": "FTP suggestion is not pending"})
+	case err != nil:
+		slog.Error("Failed to update FTP suggestion", "id", c.Param("id"), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update FTP suggestion"})
+	default:
+		c.JSON(http.StatusOK, suggestion)
+	}
+}
+
+// defaultFitnessDays is the number of days the fitness chart shows
+const defaultFitnessDays = 90
+
+// GetFitness returns the daily CTL, ATL and TSB of the last days
+// (?days=, 90 by default) up to today
+func (h *Handler) GetFitness(c *gin.Context) {
+	if h.influxClient == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Fitness data is not available"})
+		return
+	}
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(defaultFitnessDays)))
+	if err != nil || days < 1 || days > 3660 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days"})
+		return
+	}
+
+	fitness, err := h.recentFitness(c.Request.Context(), days)
+	if err != nil {
+		slog.Error("Failed to get fitness", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get fitness"})
+		return
+	}
+
+	c.JSON(http.StatusOK, fitness)
+}
+
+// recentFitness loads the fitness of the given number of days up to today
+func (h *Handler) recentFitness(ctx context.Context, days int) ([]strava.FitnessDay, error) {
+	today := strava.GetDayStart(time.Now().In(h.config.AthleteLocation()))
+	fitness, err := h.influxClient.GetFitness(ctx, today.AddDate(0, 0, 1-days), today.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	if fitness == nil {
+		fitness = []strava.FitnessDay{}
+	}
+	return fitness, nil
+}
+
+// GetWorkload returns the daily acute:chronic workload ratio, monotony and
+// strain of the last days (?days=, 90 by default) up to today
+func (h *Handler) GetWorkload(c *gin.Context) {
+	if h.influxClient == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Workload data is not available"})
+		return
+	}
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(defaultFitnessDays)))
+	if err != nil || days < 1 || days > 3660 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days"})
+		return
+	}
+
+	today := strava.GetDayStart(time.Now().In(h.config.AthleteLocation()))
+	workload, err := h.influxClient.GetWorkload(c.Request.Context(), today.AddDate(0, 0, 1-days), today.AddDate(0, 0, 1))
+	if err != nil {
+		slog.Error("Failed to get workload", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get workload"})
+		return
+	}
+	if workload == nil {
+		workload = []strava.WorkloadDay{}
+	}
+
+	c.JSON(http.StatusOK, workload)
+}
+
+// GetPowerCurve returns the best power for each duration over the last 42
+// days, the current season and all time
+func (h *Handler) GetPowerCurve(c *gin.Context) {
+	if h.influxClient == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Power curve data is not available"})
+		return
+	}
+
+	bests, err := h.powerCurveBests(c.Request.Context())
+	if err != nil {
+		slog.Error("Failed to get power curve", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get power curve"})
+		return
+	}
+
+	c.JSON(http.StatusOK, bests)
+}
+
+// powerCurveBests loads the stored power curves and combines them into the
+// recent, season and all-time bests
+func (h *Handler) powerCurveBests(ctx context.Context) (*strava.PowerCurveBests, error) {
+	now := time.Now().In(h.config.AthleteLocation())
+	curves, err := h.influxClient.GetPowerCurves(ctx, time.Unix(0, 0), now.Add(time.Hour))
+	if err != nil {
+		return nil, err
+	}
+	return strava.CalculatePowerCurveBests(curves, now, h.config.SeasonStartMonth), nil
+}
+
+// addCurrentSummaries adds the summaries of the current week, month and year
+// to the portal data
+func (h *Handler) addCurrentSummaries(ctx context.Context, data gin.H) {
+	today := strava.GetDayStart(time.Now().In(h.config.AthleteLocation()))
+
+	weekStart := strava.GetWeekStart(today)
+	if weekly, err := h.influxClient.GetWeeklySummaries(ctx, weekStart, weekStart.AddDate(0, 0, 7)); err != nil {
+		slog.Warn("Failed to get weekly summary", "error", err)
+	} else if len(weekly) > 0 {
+		data["weeklySummary"] = &weekly[0]
+	}
+
+	monthStart := strava.GetMonthStart(today)
+	if monthly, err := h.influxClient.GetMonthlySummaries(ctx, monthStart, monthStart.AddDate(0, 1, 0)); err != nil {
+		slog.Warn("Failed to get monthly summary", "error", err)
+	} else if len(monthly) > 0 {
+		data["monthlySummary"] = &monthly[0]
+	}
+
+	yearStart := strava.GetYearStart(today)
+	if yearly, err := h.influxClient.GetYearlySummaries(ctx, yearStart, yearStart.AddDate(1, 0, 0)); err != nil {
+		slog.Warn("Failed to get yearly summary", "error", err)
+	} else if len(yearly) > 0 {
+		data["yearlySummary"] = &yearly[0]
+	}
+
+	if rolling, err := h.currentRollingSummaries(ctx, today); err != nil {
+		slog.Warn("Failed to get rolling summaries", "error", err)
+	} else if len(rolling) > 0 {
+		data["rollingSummaries"] = rolling
+	}
+}
+
+// rollingSummaryLookbackDays is how far back the latest stored rolling
+// summaries are looked up when none were written today
+const rollingSummaryLookbackDays = 7
+
+// currentRollingSummaries returns the rolling summaries of the latest stored
+// day up to today, shortest window first. All windows are written together,
+// so the latest day holds every window.
+func (h *Handler) currentRollingSummaries(ctx context.Context, today time.Time) ([]strava.RollingSummary, error) {
+	summaries, err := h.influxClient.GetRollingSummaries(ctx, today.AddDate(0, 0, 1-rollingSummaryLookbackDays), today.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	if len(summaries) == 0 {
+		return []strava.RollingSummary{}, nil
+	}
+
+	latest := summaries[len(summaries)-1].End
+	current := make([]strava.RollingSummary, 0, len(strava.RollingSummaryDays))
+	for _, summary := range summaries {
+		if summary.End.Equal(latest) {
+			current = append(current, summary)
+		}
+	}
+	return current, nil
+}
+
+// GetRollingSummaries returns the totals of the last 7, 28, 90 and 365 days
+// as of the latest import
+func (h *Handler) GetRollingSummaries(c *gin.Context) {
+	if h.influxClient == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Summary data is not available"})
+		return
+	}
+
+	today := strava.GetDayStart(time.Now().In(h.config.AthleteLocation()))
+	summaries, err := h.currentRollingSummaries(c.Request.Context(), today)
+	if err != nil {
+		slog.Error("Failed to get rolling summaries", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get rolling summaries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summaries)
+}
+
+// maxSummaryPeriods is the largest number of periods the summary endpoints
+// return
+const maxSummaryPeriods = 520
+
+// summaryPeriods parses the number of periods (?periods=) of a summary
+// request, writing a 503 or 400 response and returning false when the
+// request cannot be served
+func (h *Handler) summaryPeriods(c *gin.Context, defaultPeriods int) (int, bool) {
+	if h.influxClient == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Summary data is not available"})
+		return 0, false
+	}
+
+	periods, err := strconv.Atoi(c.DefaultQuery("periods", strconv.Itoa(defaultPeriods)))
+	if err != nil || periods < 1 || periods > maxSummaryPeriods {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid periods"})
+		return 0, false
+	}
+	return periods, true
+}
+
+// GetWeeklySummaries returns the weekly summaries with their per-sport totals
+// for the last weeks (?periods=, 8 by default) up to the current week
+func (h *Handler) GetWeeklySummaries(c *gin.Context) {
+	periods, ok := h.summaryPeriods(c, 8)
+	if !ok {
+		return
+	}
+
+	weekStart := strava.GetWeekStart(time.Now().In(h.config.AthleteLocation()))
+	summaries, err := h.influxClient.GetWeeklySummaries(c.Request.Context(), weekStart.AddDate(0, 0, -7*(periods-1)), weekStart.AddDate(0, 0, 7))
+	if err != nil {
+		slog.Error("Failed to get weekly summaries", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get weekly summaries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summaries)
+}
+
+// GetMonthlySummaries returns the monthly summaries with their per-sport
+// totals for the last months (?periods=, 12 by default) up to the current one
+func (h *Handler) GetMonthlySummaries(c *gin.Context) {
+	periods, ok := h.summaryPeriods(c, 12)
+	if !ok {
+		return
+	}
+
+	monthStart := strava.GetMonthStart(time.Now().In(h.config.AthleteLocation()))
+	summaries, err := h.influxClient.GetMonthlySummaries(c.Request.Context(), monthStart.AddDate(0, 1-periods, 0), monthStart.AddDate(0, 1, 0))
+	if err != nil {
+		slog.Error("Failed to get monthly summaries", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get monthly summaries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summaries)
+}
+
+// GetYearlySummaries returns the yearly summaries with their per-sport totals
+// for the last years (?periods=, 5 by default) up to the current one
+func (h *Handler) GetYearlySummaries(c *gin.Context) {
+	periods, ok := h.summaryPeriods(c, 5)
+	if !ok {
+		return
+	}
+
+	yearStart := strava.GetYearStart(time.Now().In(h.config.AthleteLocation()))
+	summaries, err := h.influxClient.GetYearlySummaries(c.Request.Context(), yearStart.AddDate(1-periods, 0, 0), yearStart.AddDate(1, 0, 0))
+	if err != nil {
+		slog.Error("Failed to get yearly summaries", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get yearly summaries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summaries)
+}
+
+// defaultCriticalPowerDays is the number of days of critical power fits
+// returned by default
+const defaultCriticalPowerDays = 365
+
+// GetCriticalPower returns the daily critical power fits with the FTP of the
+// same day for the last days (?days=, 365 by default)
+func (h *Handler) GetCriticalPower(c *gin.Context) {
+	if h.influxClient == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Critical power data is not available"})
+		return
+	}
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(defaultCriticalPowerDays)))
+	if err != nil || days < 1 || days > 3660 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days"})
+		return
+	}
+
+	fits, err := h.recentCriticalPower(c.Request.Context(), days)
+	if err != nil {
+		slog.Error("Failed to get critical power", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get critical power"})
+		return
+	}
+
+	c.JSON(http.StatusOK, fits)
+}
+
+// recentCriticalPower loads the critical power fits of the given number of
+// days up to today
+func (h *Handler) recentCriticalPower(ctx context.Context, days int) ([]strava.CriticalPower, error) {
+	today := strava.GetDayStart(time.Now().In(h.config.AthleteLocation()))
+	fits, err := h.influxClient.GetCriticalPower(ctx, today.AddDate(0, 0, 1-days), today.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	if fits == nil {
+		fits = []strava.CriticalPower{}
+	}
+	return fits, nil
+}
+
+// StravaWebhookChallenge answers the GET validation request Strava sends
+// when a push subscription is created
+func (h *Handler) StravaWebhookChallenge(c *gin.Context) {
+	mode := c.Query("hub.mode")
+	verifyToken := c.Query("hub.verify_token")
+	challenge := c.Query("hub.challenge")
+
+	if mode != "subscribe" || h.config.StravaWebhookVerifyToken == "" || verifyToken != h.config.StravaWebhookVerifyToken {
+		slog.Warn("Rejected Strava webhook challenge", "mode", mode)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid verify token"})
+		return
+	}
+
+	slog.Info("Strava webhook subscription validated")
+	c.JSON(http.StatusOK, gin.H{"hub.challenge": challenge})
+}
+
+// StravaWebhookEvent receives push subscription events. Strava expects an
+// acknowledgement within two seconds, so events are processed asynchronously.
+func (h *Handler) StravaWebhookEvent(c *gin.Context) {
+	var event strava.WebhookEvent
+	if err := c.ShouldBindJSON(&event); err != nil {
+		slog.Error("Failed to decode Strava webhook event", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event"})
+		return
+	}
+
+	slog.Info("Received Strava webhook event", "object_type", event.ObjectType, "object_id", event.ObjectID, "aspect_type", event.AspectType, "owner_id", event.OwnerID)
+
+	// The request context ends with the response; the processor cancels the
+	// work itself when the application shuts down
+	go h.processWebhookEvent(context.WithoutCancel(c.Request.Context()), event)
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) processWebhookEvent(ctx context.Context, event strava.WebhookEvent) {
+	if h.webhookProcessor == nil {
+		slog.Warn("No webhook processor configured, dropping event", "object_type", event.ObjectType, "object_id", event.ObjectID)
+		return
+	}
+	if !h.isOwnWebhookEvent(ctx, event) {
+		return
+	}
+
+	var err error
+	switch {
+	case event.IsDeauthorization():
+		err = h.webhookProcessor.RevokeAuthorization(ctx, event.OwnerID)
+	case event.ObjectType == strava.WebhookObjectActivity && event.AspectType == strava.WebhookAspectDelete:
+		err = h.webhookProcessor.DeleteActivity(ctx, event.ObjectID)
+	case event.ObjectType == strava.WebhookObjectActivity:
+		err = h.webhookProcessor.ImportActivity(ctx, event.ObjectID, event.AspectType == strava.WebhookAspectCreate)
+	default:
+		slog.Debug("Ignoring Strava webhook event", "object_type", event.ObjectType, "aspect_type", event.AspectType)
+	}
+
+	if err != nil {
+		slog.Error("Failed to process Strava webhook event", "object_type", event.ObjectType, "object_id", event.ObjectID, "aspect_type", event.AspectType, "error", err)
+	}
+}
+
+// isOwnWebhookEvent reports whether the event was sent for our push
+// subscription and concerns the signed in athlete. The webhook route is
+// public, so anything else may be forged and is dropped.
+func (h *Handler) isOwnWebhookEvent(ctx context.Context, event strava.WebhookEvent) bool {
+	subscriptionID, err := h.webhookSubscriptionID(ctx)
+	if err != nil {
+		slog.Error("Failed to load webhook subscription, dropping event", "object_id", event.ObjectID, "error", err)
+		return false
+	}
+	if subscriptionID == 0 || event.SubscriptionID != subscriptionID {
+		slog.Warn("Dropping webhook event for an unknown subscription", "subscription_id", event.SubscriptionID, "registered_subscription_id", subscriptionID)
+		return false
+	}
+
+	if h.tokenStore == nil {
+		slog.Warn("No token store configured, dropping webhook event", "object_id", event.ObjectID)
+		return false
+	}
+	token, err := h.tokenStore.LoadToken(ctx)
+	if err != nil || token == nil {
+		slog.Warn("No token found, dropping webhook event", "object_id", event.ObjectID, "error", err)
+		return false
+	}
+	if event.OwnerID != token.AthleteID {
+		slog.Warn("Dropping webhook event for another athlete", "owner_id", event.OwnerID, "athlete_id", token.AthleteID)
+		return false
+	}
+	return true
+}
+
+// webhookSubscriptionID returns the recorded push subscription ID. Without
+// a record, e.g. for a subscription registered before IDs were recorded, the
+// application's subscription is looked up on Strava and recorded.
+func (h *Handler) webhookSubscriptionID(ctx context.Context) (int64, error) {
+	if h.subscriptions == nil {
+		return 0, errors.New("no webhook subscription store configured")
+	}
+
+	subscriptionID, err := h.subscriptions.LoadWebhookSubscription(ctx)
+	if err != nil || subscriptionID != 0 {
+		return subscriptionID, err
+	}
+
+	subscriptions, err := h.stravaClient.ListPushSubscriptions(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(subscriptions) != 1 {
+		return 0, nil
+	}
+
+	subscriptionID = subscriptions[0].ID
+	if err := h.subscriptions.SaveWebhookSubscription(ctx, subscriptionID); err != nil {
+		return 0, err
+	}
+	slog.Info("Recorded the webhook subscription registered on Strava", "subscription_id", subscriptionID)
+	return subscriptionID, nil
+}
+
 func (h *Handler) Health(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"status":    "ok",
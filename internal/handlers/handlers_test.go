@@ -1,13 +1,20 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
+	"stravaDataImporter/internal/auth"
 	"stravaDataImporter/internal/config"
 	"stravaDataImporter/internal/db"
 	"stravaDataImporter/internal/strava"
+	"stravaDataImporter/internal/strava/fake"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,16 +22,32 @@ import (
 // InfluxDBClientの完全なモック実装
 type mockInfluxDBClient struct{}
 
-func (m *mockInfluxDBClient) Close()                                                   {}
-func (m *mockInfluxDBClient) WriteActivity(activity *strava.ActivityData) error        { return nil }
-func (m *mockInfluxDBClient) WriteWeeklySummary(summary *strava.WeeklySummary) error   { return nil }
-func (m *mockInfluxDBClient) WriteMonthlySummary(summary *strava.MonthlySummary) error { return nil }
-func (m *mockInfluxDBClient) WriteYearlySummary(summary *strava.YearlySummary) error   { return nil }
-func (m *mockInfluxDBClient) GetLatestActivity() (*strava.ActivityData, error)         { return nil, nil }
-func (m *mockInfluxDBClient) GetWeeklyTrend() ([]strava.WeeklySummary, error)          { return nil, nil }
-func (m *mockInfluxDBClient) SaveToken(token *strava.TokenData) error                  { return nil }
-func (m *mockInfluxDBClient) LoadToken() (*strava.TokenData, error)                    { return nil, nil }
-func (m *mockInfluxDBClient) ClearToken() error                                        { return nil }
+func (m *mockInfluxDBClient) Close(ctx context.Context) {}
+func (m *mockInfluxDBClient) WriteActivity(ctx context.Context, activity *strava.ActivityData) error {
+	return nil
+}
+func (m *mockInfluxDBClient) WriteWeeklySummary(ctx context.Context, summary *strava.WeeklySummary) error {
+	return nil
+}
+func (m *mockInfluxDBClient) WriteMonthlySummary(ctx context.Context, summary *strava.MonthlySummary) error {
+	return nil
+}
+func (m *mockInfluxDBClient) WriteYearlySummary(ctx context.Context, summary *strava.YearlySummary) error {
+	return nil
+}
+func (m *mockInfluxDBClient) GetLatestActivity(ctx context.Context) (*strava.ActivityData, error) {
+	return nil, nil
+}
+func (m *mockInfluxDBClient) GetWeeklyTrend(ctx context.Context) ([]strava.WeeklySummary, error) {
+	return nil, nil
+}
+func (m *mockInfluxDBClient) SaveToken(ctx context.Context, token *strava.TokenData) error {
+	return nil
+}
+func (m *mockInfluxDBClient) LoadToken(ctx context.Context) (*strava.TokenData, error) {
+	return nil, nil
+}
+func (m *mockInfluxDBClient) ClearToken(ctx context.Context) error { return nil }
 
 // mockInfluxDBClientを*db.InfluxDBClientに変換するヘルパー
 func createMockInfluxDBClient() *db.InfluxDBClient {
@@ -41,7 +64,7 @@ func TestNewHandler(t *testing.T) {
 	}
 
 	// nilクライアントでテスト - これは実際のテストではハンドラーの作成をスキップする
-	handler := NewHandler(cfg, nil)
+	handler := NewHandler(cfg, nil, nil)
 	if handler == nil {
 		t.Fatal("NewHandler() returned nil")
 	}
@@ -55,7 +78,7 @@ func TestHealthHandler(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	cfg := &config.Config{}
-	handler := NewHandler(cfg, nil)
+	handler := NewHandler(cfg, nil, nil)
 
 	router := gin.New()
 	router.GET("/health", handler.Health)
@@ -82,7 +105,7 @@ func TestHomeRedirect(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	cfg := &config.Config{}
-	handler := NewHandler(cfg, nil)
+	handler := NewHandler(cfg, nil, nil)
 
 	router := gin.New()
 	router.GET("/", handler.Home)
@@ -105,3 +128,460 @@ func TestHomeRedirect(t *testing.T) {
 		t.Errorf("Home handler redirected to wrong location: got %v want %v", location, "/login")
 	}
 }
+
+// recordingWebhookProcessor records the events passed to the processor
+type recordingWebhookProcessor struct {
+	imported []int64
+	created  []bool
+	deleted  []int64
+	revoked  []int64
+}
+
+func (p *recordingWebhookProcessor) ImportActivity(ctx context.Context, activityID int64, created bool) error {
+	p.imported = append(p.imported, activityID)
+	p.created = append(p.created, created)
+	return nil
+}
+
+func (p *recordingWebhookProcessor) DeleteActivity(ctx context.Context, activityID int64) error {
+	p.deleted = append(p.deleted, activityID)
+	return nil
+}
+
+func (p *recordingWebhookProcessor) RevokeAuthorization(ctx context.Context, athleteID int64) error {
+	p.revoked = append(p.revoked, athleteID)
+	return nil
+}
+
+func TestStravaWebhookChallenge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{StravaWebhookVerifyToken: "verify"}
+	handler := NewHandler(cfg, nil, nil)
+
+	router := gin.New()
+	router.GET("/webhook/strava", handler.StravaWebhookChallenge)
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"valid token", "hub.mode=subscribe&hub.verify_token=verify&hub.challenge=abc", http.StatusOK},
+		{"wrong token", "hub.mode=subscribe&hub.verify_token=wrong&hub.challenge=abc", http.StatusForbidden},
+		{"wrong mode", "hub.mode=unsubscribe&hub.verify_token=verify&hub.challenge=abc", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/webhook/strava?"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.status {
+				t.Errorf("status = %v, want %v", rr.Code, tt.status)
+			}
+			if tt.status == http.StatusOK && rr.Body.String() != `{"hub.challenge":"abc"}` {
+				t.Errorf("body = %v, want challenge echo", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestStravaWebhookEvent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewHandler(&config.Config{}, nil, nil)
+
+	router := gin.New()
+	router.POST("/webhook/strava", handler.StravaWebhookEvent)
+
+	body := `{"object_type":"activity","object_id":1,"aspect_type":"create","owner_id":5,"subscription_id":9,"event_time":1700000000}`
+	req, _ := http.NewRequest("POST", "/webhook/strava", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("status = %v, want %v", rr.Code, http.StatusOK)
+	}
+
+	req, _ = http.NewRequest("POST", "/webhook/strava", strings.NewReader("not json"))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("status = %v, want %v for invalid body", rr.Code, http.StatusBadRequest)
+	}
+}
+
+// memorySubscriptionStore keeps the recorded subscription ID in memory
+type memorySubscriptionStore struct {
+	subscriptionID int64
+}
+
+func (m *memorySubscriptionStore) SaveWebhookSubscription(ctx context.Context, subscriptionID int64) error {
+	m.subscriptionID = subscriptionID
+	return nil
+}
+
+func (m *memorySubscriptionStore) LoadWebhookSubscription(ctx context.Context) (int64, error) {
+	return m.subscriptionID, nil
+}
+
+// memoryTokenStore keeps the stored token in memory
+type memoryTokenStore struct {
+	token *strava.TokenData
+}
+
+func (m *memoryTokenStore) SaveToken(ctx context.Context, token *strava.TokenData) error {
+	m.token = token
+	return nil
+}
+
+func (m *memoryTokenStore) LoadToken(ctx context.Context) (*strava.TokenData, error) {
+	return m.token, nil
+}
+
+func (m *memoryTokenStore) ClearToken(ctx context.Context) error {
+	m.token = nil
+	return nil
+}
+
+// newWebhookHandler returns a handler subscribed as subscription 9 for
+// athlete 5
+func newWebhookHandler(processor WebhookProcessor) *Handler {
+	tokenStore := auth.NewTokenStore(&memoryTokenStore{token: &strava.TokenData{AccessToken: "access", AthleteID: 5, ExpiresAt: time.Now().Add(time.Hour)}})
+	handler := NewHandler(&config.Config{}, nil, tokenStore)
+	handler.SetWebhookProcessor(processor)
+	handler.subscriptions = &memorySubscriptionStore{subscriptionID: 9}
+	return handler
+}
+
+func TestProcessWebhookEvent(t *testing.T) {
+	processor := &recordingWebhookProcessor{}
+	handler := newWebhookHandler(processor)
+
+	handler.processWebhookEvent(context.Background(), strava.WebhookEvent{ObjectType: "activity", ObjectID: 1, OwnerID: 5, SubscriptionID: 9, AspectType: "create"})
+	handler.processWebhookEvent(context.Background(), strava.WebhookEvent{ObjectType: "activity", ObjectID: 2, OwnerID: 5, SubscriptionID: 9, AspectType: "update", Updates: map[string]string{"title": "Renamed"}})
+	handler.processWebhookEvent(context.Background(), strava.WebhookEvent{ObjectType: "activity", ObjectID: 3, OwnerID: 5, SubscriptionID: 9, AspectType: "delete"})
+	handler.processWebhookEvent(context.Background(), strava.WebhookEvent{ObjectType: "athlete", ObjectID: 5, OwnerID: 5, SubscriptionID: 9, AspectType: "update", Updates: map[string]string{"authorized": "false"}})
+	handler.processWebhookEvent(context.Background(), strava.WebhookEvent{ObjectType: "athlete", ObjectID: 5, OwnerID: 5, SubscriptionID: 9, AspectType: "update", Updates: map[string]string{"weight": "70"}})
+
+	if len(processor.imported) != 2 || processor.imported[0] != 1 || processor.imported[1] != 2 {
+		t.Errorf("imported = %v, want [1 2]", processor.imported)
+	}
+	if len(processor.created) != 2 || !processor.created[0] || processor.created[1] {
+		t.Errorf("created = %v, want only the create event marked", processor.created)
+	}
+	if len(processor.deleted) != 1 || processor.deleted[0] != 3 {
+		t.Errorf("deleted = %v, want [3]", processor.deleted)
+	}
+	if len(processor.revoked) != 1 || processor.revoked[0] != 5 {
+		t.Errorf("revoked = %v, want [5]", processor.revoked)
+	}
+}
+
+func TestProcessWebhookEventDropsForgedEvents(t *testing.T) {
+	processor := &recordingWebhookProcessor{}
+	handler := newWebhookHandler(processor)
+
+	// Another subscription, a missing subscription and another athlete
+	handler.processWebhookEvent(context.Background(), strava.WebhookEvent{ObjectType: "activity", ObjectID: 3, OwnerID: 5, SubscriptionID: 8, AspectType: "delete"})
+	handler.processWebhookEvent(context.Background(), strava.WebhookEvent{ObjectType: "activity", ObjectID: 3, OwnerID: 5, AspectType: "delete"})
+	handler.processWebhookEvent(context.Background(), strava.WebhookEvent{ObjectType: "activity", ObjectID: 3, OwnerID: 6, SubscriptionID: 9, AspectType: "create"})
+	handler.processWebhookEvent(context.Background(), strava.WebhookEvent{ObjectType: "athlete", ObjectID: 6, OwnerID: 6, SubscriptionID: 9, AspectType: "update", Updates: map[string]string{"authorized": "false"}})
+
+	if len(processor.imported) != 0 || len(processor.deleted) != 0 || len(processor.revoked) != 0 {
+		t.Errorf("processed forged events: imported %v, deleted %v, revoked %v", processor.imported, processor.deleted, processor.revoked)
+	}
+}
+
+// recordingReconciler records the requested reconciliation windows
+type recordingReconciler struct {
+	since []time.Time
+}
+
+func (r *recordingReconciler) Reconcile(ctx context.Context, since time.Time) (*strava.ReconcileReport, error) {
+	r.since = append(r.since, since)
+	return &strava.ReconcileReport{Since: since, Checked: 2, Deleted: []int64{7}}, nil
+}
+
+func TestReconcile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	reconciler := &recordingReconciler{}
+	handler := NewHandler(&config.Config{ReconcileDays: 30}, nil, nil)
+	handler.SetReconciler(reconciler)
+
+	router := gin.New()
+	router.POST("/api/v1/reconcile", handler.Reconcile)
+
+	req, _ := http.NewRequest("POST", "/api/v1/reconcile?days=7", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %v, want %v", rr.Code, http.StatusOK)
+	}
+	var report strava.ReconcileReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+	if report.Checked != 2 || len(report.Deleted) != 1 {
+		t.Errorf("report = %+v, want the reconciler's report", report)
+	}
+
+	req, _ = http.NewRequest("POST", "/api/v1/reconcile", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	if len(reconciler.since) != 2 {
+		t.Fatalf("reconciled %d times, want 2", len(reconciler.since))
+	}
+	if days := time.Since(reconciler.since[0]).Hours() / 24; days < 6.9 || days > 7.1 {
+		t.Errorf("days=7 reconciled %.1f days", days)
+	}
+	if days := time.Since(reconciler.since[1]).Hours() / 24; days < 29.9 || days > 30.1 {
+		t.Errorf("default reconciled %.1f days, want 30", days)
+	}
+
+	req, _ = http.NewRequest("POST", "/api/v1/reconcile?days=abc", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("status = %v, want %v for invalid days", rr.Code, http.StatusBadRequest)
+	}
+}
+
+// memoryFTPSuggester keeps suggestions in memory
+type memoryFTPSuggester struct {
+	suggestions []strava.FTPSuggestion
+}
+
+func (m *memoryFTPSuggester) PendingFTPSuggestions(ctx context.Context) ([]strava.FTPSuggestion, error) {
+	var pending []strava.FTPSuggestion
+	for _, suggestion := range m.suggestions {
+		if suggestion.Status == strava.FTPSuggestionPending {
+			pending = append(pending, suggestion)
+		}
+	}
+	return pending, nil
+}
+
+func (m *memoryFTPSuggester) AcceptFTPSuggestion(ctx context.Context, id string) (*strava.FTPSuggestion, error) {
+	return m.decide(id, strava.FTPSuggestionAccepted)
+}
+
+func (m *memoryFTPSuggester) DismissFTPSuggestion(ctx context.Context, id string) (*strava.FTPSuggestion, error) {
+	return m.decide(id, strava.FTPSuggestionDismissed)
+}
+
+func (m *memoryFTPSuggester) decide(id, status string) (*strava.FTPSuggestion, error) {
+	for i := range m.suggestions {
+		if m.suggestions[i].ID == id {
+			if m.suggestions[i].Status != strava.FTPSuggestionPending {
+				return nil, strava.ErrFTPSuggestionNotPending
+			}
+			m.suggestions[i].Status = status
+			return &m.suggestions[i], nil
+		}
+	}
+	return nil, strava.ErrFTPSuggestionNotFound
+}
+
+func TestFTPSuggestions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	suggester := &memoryFTPSuggester{suggestions: []strava.FTPSuggestion{
+		{ID: "2024-06-01-20min", FTP: 260, Status: strava.FTPSuggestionPending},
+		{ID: "2024-05-01-cp", FTP: 250, Status: strava.FTPSuggestionDismissed},
+	}}
+	handler := NewHandler(&config.Config{}, nil, nil)
+	handler.SetFTPSuggester(suggester)
+
+	router := gin.New()
+	router.GET("/api/v1/ftp-suggestions", handler.GetFTPSuggestions)
+	router.POST("/api/v1/ftp-suggestions/:id/accept", handler.AcceptFTPSuggestion)
+	router.POST("/api/v1/ftp-suggestions/:id/dismiss", handler.DismissFTPSuggestion)
+
+	req, _ := http.NewRequest("GET", "/api/v1/ftp-suggestions", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var pending []strava.FTPSuggestion
+	if err := json.Unmarshal(rr.Body.Bytes(), &pending); err != nil {
+		t.Fatalf("failed to decode suggestions: %v", err)
+	}
+	if rr.Code != http.StatusOK || len(pending) != 1 || pending[0].FTP != 260 {
+		t.Errorf("status = %v, pending = %+v, want the pending suggestion", rr.Code, pending)
+	}
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/api/v1/ftp-suggestions/2024-06-01-20min/accept", http.StatusOK},
+		{"/api/v1/ftp-suggestions/2024-06-01-20min/dismiss", http.StatusConflict},
+		{"/api/v1/ftp-suggestions/unknown/accept", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", tt.path, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("POST %s status = %v, want %v", tt.path, rr.Code, tt.want)
+		}
+	}
+	if suggester.suggestions[0].Status != strava.FTPSuggestionAccepted {
+		t.Errorf("suggestion status = %s, want accepted", suggester.suggestions[0].Status)
+	}
+}
+
+func TestGetFitnessWithoutInfluxDB(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewHandler(&config.Config{}, nil, nil)
+	router := gin.New()
+	router.GET("/api/v1/fitness", handler.GetFitness)
+
+	req, _ := http.NewRequest("GET", "/api/v1/fitness", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %v, want %v", rr.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestGetWorkloadWithoutInfluxDB(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewHandler(&config.Config{}, nil, nil)
+	router := gin.New()
+	router.GET("/api/v1/workload", handler.GetWorkload)
+
+	req, _ := http.NewRequest("GET", "/api/v1/workload", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %v, want %v", rr.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestGetSummariesWithoutInfluxDB(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewHandler(&config.Config{}, nil, nil)
+	router := gin.New()
+	router.GET("/api/v1/summaries/weekly", handler.GetWeeklySummaries)
+	router.GET("/api/v1/summaries/monthly", handler.GetMonthlySummaries)
+	router.GET("/api/v1/summaries/yearly", handler.GetYearlySummaries)
+	router.GET("/api/v1/summaries/rolling", handler.GetRollingSummaries)
+
+	for _, path := range []string{"/api/v1/summaries/weekly", "/api/v1/summaries/monthly", "/api/v1/summaries/yearly", "/api/v1/summaries/rolling"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s status = %v, want %v", path, rr.Code, http.StatusServiceUnavailable)
+		}
+	}
+}
+
+func TestGetPowerCurveWithoutInfluxDB(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewHandler(&config.Config{}, nil, nil)
+	router := gin.New()
+	router.GET("/api/v1/power-curve", handler.GetPowerCurve)
+
+	req, _ := http.NewRequest("GET", "/api/v1/power-curve", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %v, want %v", rr.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestGetCriticalPowerWithoutInfluxDB(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewHandler(&config.Config{}, nil, nil)
+	router := gin.New()
+	router.GET("/api/v1/critical-power", handler.GetCriticalPower)
+
+	req, _ := http.NewRequest("GET", "/api/v1/critical-power", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %v, want %v", rr.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestAuthCallbackWithFakeStrava(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	fakeStrava, err := fake.New()
+	if err != nil {
+		t.Fatalf("fake.New() error = %v", err)
+	}
+	server := httptest.NewServer(fakeStrava)
+	defer server.Close()
+
+	cfg := &config.Config{
+		StravaClientID:     "test_client_id",
+		StravaClientSecret: "test_client_secret",
+		StravaRedirectURL:  "http://localhost:9090/auth/callback",
+		FTPFilePath:        "./test_ftp.csv",
+	}
+	fake.Configure(cfg, server.URL)
+
+	tokenStore := auth.NewTokenStore(&mockInfluxDBClient{})
+	handler := NewHandler(cfg, nil, tokenStore)
+
+	router := gin.New()
+	router.GET("/auth/login", handler.AuthLogin)
+	router.GET("/auth/callback", handler.AuthCallback)
+
+	// Start the login and let the fake authorize page redirect back
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/auth/login", nil)
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusFound {
+		t.Fatalf("login status = %v, want %v", rr.Code, http.StatusFound)
+	}
+	authURL := rr.Header().Get("Location")
+	if !strings.HasPrefix(authURL, server.URL+"/oauth/authorize") {
+		t.Fatalf("login redirected to %q, want the fake authorize URL", authURL)
+	}
+
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := noRedirect.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorize request failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	callbackURL, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || callbackURL.Path != "/auth/callback" {
+		t.Fatalf("authorize redirected to %q, want the callback URL", resp.Header.Get("Location"))
+	}
+
+	rr = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", callbackURL.RequestURI(), nil)
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusFound || rr.Header().Get("Location") != "/portal" {
+		t.Fatalf("callback = %v %q, want redirect to /portal", rr.Code, rr.Header().Get("Location"))
+	}
+
+	token, err := tokenStore.LoadToken(context.Background())
+	if err != nil || token == nil {
+		t.Fatalf("LoadToken() = %v, %v, want the exchanged token", token, err)
+	}
+	if token.AthleteID != fakeStrava.AthleteID() {
+		t.Errorf("AthleteID = %d, want %d", token.AthleteID, fakeStrava.AthleteID())
+	}
+	if !handler.IsAuthenticated(context.Background()) {
+		t.Error("handler should be authenticated after the callback")
+	}
+
+	// Replaying the callback fails because the state was consumed
+	rr = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", callbackURL.RequestURI(), nil)
+	router.ServeHTTP(rr, req)
+	if rr.Header().Get("Location") != "/login?error=invalid_state" {
+		t.Errorf("replayed callback redirected to %q, want invalid_state", rr.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"stravaDataImporter/internal/strava"
+)
+
+// updateCriticalPower fits the critical power model to the best efforts of
+// the configured window up to today and stores the fit as today's value
+func (s *Scheduler) updateCriticalPower(ctx context.Context) {
+	today := strava.GetDayStart(time.Now().In(s.config.AthleteLocation()))
+	start := today.AddDate(0, 0, 1-s.config.CPWindowDays)
+	end := today.AddDate(0, 0, 1)
+
+	curves, err := s.influxClient.GetPowerCurves(ctx, start, end)
+	if err != nil {
+		slog.Error("Failed to load power curves for critical power", "error", err)
+		return
+	}
+
+	cp, wPrime, efforts, ok := strava.FitCriticalPower(strava.BestPowerCurve(curves, start, end))
+	if !ok {
+		slog.Debug("Not enough efforts to fit critical power", "window_days", s.config.CPWindowDays, "efforts", efforts)
+		return
+	}
+
+	fit := &strava.CriticalPower{
+		Date:       today,
+		CP:         cp,
+		WPrime:     wPrime,
+		WindowDays: s.config.CPWindowDays,
+		Efforts:    efforts,
+		FTP:        s.ftpManager.GetFTPForDate(time.Now()),
+	}
+	if err := s.influxClient.WriteCriticalPower(ctx, fit); err != nil {
+		slog.Error("Failed to write critical power", "error", err)
+		return
+	}
+
+	slog.Info("Critical power updated", "cp", cp, "w_prime", wPrime, "efforts", efforts, "ftp", fit.FTP)
+}
+
+// criticalPowerAt returns the latest critical power fit stored before the
+// given time within the fit window, or nil when there is none
+func (s *Scheduler) criticalPowerAt(ctx context.Context, date time.Time) *strava.CriticalPower {
+	fits, err := s.influxClient.GetCriticalPower(ctx, date.AddDate(0, 0, -s.config.CPWindowDays), date)
+	if err != nil {
+		slog.Warn("Failed to load critical power", "error", err)
+		return nil
+	}
+	if len(fits) == 0 {
+		return nil
+	}
+	return &fits[len(fits)-1]
+}
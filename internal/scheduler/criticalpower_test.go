@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"stravaDataImporter/internal/strava"
+)
+
+func TestUpdateCriticalPower(t *testing.T) {
+	s, _, store := newFakeStravaScheduler(t)
+	today := strava.GetDayStart(time.Now())
+
+	// Best efforts that follow CP 250 W and W' 20 kJ exactly
+	watts := make([]float64, len(strava.PowerCurveDurations))
+	for i, duration := range strava.PowerCurveDurations {
+		watts[i] = 250 + 20000/float64(duration)
+	}
+	_ = store.WritePowerCurve(context.Background(), &strava.PowerCurve{ActivityID: 1, StartDate: today.AddDate(0, 0, -3), Watts: watts})
+	_ = store.WritePowerCurve(context.Background(), &strava.PowerCurve{ActivityID: 2, StartDate: today.AddDate(0, 0, -200), Watts: []float64{900, 800, 700, 600, 500, 450, 400, 380, 370, 360, 350, 340}})
+
+	s.updateCriticalPower(context.Background())
+
+	fit, ok := store.criticalPower[today.Unix()]
+	if !ok {
+		t.Fatal("no critical power written for today")
+	}
+	if math.Abs(fit.CP-250) > 1e-6 || math.Abs(fit.WPrime-20000) > 1e-3 {
+		t.Errorf("fit = CP %v W' %v, want 250 W and 20000 J from the efforts inside the window", fit.CP, fit.WPrime)
+	}
+	if fit.Efforts != 4 || fit.WindowDays != 90 || fit.FTP != s.ftpManager.GetFTPForDate(time.Now()) {
+		t.Errorf("fit = %+v, want 4 efforts, a 90 day window and the current FTP", fit)
+	}
+}
+
+func TestImportWPrimeBalance(t *testing.T) {
+	s, fakeStrava, store := newFakeStravaScheduler(t)
+	fakeStrava.ShiftActivities(time.Now().Add(-3 * time.Hour))
+
+	_ = store.WriteCriticalPower(context.Background(), &strava.CriticalPower{
+		Date:   strava.GetDayStart(time.Now()).AddDate(0, 0, -1),
+		CP:     200,
+		WPrime: 30000,
+	})
+
+	s.importDataJob(context.Background())
+
+	ride := store.activities[1001]
+	if ride == nil {
+		t.Fatal("ride 1001 was not imported")
+	}
+	if ride.CP != 200 || ride.WPrime != 30000 || ride.WPrimeBalanceMin <= 0 || ride.WPrimeBalanceMin >= 30000 {
+		t.Errorf("ride W'bal = CP %v W' %v min %v, want a partially depleted W'", ride.CP, ride.WPrime, ride.WPrimeBalanceMin)
+	}
+	if streams := store.streams[1001]; len(streams.WPrimeBalance) != len(streams.Watts) {
+		t.Errorf("W'bal samples = %d, want one per watts sample (%d)", len(streams.WPrimeBalance), len(streams.Watts))
+	}
+
+	if run := store.activities[1002]; run.CP != 0 {
+		t.Errorf("run CP = %v, want no W'bal without a power stream", run.CP)
+	}
+}
@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"stravaDataImporter/internal/strava"
+)
+
+// fitnessJob extends the fitness and workload series through today, so that
+// rest days show the decay of fitness and fatigue, and refits critical power
+// as old efforts leave the window
+func (s *Scheduler) fitnessJob(ctx context.Context) {
+	slog.Info("Starting fitness job")
+	s.reloadFTP(ctx)
+	s.updateFitness(ctx, time.Now())
+	s.updateWorkload(ctx, time.Now())
+	s.updateRollingSummaries(ctx)
+	s.updateCriticalPower(ctx)
+}
+
+// updateFitness recomputes the fitness series from the day containing since
+// through today, continuing from the stored day before. Without that day, or
+// when the time constants changed, the whole history is recomputed.
+func (s *Scheduler) updateFitness(ctx context.Context, since time.Time) {
+	loc := s.config.AthleteLocation()
+	today := strava.GetDayStart(time.Now().In(loc))
+	from := strava.GetDayStart(since.In(loc))
+	if from.After(today) {
+		from = today
+	}
+
+	seed, err := s.influxClient.GetFitness(ctx, from.AddDate(0, 0, -1), from)
+	if err != nil {
+		slog.Error("Failed to load fitness", "error", err)
+		return
+	}
+
+	var previous strava.FitnessDay
+	if n := len(seed); n > 0 && seed[n-1].CTLDays == s.config.FitnessCTLDays && seed[n-1].ATLDays == s.config.FitnessATLDays {
+		previous = seed[n-1]
+	} else {
+		from = time.Unix(0, 0).In(loc)
+	}
+
+	activities, err := s.influxClient.GetActivities(ctx, from, today.AddDate(0, 0, 1))
+	if err != nil {
+		slog.Error("Failed to load activities for fitness", "error", err)
+		return
+	}
+
+	if previous.Date.IsZero() {
+		// Start from scratch on the day of the first activity
+		var first time.Time
+		for _, activity := range activities {
+			if !activity.Deleted && (first.IsZero() || activity.StartDate.Before(first)) {
+				first = activity.StartDate
+			}
+		}
+		if first.IsZero() {
+			return
+		}
+		from = strava.GetDayStart(first.In(loc))
+	}
+
+	days := strava.CalculateFitness(activities, previous, from, today, s.config.FitnessCTLDays, s.config.FitnessATLDays)
+	if err := s.influxClient.WriteFitness(ctx, days); err != nil {
+		slog.Error("Failed to write fitness", "error", err)
+		return
+	}
+
+	latest := days[len(days)-1]
+	slog.Info("Fitness updated", "from", from, "days", len(days), "ctl", latest.CTL, "atl", latest.ATL, "tsb", latest.TSB)
+}
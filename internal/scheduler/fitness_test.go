@@ -0,0 +1,72 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"stravaDataImporter/internal/strava"
+)
+
+func TestImportUpdatesFitness(t *testing.T) {
+	s, _, store := newFakeStravaScheduler(t)
+
+	if _, err := s.Reconcile(context.Background(), time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	days, _ := store.GetFitness(context.Background(), time.Unix(0, 0), time.Now().Add(24*time.Hour))
+	if len(days) == 0 {
+		t.Fatal("no fitness written after the import")
+	}
+
+	ride := store.activities[1001]
+	first := strava.GetDayStart(store.activities[1003].StartDate.In(time.Local))
+	if !days[0].Date.Equal(first) {
+		t.Errorf("fitness starts on %v, want the day of the first activity %v", days[0].Date, first)
+	}
+	today := strava.GetDayStart(time.Now())
+	if last := days[len(days)-1]; !last.Date.Equal(today) {
+		t.Errorf("fitness ends on %v, want today %v", last.Date, today)
+	}
+
+	var rideDay *strava.FitnessDay
+	for i := range days {
+		if days[i].Date.Equal(strava.GetDayStart(ride.StartDate.In(time.Local))) {
+			rideDay = &days[i]
+		}
+	}
+	if rideDay == nil || rideDay.TSS < ride.TSS || rideDay.CTL <= 0 || rideDay.ATL <= rideDay.CTL {
+		t.Errorf("fitness on the ride day = %+v, want the ride TSS and ATL above CTL", rideDay)
+	}
+	if last := days[len(days)-1]; last.CTL >= rideDay.CTL {
+		t.Errorf("CTL today = %v, want it to have decayed from %v", last.CTL, rideDay.CTL)
+	}
+}
+
+func TestUpdateFitnessContinuesFromPreviousDay(t *testing.T) {
+	s, _, store := newFakeStravaScheduler(t)
+	today := strava.GetDayStart(time.Now())
+	yesterday := today.AddDate(0, 0, -1)
+
+	store.activities[1] = &strava.ActivityData{ID: 1, StartDate: today.Add(8 * time.Hour), TSS: 100}
+	_ = store.WriteFitness(context.Background(), []strava.FitnessDay{{Date: yesterday, CTL: 50, ATL: 70, CTLDays: 42, ATLDays: 7}})
+
+	s.updateFitness(context.Background(), today)
+
+	days, _ := store.GetFitness(context.Background(), today, today.AddDate(0, 0, 1))
+	if len(days) != 1 {
+		t.Fatalf("got %d fitness days for today, want 1", len(days))
+	}
+	if days[0].TSB != -20 || days[0].CTL <= 50 || days[0].ATL <= 70 {
+		t.Errorf("today = %+v, want TSB -20 and rising CTL and ATL", days[0])
+	}
+
+	// Changed time constants recompute from the first activity
+	s.config.FitnessCTLDays = 28
+	s.updateFitness(context.Background(), today)
+	days, _ = store.GetFitness(context.Background(), today, today.AddDate(0, 0, 1))
+	if days[0].CTLDays != 28 || days[0].TSB != 0 {
+		t.Errorf("today = %+v, want a fresh series with CTL over 28 days", days[0])
+	}
+}
@@ -0,0 +1,209 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"stravaDataImporter/internal/strava"
+)
+
+// reloadFTP reads the FTP file again when it changed since it was last read
+// and applies the new history to the stored activities
+func (s *Scheduler) reloadFTP(ctx context.Context) {
+	s.ftpMu.Lock()
+	defer s.ftpMu.Unlock()
+
+	info, err := os.Stat(s.config.FTPFilePath)
+	if err != nil || info.ModTime().Equal(s.ftpModTime) {
+		return
+	}
+	if err := s.ftpManager.LoadFTPData(); err != nil {
+		slog.Warn("Failed to reload FTP data", "error", err)
+		return
+	}
+	s.ftpModTime = info.ModTime()
+
+	s.applyFTPHistory(ctx)
+}
+
+// applyFTPHistory recalculates IF and TSS of the stored activities whose FTP
+// no longer matches the FTP history, e.g. after a test result was added
+func (s *Scheduler) applyFTPHistory(ctx context.Context) {
+	activities, err := s.influxClient.GetActivities(ctx, time.Unix(0, 0), time.Now().Add(time.Hour))
+	if err != nil {
+		slog.Error("Failed to load activities for FTP history", "error", err)
+		return
+	}
+
+	var changed []time.Time
+	for i := range activities {
+		activity := &activities[i]
+		if activity.Deleted || activity.NP <= 0 {
+			continue
+		}
+		ftp := s.ftpManager.GetFTPForDate(activity.StartDate)
+		if ftp <= 0 || ftp == activity.FTP {
+			continue
+		}
+
+		previousFTP := activity.FTP
+		strava.ApplyFTP(activity, ftp)
+		if err := s.influxClient.WriteActivity(ctx, activity); err != nil {
+			slog.Error("Failed to rewrite activity with new FTP", "activity_id", activity.ID, "error", err)
+			continue
+		}
+		slog.Debug("Applied FTP to activity", "activity_id", activity.ID, "previous_ftp", previousFTP, "ftp", ftp, "tss", activity.TSS)
+		changed = append(changed, activity.StartDate)
+	}
+
+	if len(changed) > 0 {
+		slog.Info("Applied FTP history to stored activities", "activities", len(changed))
+		s.activitiesChanged(ctx, changed)
+	}
+}
+
+// suggestFTP proposes a new FTP when the best efforts or the critical power
+// fit of the last CPWindowDays days exceed the current FTP. Evidence close to
+// a pending suggestion, or not above a decided suggestion from the same
+// source, is not proposed again.
+func (s *Scheduler) suggestFTP(ctx context.Context) {
+	loc := s.config.AthleteLocation()
+	today := strava.GetDayStart(time.Now().In(loc))
+	start := today.AddDate(0, 0, 1-s.config.CPWindowDays)
+	end := today.AddDate(0, 0, 1)
+
+	curves, err := s.influxClient.GetPowerCurves(ctx, start, end)
+	if err != nil {
+		slog.Error("Failed to load power curves for FTP suggestion", "error", err)
+		return
+	}
+	var fit *strava.CriticalPower
+	if fits, err := s.influxClient.GetCriticalPower(ctx, today, end); err != nil {
+		slog.Warn("Failed to load critical power for FTP suggestion", "error", err)
+	} else if len(fits) > 0 {
+		fit = &fits[len(fits)-1]
+	}
+
+	suggestion := strava.SuggestFTP(strava.BestPowerCurve(curves, start, end), fit, s.ftpManager.GetFTPForDate(time.Now()), loc)
+	if suggestion == nil {
+		return
+	}
+
+	existing, err := s.influxClient.GetFTPSuggestions(ctx)
+	if err != nil {
+		slog.Error("Failed to load FTP suggestions", "error", err)
+		return
+	}
+	for _, previous := range existing {
+		if previous.Status == strava.FTPSuggestionPending && suggestion.FTP < previous.FTP*(1+strava.FTPSuggestionMinIncrease) {
+			return
+		}
+		// The CP fit is dated today, so its evidence gets a new date every
+		// day; a decided suggestion covers anything up to its FTP
+		if previous.Status != strava.FTPSuggestionPending && previous.Source == suggestion.Source && suggestion.FTP <= previous.FTP {
+			return
+		}
+	}
+
+	if err := s.influxClient.WriteFTPSuggestion(ctx, suggestion); err != nil {
+		slog.Error("Failed to write FTP suggestion", "error", err)
+		return
+	}
+	for i := range existing {
+		if previous := &existing[i]; previous.Status == strava.FTPSuggestionPending && previous.ID != suggestion.ID {
+			previous.Status = strava.FTPSuggestionSuperseded
+			if err := s.influxClient.WriteFTPSuggestion(ctx, previous); err != nil {
+				slog.Error("Failed to supersede FTP suggestion", "id", previous.ID, "error", err)
+			}
+		}
+	}
+
+	slog.Info("New FTP suggested", "id", suggestion.ID, "ftp", suggestion.FTP, "current_ftp", suggestion.CurrentFTP, "source", suggestion.Source)
+}
+
+// PendingFTPSuggestions returns the FTP suggestions awaiting a decision
+func (s *Scheduler) PendingFTPSuggestions(ctx context.Context) ([]strava.FTPSuggestion, error) {
+	suggestions, err := s.influxClient.GetFTPSuggestions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load FTP suggestions: %w", err)
+	}
+	pending := []strava.FTPSuggestion{}
+	for _, suggestion := range suggestions {
+		if suggestion.Status == strava.FTPSuggestionPending {
+			pending = append(pending, suggestion)
+		}
+	}
+	return pending, nil
+}
+
+// AcceptFTPSuggestion appends a pending suggestion to the FTP file and
+// recalculates the TSS of the activities it applies to
+func (s *Scheduler) AcceptFTPSuggestion(ctx context.Context, id string) (*strava.FTPSuggestion, error) {
+	ctx, end, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	suggestion, err := s.pendingFTPSuggestion(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.ftpMu.Lock()
+	defer s.ftpMu.Unlock()
+
+	if err := s.ftpManager.AppendRecord(suggestion.Date, suggestion.FTP); err != nil {
+		return nil, fmt.Errorf("failed to add FTP to history: %w", err)
+	}
+	// The file changed on our own behalf; remember it so the next reload
+	// does not apply the history a second time
+	if info, err := os.Stat(s.config.FTPFilePath); err == nil {
+		s.ftpModTime = info.ModTime()
+	}
+
+	suggestion.Status = strava.FTPSuggestionAccepted
+	if err := s.influxClient.WriteFTPSuggestion(ctx, suggestion); err != nil {
+		return nil, fmt.Errorf("failed to update FTP suggestion: %w", err)
+	}
+	slog.Info("FTP suggestion accepted", "id", suggestion.ID, "date", suggestion.Date, "ftp", suggestion.FTP)
+
+	s.applyFTPHistory(ctx)
+	return suggestion, nil
+}
+
+// DismissFTPSuggestion rejects a pending suggestion
+func (s *Scheduler) DismissFTPSuggestion(ctx context.Context, id string) (*strava.FTPSuggestion, error) {
+	suggestion, err := s.pendingFTPSuggestion(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestion.Status = strava.FTPSuggestionDismissed
+	if err := s.influxClient.WriteFTPSuggestion(ctx, suggestion); err != nil {
+		return nil, fmt.Errorf("failed to update FTP suggestion: %w", err)
+	}
+	slog.Info("FTP suggestion dismissed", "id", suggestion.ID, "ftp", suggestion.FTP)
+	return suggestion, nil
+}
+
+// pendingFTPSuggestion looks up a suggestion that is still awaiting a decision
+func (s *Scheduler) pendingFTPSuggestion(ctx context.Context, id string) (*strava.FTPSuggestion, error) {
+	suggestions, err := s.influxClient.GetFTPSuggestions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load FTP suggestions: %w", err)
+	}
+	for i := range suggestions {
+		if suggestions[i].ID != id {
+			continue
+		}
+		if suggestions[i].Status != strava.FTPSuggestionPending {
+			return nil, strava.ErrFTPSuggestionNotPending
+		}
+		return &suggestions[i], nil
+	}
+	return nil, strava.ErrFTPSuggestionNotFound
+}
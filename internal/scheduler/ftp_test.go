@@ -0,0 +1,175 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"stravaDataImporter/internal/strava"
+)
+
+func TestReloadFTPAppliesHistory(t *testing.T) {
+	s, _, store := newFakeStravaScheduler(t)
+
+	if _, err := s.Reconcile(context.Background(), time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	s.reloadFTP(context.Background())
+
+	ride := *store.activities[1001]
+	if ride.FTP != 250 {
+		t.Fatalf("ride FTP = %v, want 250 before the FTP file changes", ride.FTP)
+	}
+	week := strava.GetWeekStart(ride.StartDate.In(time.Local))
+	before := *findWeeklySummary(store, week)
+
+	// A test result from before the ride raises its FTP
+	ftpFile := s.config.FTPFilePath
+	if err := os.WriteFile(ftpFile, []byte("date,ftp\n2020-01-01,250\n2024-06-01,300\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write FTP file: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(ftpFile, later, later); err != nil {
+		t.Fatalf("Failed to touch FTP file: %v", err)
+	}
+	s.reloadFTP(context.Background())
+
+	updated := store.activities[1001]
+	wantTSS := ride.TSS * (250.0 / 300.0) * (250.0 / 300.0)
+	if updated.FTP != 300 || math.Abs(updated.TSS-wantTSS) > 1e-9 || math.Abs(updated.IF-ride.NP/300) > 1e-9 {
+		t.Errorf("ride FTP = %v, TSS = %v, IF = %v, want 300, %v and %v", updated.FTP, updated.TSS, updated.IF, wantTSS, ride.NP/300)
+	}
+	if run := store.activities[1002]; run.NP > 0 && run.FTP != 300 {
+		t.Errorf("run FTP = %v, want 300", run.FTP)
+	}
+
+	if after := findWeeklySummary(store, week); after.TotalTSS >= before.TotalTSS {
+		t.Errorf("weekly TSS = %v, want it recomputed below %v", after.TotalTSS, before.TotalTSS)
+	}
+}
+
+func TestSuggestAndAcceptFTP(t *testing.T) {
+	s, fakeStrava, store := newFakeStravaScheduler(t)
+	fakeStrava.ShiftActivities(time.Now().Add(-3 * time.Hour))
+	s.importDataJob(context.Background())
+	if len(store.ftpSuggestions) != 0 {
+		t.Fatalf("suggestions = %+v, want none below the current FTP", store.ftpSuggestions)
+	}
+	ride := *store.activities[1001]
+
+	// A 300 W 20 minute effort yesterday is evidence for an FTP of 285
+	yesterday := strava.GetDayStart(time.Now()).AddDate(0, 0, -1)
+	watts := make([]float64, len(strava.PowerCurveDurations))
+	watts[8] = 300
+	_ = store.WritePowerCurve(context.Background(), &strava.PowerCurve{ActivityID: 99, StartDate: yesterday.Add(8 * time.Hour), Watts: watts})
+
+	s.suggestFTP(context.Background())
+	s.suggestFTP(context.Background())
+
+	pending, err := s.PendingFTPSuggestions(context.Background())
+	if err != nil {
+		t.Fatalf("PendingFTPSuggestions() error = %v", err)
+	}
+	if len(pending) != 1 {
+		t.Fatalf("pending = %+v, want one suggestion", pending)
+	}
+	suggestion := pending[0]
+	if suggestion.FTP != 285 || suggestion.CurrentFTP != 250 || suggestion.Source != strava.FTPSourceTwentyMinute || suggestion.ActivityID != 99 || !suggestion.Date.Equal(yesterday) {
+		t.Errorf("suggestion = %+v, want 285 W from the 20 minute effort of activity 99 yesterday", suggestion)
+	}
+
+	if _, err := s.AcceptFTPSuggestion(context.Background(), suggestion.ID); err != nil {
+		t.Fatalf("AcceptFTPSuggestion() error = %v", err)
+	}
+
+	content, _ := os.ReadFile(s.config.FTPFilePath)
+	if want := yesterday.Format("2006-01-02") + ",285\n"; !strings.HasSuffix(string(content), want) {
+		t.Errorf("FTP file = %q, want it to end with %q", content, want)
+	}
+	updated := store.activities[1001]
+	wantTSS := ride.TSS * (250.0 / 285.0) * (250.0 / 285.0)
+	if updated.FTP != 285 || math.Abs(updated.TSS-wantTSS) > 1e-9 {
+		t.Errorf("ride FTP = %v, TSS = %v, want 285 and %v", updated.FTP, updated.TSS, wantTSS)
+	}
+
+	if _, err := s.AcceptFTPSuggestion(context.Background(), suggestion.ID); !errors.Is(err, strava.ErrFTPSuggestionNotPending) {
+		t.Errorf("AcceptFTPSuggestion() twice error = %v, want ErrFTPSuggestionNotPending", err)
+	}
+	if _, err := s.DismissFTPSuggestion(context.Background(), "unknown"); !errors.Is(err, strava.ErrFTPSuggestionNotFound) {
+		t.Errorf("DismissFTPSuggestion() error = %v, want ErrFTPSuggestionNotFound", err)
+	}
+
+	// The accepted FTP is now current, so the same evidence is not proposed again
+	s.suggestFTP(context.Background())
+	if pending, _ := s.PendingFTPSuggestions(context.Background()); len(pending) != 0 {
+		t.Errorf("pending = %+v, want none after accepting", pending)
+	}
+}
+
+func TestSuggestFTPAfterDismissal(t *testing.T) {
+	s, _, store := newFakeStravaScheduler(t)
+
+	// A 300 W 20 minute effort is evidence for an FTP of 285
+	yesterday := strava.GetDayStart(time.Now()).AddDate(0, 0, -1)
+	watts := make([]float64, len(strava.PowerCurveDurations))
+	watts[8] = 300
+	_ = store.WritePowerCurve(context.Background(), &strava.PowerCurve{ActivityID: 99, StartDate: yesterday.Add(8 * time.Hour), Watts: watts})
+	s.suggestFTP(context.Background())
+
+	pending, _ := s.PendingFTPSuggestions(context.Background())
+	if len(pending) != 1 {
+		t.Fatalf("pending = %+v, want one suggestion", pending)
+	}
+	if _, err := s.DismissFTPSuggestion(context.Background(), pending[0].ID); err != nil {
+		t.Fatalf("DismissFTPSuggestion() error = %v", err)
+	}
+
+	// The dismissed evidence is not proposed again
+	s.suggestFTP(context.Background())
+	if pending, _ := s.PendingFTPSuggestions(context.Background()); len(pending) != 0 {
+		t.Fatalf("pending = %+v, want none for the dismissed evidence", pending)
+	}
+
+	// A slightly better effort today still beats the current FTP of 250
+	better := make([]float64, len(strava.PowerCurveDurations))
+	better[8] = 305
+	_ = store.WritePowerCurve(context.Background(), &strava.PowerCurve{ActivityID: 100, StartDate: strava.GetDayStart(time.Now()), Watts: better})
+	s.suggestFTP(context.Background())
+	pending, _ = s.PendingFTPSuggestions(context.Background())
+	if len(pending) != 1 || pending[0].FTP != 290 || pending[0].ActivityID != 100 {
+		t.Errorf("pending = %+v, want 290 W from activity 100 despite the dismissed 285 W", pending)
+	}
+}
+
+func TestSuggestFTPSkipsDismissedCriticalPower(t *testing.T) {
+	s, _, store := newFakeStravaScheduler(t)
+
+	// Yesterday's CP suggestion of 280 W was dismissed
+	today := strava.GetDayStart(time.Now().In(s.config.AthleteLocation()))
+	dismissed := strava.FTPSuggestion{
+		ID:     today.AddDate(0, 0, -1).Format("2006-01-02") + "-" + strava.FTPSourceCriticalPower,
+		Date:   today.AddDate(0, 0, -1),
+		FTP:    280,
+		Source: strava.FTPSourceCriticalPower,
+		Status: strava.FTPSuggestionDismissed,
+	}
+	_ = store.WriteFTPSuggestion(context.Background(), &dismissed)
+
+	// Today's fit carries the same evidence under a new date
+	_ = store.WriteCriticalPower(context.Background(), &strava.CriticalPower{Date: today, CP: 280, WPrime: 20000})
+	s.suggestFTP(context.Background())
+	if pending, _ := s.PendingFTPSuggestions(context.Background()); len(pending) != 0 {
+		t.Fatalf("pending = %+v, want the dismissed CP not proposed again", pending)
+	}
+
+	// A higher fit is proposed
+	_ = store.WriteCriticalPower(context.Background(), &strava.CriticalPower{Date: today, CP: 290, WPrime: 20000})
+	s.suggestFTP(context.Background())
+	if pending, _ := s.PendingFTPSuggestions(context.Background()); len(pending) != 1 || pending[0].FTP != 290 {
+		t.Errorf("pending = %+v, want 290 W from the higher fit", pending)
+	}
+}
@@ -0,0 +1,136 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"stravaDataImporter/internal/strava"
+)
+
+// syncGear updates the cumulative usage of every piece of gear used by the
+// imported activities and sends a reminder each time a maintenance item
+// covers another whole interval since the last sync
+func (s *Scheduler) syncGear(ctx context.Context) {
+	s.reloadMaintenance()
+
+	activities, err := s.influxClient.GetActivities(ctx, time.Unix(0, 0), time.Now().Add(time.Hour))
+	if err != nil {
+		slog.Error("Failed to load activities for gear sync", "error", err)
+		return
+	}
+
+	stats := s.gearStats(ctx, activities)
+	if len(stats) == 0 {
+		return
+	}
+	if err := s.influxClient.WriteGearStats(ctx, stats); err != nil {
+		slog.Error("Failed to write gear stats", "error", err)
+		return
+	}
+
+	statuses := s.maintenanceManager.Evaluate(stats, activities)
+	if len(statuses) == 0 {
+		slog.Info("Gear synced", "gear", len(stats))
+		return
+	}
+
+	previous, err := s.influxClient.GetMaintenanceStatus(ctx)
+	if err != nil {
+		// Without the previous state every due item would be reported again
+		slog.Error("Failed to load maintenance status", "error", err)
+		return
+	}
+	reported := make(map[string]int, len(previous))
+	for _, status := range previous {
+		intervals := status.Intervals
+		if status.Due && intervals == 0 {
+			// Recorded before intervals were counted; the first was reported
+			intervals = 1
+		}
+		reported[status.GearID+"/"+status.Item] = intervals
+	}
+
+	if err := s.influxClient.WriteMaintenanceStatus(ctx, statuses); err != nil {
+		slog.Error("Failed to write maintenance status", "error", err)
+		return
+	}
+
+	for i := range statuses {
+		status := &statuses[i]
+		if !status.Due || status.Intervals <= reported[status.GearID+"/"+status.Item] {
+			continue
+		}
+		slog.Info("Gear maintenance due", "gear_id", status.GearID, "gear", status.GearName, "item", status.Item, "distance_km", status.DistanceKm, "interval_km", status.IntervalKm, "intervals", status.Intervals)
+		if err := s.notifier.PostMaintenanceReminder(status); err != nil {
+			slog.Error("Failed to post maintenance reminder", "gear_id", status.GearID, "item", status.Item, "error", err)
+		}
+	}
+
+	slog.Info("Gear synced", "gear", len(stats), "maintenance_items", len(statuses))
+}
+
+// reloadMaintenance reads the maintenance file again when it changed, was
+// added or was removed since it was last read
+func (s *Scheduler) reloadMaintenance() {
+	s.maintenanceMu.Lock()
+	defer s.maintenanceMu.Unlock()
+
+	var modTime time.Time
+	info, err := os.Stat(s.config.MaintenanceFilePath)
+	if err == nil {
+		modTime = info.ModTime()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("Failed to check maintenance file", "error", err)
+		return
+	}
+	if modTime.Equal(s.maintenanceModTime) {
+		return
+	}
+	if err := s.maintenanceManager.LoadMaintenanceData(); err != nil {
+		slog.Warn("Failed to reload maintenance data", "error", err)
+		return
+	}
+	s.maintenanceModTime = modTime
+}
+
+// gearStats totals the moving time and activity count per gear from the
+// stored activities and adds the name and total distance from Strava. The
+// summed activity distance is used when Strava cannot be reached.
+func (s *Scheduler) gearStats(ctx context.Context, activities []strava.ActivityData) []strava.GearStats {
+	byID := make(map[string]*strava.GearStats)
+	for _, activity := range strava.UniqueActivities(activities) {
+		if activity.GearID == "" {
+			continue
+		}
+		stats, ok := byID[activity.GearID]
+		if !ok {
+			stats = &strava.GearStats{GearID: activity.GearID, Kind: strava.GearKind(activity.GearID)}
+			byID[activity.GearID] = stats
+		}
+		stats.Distance += activity.Distance
+		stats.MovingTime += activity.MovingTime
+		stats.ActivityCount++
+	}
+
+	result := make([]strava.GearStats, 0, len(byID))
+	for _, stats := range byID {
+		gear, err := s.stravaClient.GetGear(ctx, stats.GearID)
+		if err != nil {
+			slog.Warn("Failed to fetch gear", "gear_id", stats.GearID, "error", err)
+			stats.Name = stats.GearID
+		} else {
+			stats.Name = gear.Name
+			stats.Distance = gear.Distance
+			stats.Retired = gear.Retired
+		}
+		result = append(result, *stats)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].GearID < result[j].GearID })
+	return result
+}
@@ -0,0 +1,128 @@
+package scheduler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"stravaDataImporter/internal/gear"
+	"stravaDataImporter/internal/strava"
+)
+
+func TestSyncGearWithFakeStrava(t *testing.T) {
+	s, fakeStrava, store := newFakeStravaScheduler(t)
+	notifier := &recordingNotifier{}
+	s.notifier = notifier
+
+	maintenanceFile := filepath.Join(t.TempDir(), "maintenance.csv")
+	rules := "gear,item,interval_km,last_service\nb1111,chain,3000,2024-06-01\nPegasus,shoes,5,2024-06-01\n"
+	if err := os.WriteFile(maintenanceFile, []byte(rules), 0o644); err != nil {
+		t.Fatalf("Failed to write maintenance file: %v", err)
+	}
+	s.config.MaintenanceFilePath = maintenanceFile
+	s.maintenanceManager = gear.NewMaintenanceManager(maintenanceFile, time.UTC)
+
+	if _, err := s.Reconcile(context.Background(), time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	s.syncGear(context.Background())
+
+	if len(store.gearStats) != 1 || len(store.gearStats[0]) != 2 {
+		t.Fatalf("gear stats writes = %+v, want one write for two pieces of gear", store.gearStats)
+	}
+	bike := store.gearStats[0][0]
+	if bike.GearID != "b1111" || bike.Name != "Tarmac" || bike.Kind != "bike" || bike.Distance != 2985000 || bike.MovingTime != 600 || bike.ActivityCount != 1 {
+		t.Errorf("bike stats = %+v", bike)
+	}
+	if shoes := store.gearStats[0][1]; shoes.GearID != "g2222" || shoes.Kind != "shoes" {
+		t.Errorf("shoe stats = %+v", shoes)
+	}
+
+	if len(notifier.reminders) != 1 || notifier.reminders[0].Item != "shoes" {
+		t.Fatalf("reminders = %+v, want one for the shoes", notifier.reminders)
+	}
+	if len(store.maintenance) != 2 || store.maintenance[0].Due || !store.maintenance[1].Due {
+		t.Errorf("maintenance status = %+v, want the chain not due and the shoes due", store.maintenance)
+	}
+
+	// The shoes are still due, which was already reported
+	s.syncGear(context.Background())
+	if len(notifier.reminders) != 1 {
+		t.Errorf("reminders = %d after the second sync, want 1", len(notifier.reminders))
+	}
+
+	// Covering a second interval is reported again
+	fakeStrava.UpdateActivity(1002, map[string]any{"distance": 10100.0})
+	if _, err := s.Reconcile(context.Background(), time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	s.syncGear(context.Background())
+	if len(notifier.reminders) != 2 || notifier.reminders[1].Intervals != 2 {
+		t.Errorf("reminders = %+v, want a second one for the second interval", notifier.reminders)
+	}
+	s.syncGear(context.Background())
+	if len(notifier.reminders) != 2 {
+		t.Errorf("reminders = %d after syncing the same distance, want 2", len(notifier.reminders))
+	}
+}
+
+func TestSyncGearReloadsMaintenanceFile(t *testing.T) {
+	s, _, store := newFakeStravaScheduler(t)
+	notifier := &recordingNotifier{}
+	s.notifier = notifier
+
+	maintenanceFile := filepath.Join(t.TempDir(), "maintenance.csv")
+	s.config.MaintenanceFilePath = maintenanceFile
+	s.maintenanceManager = gear.NewMaintenanceManager(maintenanceFile, time.UTC)
+
+	if _, err := s.Reconcile(context.Background(), time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	s.syncGear(context.Background())
+	if len(store.maintenance) != 0 || len(notifier.reminders) != 0 {
+		t.Fatalf("maintenance status = %+v, want none without a maintenance file", store.maintenance)
+	}
+
+	// A rule added while running is used by the next sync
+	if err := os.WriteFile(maintenanceFile, []byte("gear,item,interval_km\nPegasus,shoes,5\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write maintenance file: %v", err)
+	}
+	s.syncGear(context.Background())
+	if len(notifier.reminders) != 1 || notifier.reminders[0].Item != "shoes" {
+		t.Fatalf("reminders = %+v, want one for the shoes", notifier.reminders)
+	}
+
+	// So is a changed rule
+	if err := os.WriteFile(maintenanceFile, []byte("gear,item,interval_km\nPegasus,insoles,1\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write maintenance file: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(maintenanceFile, later, later); err != nil {
+		t.Fatalf("Failed to touch maintenance file: %v", err)
+	}
+	s.syncGear(context.Background())
+	if len(notifier.reminders) != 2 || notifier.reminders[1].Item != "insoles" {
+		t.Fatalf("reminders = %+v, want a second one for the insoles", notifier.reminders)
+	}
+
+	// Removing the file disables the reminders again
+	if err := os.Remove(maintenanceFile); err != nil {
+		t.Fatalf("Failed to remove maintenance file: %v", err)
+	}
+	s.syncGear(context.Background())
+	if rules := s.maintenanceManager.GetAllRules(); len(rules) != 0 {
+		t.Errorf("rules = %+v after removing the file, want none", rules)
+	}
+}
+
+func TestGearStatsCountsRepeatedSeriesOnce(t *testing.T) {
+	s, _, _ := newFakeStravaScheduler(t)
+
+	activity := strava.ActivityData{ID: 1002, GearID: "g2222", Distance: 5020, MovingTime: 600}
+	stats := s.gearStats(context.Background(), []strava.ActivityData{activity, activity})
+	if len(stats) != 1 || stats[0].ActivityCount != 1 || stats[0].MovingTime != 600 {
+		t.Errorf("gear stats = %+v, want the repeated series counted once", stats)
+	}
+}
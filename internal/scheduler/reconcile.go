@@ -0,0 +1,246 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"stravaDataImporter/internal/strava"
+)
+
+// reconcilePageSize is the number of activities listed per Strava request
+const reconcilePageSize = 200
+
+// Reconcile compares the activities stored since the given time with Strava.
+// Missing activities are imported, activities edited on Strava are rewritten
+// and activities that no longer exist on Strava are marked as deleted.
+func (s *Scheduler) Reconcile(ctx context.Context, since time.Time) (*strava.ReconcileReport, error) {
+	ctx, end, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	token, err := s.tokenStore.LoadToken(ctx)
+	if err != nil || token == nil {
+		return nil, fmt.Errorf("no token found for reconciliation")
+	}
+
+	// An incomplete listing would tombstone activities that still exist,
+	// so any error aborts the run before anything is written
+	upstream, err := s.listActivitiesSince(ctx, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list activities: %w", err)
+	}
+
+	stored, err := s.influxClient.GetActivities(ctx, since, time.Now().Add(time.Hour))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stored activities: %w", err)
+	}
+	storedByID := make(map[int64][]strava.ActivityData)
+	for _, activity := range stored {
+		storedByID[activity.ID] = append(storedByID[activity.ID], activity)
+	}
+
+	report := &strava.ReconcileReport{Since: since, Checked: len(upstream)}
+
+	// The start dates of every added, edited or deleted activity, including
+	// the periods that moved activities leave, are recomputed once at the end
+	var changed []time.Time
+	defer func() { s.activitiesChanged(ctx, changed) }()
+
+	upstreamIDs := make(map[int64]bool, len(upstream))
+	for _, activity := range upstream {
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+		upstreamIDs[activity.ID] = true
+
+		series, ok := storedByID[activity.ID]
+		if !ok {
+			activityData, err := s.importActivity(ctx, activity, true, false)
+			if err != nil {
+				slog.Error("Failed to import missing activity", "activity_id", activity.ID, "error", err)
+				report.Failed = append(report.Failed, activity.ID)
+				continue
+			}
+			report.Added = append(report.Added, activity.ID)
+			changed = append(changed, activityData.StartDate)
+			continue
+		}
+
+		fields := changedFields(series, activity)
+		if len(fields) == 0 {
+			continue
+		}
+		activityData, err := s.replaceActivity(ctx, activity, series, true, false)
+		if err != nil {
+			slog.Error("Failed to rewrite edited activity", "activity_id", activity.ID, "error", err)
+			report.Failed = append(report.Failed, activity.ID)
+			continue
+		}
+		report.Updated = append(report.Updated, strava.ActivityChange{ID: activity.ID, Name: activity.Name, Fields: fields})
+		changed = append(changed, activityData.StartDate, series[0].StartDate)
+	}
+
+	for id, series := range storedByID {
+		if upstreamIDs[id] || series[0].Deleted {
+			continue
+		}
+		if err := s.influxClient.MarkActivityDeleted(ctx, id); err != nil {
+			slog.Error("Failed to mark activity as deleted", "activity_id", id, "error", err)
+			report.Failed = append(report.Failed, id)
+			continue
+		}
+		report.Deleted = append(report.Deleted, id)
+		changed = append(changed, series[0].StartDate)
+	}
+
+	slog.Info("Reconciliation completed",
+		"since", since,
+		"checked", report.Checked,
+		"added", len(report.Added),
+		"updated", len(report.Updated),
+		"deleted", len(report.Deleted),
+		"failed", len(report.Failed))
+	return report, nil
+}
+
+// reconcileJob reconciles the configured number of recent days
+func (s *Scheduler) reconcileJob(ctx context.Context) {
+	slog.Info("Starting reconcile job")
+
+	since := time.Now().AddDate(0, 0, -s.config.ReconcileDays)
+	if _, err := s.Reconcile(ctx, since); err != nil {
+		slog.Error("Reconcile job failed", "error", err)
+	}
+}
+
+// listActivitiesSince pages through all activities that started after since
+func (s *Scheduler) listActivitiesSince(ctx context.Context, since time.Time) ([]strava.StravaActivity, error) {
+	var activities []strava.StravaActivity
+	for page := 1; ; page++ {
+		batch, err := s.stravaClient.ListActivities(ctx, strava.ActivityListOptions{After: since, Page: page, PerPage: reconcilePageSize})
+		if err != nil {
+			return nil, err
+		}
+		activities = append(activities, batch...)
+		if len(batch) < reconcilePageSize {
+			return activities, nil
+		}
+	}
+}
+
+// replaceActivity writes the current state of an activity over its stored
+// series and then removes what the rewrite left stale. Nothing is removed
+// until the new points are written, so a failed fetch or write keeps the
+// stored activity. The caller recomputes the periods of the old and new
+// start dates.
+func (s *Scheduler) replaceActivity(ctx context.Context, activity strava.StravaActivity, stored []strava.ActivityData, deferrable, announce bool) (*strava.ActivityData, error) {
+	activityData, err := s.importActivity(ctx, activity, deferrable, announce)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.removeStaleSeries(ctx, activityData, stored); err != nil {
+		return nil, err
+	}
+	return activityData, nil
+}
+
+// removeStaleSeries removes the stored points of an activity that its
+// rewrite did not overwrite: the series tagged with a previous activity type
+// or the legacy name tag and the points of the time span the activity no
+// longer covers
+func (s *Scheduler) removeStaleSeries(ctx context.Context, current *strava.ActivityData, stored []strava.ActivityData) error {
+	if len(stored) > 1 {
+		if err := s.influxClient.DeleteLegacyActivitySeries(ctx, current.ID); err != nil {
+			return fmt.Errorf("failed to remove legacy series of activity %d: %w", current.ID, err)
+		}
+	}
+
+	removedTypes := make(map[string]bool)
+	for _, old := range stored {
+		if old.Type != current.Type && !removedTypes[old.Type] {
+			removedTypes[old.Type] = true
+			if err := s.influxClient.DeleteActivityPoints(ctx, current.ID, old.Type, time.Unix(0, 0), time.Now().Add(time.Hour)); err != nil {
+				return fmt.Errorf("failed to remove stale series of activity %d: %w", current.ID, err)
+			}
+		}
+
+		for _, span := range subtractSpan(activitySpan(&old), activitySpan(current)) {
+			if err := s.influxClient.DeleteActivityPoints(ctx, current.ID, "", span[0], span[1]); err != nil {
+				return fmt.Errorf("failed to remove stale points of activity %d: %w", current.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// activitySpan is the time range [start, stop) holding the points of an
+// activity, from its start through its last stream sample
+func activitySpan(activity *strava.ActivityData) [2]time.Time {
+	return [2]time.Time{activity.StartDate, activity.StartDate.Add(time.Duration(activity.ElapsedTime+1) * time.Second)}
+}
+
+// subtractSpan returns the parts of span a not covered by span b
+func subtractSpan(a, b [2]time.Time) [][2]time.Time {
+	if !b[0].Before(a[1]) || !a[0].Before(b[1]) {
+		return [][2]time.Time{a}
+	}
+	var spans [][2]time.Time
+	if a[0].Before(b[0]) {
+		spans = append(spans, [2]time.Time{a[0], b[0]})
+	}
+	if b[1].Before(a[1]) {
+		spans = append(spans, [2]time.Time{b[1], a[1]})
+	}
+	return spans
+}
+
+// changedFields lists the fields in which the stored series of an activity
+// differ from its current state on Strava
+func changedFields(stored []strava.ActivityData, activity strava.StravaActivity) []string {
+	current := stored[0]
+	var fields []string
+	if len(stored) > 1 {
+		fields = append(fields, "series")
+	}
+	if current.Deleted {
+		fields = append(fields, "deleted")
+	}
+	if current.Name != activity.Name {
+		fields = append(fields, "name")
+	}
+	if current.Type != activity.Type {
+		fields = append(fields, "type")
+	}
+	if !nearlyEqual(current.Distance, activity.Distance) {
+		fields = append(fields, "distance")
+	}
+	if current.MovingTime != activity.MovingTime {
+		fields = append(fields, "moving_time")
+	}
+	if current.ElapsedTime != activity.ElapsedTime {
+		fields = append(fields, "elapsed_time")
+	}
+	if !nearlyEqual(current.TotalElevationGain, activity.TotalElevationGain) {
+		fields = append(fields, "total_elevation_gain")
+	}
+	if current.GearID != activity.GearID {
+		fields = append(fields, "gear_id")
+	}
+	// Also rewrites series stored before the timezone was kept
+	if current.Timezone != activity.Timezone {
+		fields = append(fields, "timezone")
+	}
+	if startDate, err := time.Parse(time.RFC3339, activity.StartDate); err == nil && !startDate.Equal(current.StartDate) {
+		fields = append(fields, "start_date")
+	}
+	return fields
+}
+
+func nearlyEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
@@ -0,0 +1,243 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestReconcileWithFakeStrava(t *testing.T) {
+	s, fakeStrava, store := newFakeStravaScheduler(t)
+	since := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	report, err := s.Reconcile(context.Background(), since)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if report.Checked != 3 || len(report.Added) != 3 {
+		t.Fatalf("first run checked %d and added %v, want all 3 fixture activities", report.Checked, report.Added)
+	}
+	if store.rollingWrites != 1 {
+		t.Errorf("first run recomputed %d times, want once for all added activities", store.rollingWrites)
+	}
+
+	fakeStrava.UpdateActivity(1001, map[string]any{"name": "Renamed Ride"})
+	fakeStrava.UpdateActivity(1002, map[string]any{"type": "TrailRun"})
+	fakeStrava.DeleteActivity(1003)
+
+	report, err = s.Reconcile(context.Background(), since)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if len(report.Added) != 0 || len(report.Failed) != 0 {
+		t.Errorf("second run added %v and failed %v, want none", report.Added, report.Failed)
+	}
+	if len(report.Updated) != 2 {
+		t.Fatalf("second run updated %+v, want activities 1001 and 1002", report.Updated)
+	}
+	if store.rollingWrites != 2 {
+		t.Errorf("second run recomputed %d times, want once for all changes", store.rollingWrites-1)
+	}
+	for _, change := range report.Updated {
+		switch change.ID {
+		case 1001:
+			if !slices.Equal(change.Fields, []string{"name"}) {
+				t.Errorf("changed fields of 1001 = %v, want [name]", change.Fields)
+			}
+		case 1002:
+			if !slices.Equal(change.Fields, []string{"type"}) {
+				t.Errorf("changed fields of 1002 = %v, want [type]", change.Fields)
+			}
+		default:
+			t.Errorf("unexpected update of activity %d", change.ID)
+		}
+	}
+	if !slices.Equal(report.Deleted, []int64{1003}) {
+		t.Errorf("deleted = %v, want [1003]", report.Deleted)
+	}
+
+	if name := store.activities[1001].Name; name != "Renamed Ride" {
+		t.Errorf("stored name = %q, want %q", name, "Renamed Ride")
+	}
+	if activityType := store.activities[1002].Type; activityType != "TrailRun" {
+		t.Errorf("stored type = %q, want %q", activityType, "TrailRun")
+	}
+	if store.streams[1001] == nil {
+		t.Error("streams of the rewritten ride were not imported again")
+	}
+	if !store.activities[1003].Deleted {
+		t.Error("activity 1003 should be marked as deleted")
+	}
+	if len(store.yearlySummary) != 1 || store.yearlySummary[0].ActivityCount != 2 {
+		t.Errorf("yearly summaries = %+v, want one year without the deleted activity", store.yearlySummary)
+	}
+
+	// Nothing changed since the last run, so nothing is written
+	report, err = s.Reconcile(context.Background(), since)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if len(report.Added)+len(report.Updated)+len(report.Deleted)+len(report.Failed) != 0 {
+		t.Errorf("third run = %+v, want no changes", report)
+	}
+}
+
+func TestReconcileKeepsActivitiesWhenListingFails(t *testing.T) {
+	s, fakeStrava, store := newFakeStravaScheduler(t)
+	since := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	if _, err := s.Reconcile(context.Background(), since); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	// Without a valid token the listing fails; stored activities must not
+	// be taken for deleted ones
+	fakeStrava.ExpireAccessTokens()
+	s.tokenStore.SetRefresher(nil)
+	if _, err := s.Reconcile(context.Background(), since); err == nil {
+		t.Fatal("Reconcile() should fail when Strava cannot be listed")
+	}
+	if len(store.deleted) != 0 {
+		t.Errorf("deleted = %v, want none", store.deleted)
+	}
+}
+
+func TestImportActivityReplacesStoredSeries(t *testing.T) {
+	s, fakeStrava, store := newFakeStravaScheduler(t)
+
+	if err := s.ImportActivity(context.Background(), 1002, true); err != nil {
+		t.Fatalf("ImportActivity() error = %v", err)
+	}
+	fakeStrava.UpdateActivity(1002, map[string]any{"type": "TrailRun"})
+	if err := s.ImportActivity(context.Background(), 1002, true); err != nil {
+		t.Fatalf("ImportActivity() error = %v", err)
+	}
+
+	if activityType := store.activities[1002].Type; activityType != "TrailRun" {
+		t.Errorf("stored type = %q, want %q", activityType, "TrailRun")
+	}
+	if store.rollingWrites != 2 {
+		t.Errorf("recomputed %d times, want once per webhook import", store.rollingWrites)
+	}
+	// Only the series of the previous type is removed
+	if len(store.staleDeletes) != 1 || store.staleDeletes[0].activityID != 1002 || store.staleDeletes[0].activityType != "Run" {
+		t.Errorf("stale deletes = %+v, want the Run series of 1002 only", store.staleDeletes)
+	}
+}
+
+func TestImportActivityPostsOnlyFirstImport(t *testing.T) {
+	s, fakeStrava, _ := newFakeStravaScheduler(t)
+	notifier := &recordingNotifier{}
+	s.notifier = notifier
+
+	if err := s.ImportActivity(context.Background(), 1002, true); err != nil {
+		t.Fatalf("ImportActivity() error = %v", err)
+	}
+
+	// Updates and repeated create events rewrite the activity silently
+	fakeStrava.UpdateActivity(1002, map[string]any{"name": "Renamed Run"})
+	if err := s.ImportActivity(context.Background(), 1002, false); err != nil {
+		t.Fatalf("ImportActivity() error = %v", err)
+	}
+	if err := s.ImportActivity(context.Background(), 1002, true); err != nil {
+		t.Fatalf("ImportActivity() error = %v", err)
+	}
+	if _, err := s.Reconcile(context.Background(), time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	// Stop waits for the posts in flight
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if posted := notifier.postedActivities(); len(posted) != 1 || posted[0] != 1002 {
+		t.Errorf("posted = %v, want 1002 once", posted)
+	}
+}
+
+func TestDeleteActivityConfirmsWithStrava(t *testing.T) {
+	s, fakeStrava, store := newFakeStravaScheduler(t)
+
+	// A forged event for an activity that still exists is ignored
+	if err := s.DeleteActivity(context.Background(), 1002); err != nil {
+		t.Fatalf("DeleteActivity() error = %v", err)
+	}
+	if len(store.deleted) != 0 {
+		t.Fatalf("deleted = %v, want none while Strava still has the activity", store.deleted)
+	}
+
+	fakeStrava.DeleteActivity(1002)
+	if err := s.DeleteActivity(context.Background(), 1002); err != nil {
+		t.Fatalf("DeleteActivity() error = %v", err)
+	}
+	if len(store.deleted) != 1 || store.deleted[0] != 1002 {
+		t.Errorf("deleted = %v, want 1002 once Strava no longer has it", store.deleted)
+	}
+}
+
+func TestRevokeAuthorizationConfirmsWithStrava(t *testing.T) {
+	s, fakeStrava, _ := newFakeStravaScheduler(t)
+	athleteID := fakeStrava.AthleteID()
+
+	// A forged deauthorization keeps the token Strava still accepts
+	if err := s.RevokeAuthorization(context.Background(), athleteID); err != nil {
+		t.Fatalf("RevokeAuthorization() error = %v", err)
+	}
+	if token, _ := s.tokenStore.LoadToken(context.Background()); token == nil {
+		t.Fatal("token cleared for a forged deauthorization")
+	}
+
+	fakeStrava.RevokeAuthorization()
+	if err := s.RevokeAuthorization(context.Background(), athleteID); err != nil {
+		t.Fatalf("RevokeAuthorization() error = %v", err)
+	}
+	if token, _ := s.tokenStore.LoadToken(context.Background()); token != nil {
+		t.Errorf("token = %+v, want it cleared once Strava rejects it", token)
+	}
+}
+
+func TestImportActivityKeepsStoredSeriesWhenWriteFails(t *testing.T) {
+	s, fakeStrava, store := newFakeStravaScheduler(t)
+
+	if err := s.ImportActivity(context.Background(), 1002, true); err != nil {
+		t.Fatalf("ImportActivity() error = %v", err)
+	}
+	fakeStrava.UpdateActivity(1002, map[string]any{"type": "TrailRun"})
+	store.writeErr = errors.New("write failed")
+	if err := s.ImportActivity(context.Background(), 1002, true); err == nil {
+		t.Fatal("ImportActivity() error = nil, want the write error")
+	}
+
+	if activity := store.activities[1002]; activity == nil || activity.Type != "Run" {
+		t.Errorf("stored activity = %+v, want the previous Run", activity)
+	}
+	if len(store.staleDeletes) != 0 {
+		t.Errorf("stale deletes = %+v, want none after a failed rewrite", store.staleDeletes)
+	}
+}
+
+func TestSubtractSpan(t *testing.T) {
+	at := func(seconds int) time.Time { return time.Unix(int64(seconds), 0) }
+	span := func(start, stop int) [2]time.Time { return [2]time.Time{at(start), at(stop)} }
+
+	tests := []struct {
+		name string
+		a, b [2]time.Time
+		want [][2]time.Time
+	}{
+		{"same span", span(0, 100), span(0, 100), nil},
+		{"cropped end", span(0, 100), span(0, 60), [][2]time.Time{span(60, 100)}},
+		{"cropped start", span(0, 100), span(30, 100), [][2]time.Time{span(0, 30)}},
+		{"moved apart", span(0, 100), span(200, 300), [][2]time.Time{span(0, 100)}},
+		{"extended", span(10, 50), span(0, 100), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := subtractSpan(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("subtractSpan() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"stravaDataImporter/internal/strava"
+)
+
+// updateRollingSummaries recomputes the rolling summaries of the windows
+// ending today from the stored activities and stores them as today's values
+func (s *Scheduler) updateRollingSummaries(ctx context.Context) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	end := strava.GetDayStart(time.Now().In(s.config.AthleteLocation())).AddDate(0, 0, 1)
+	longest := 0
+	for _, days := range strava.RollingSummaryDays {
+		longest = max(longest, days)
+	}
+
+	activities, err := s.influxClient.GetActivities(ctx, end.AddDate(0, 0, -longest), end)
+	if err != nil {
+		slog.Error("Failed to load activities for rolling summaries", "error", err)
+		return
+	}
+
+	summaries := make([]strava.RollingSummary, 0, len(strava.RollingSummaryDays))
+	for _, days := range strava.RollingSummaryDays {
+		summaries = append(summaries, strava.CalculateRollingSummary(activities, end, days))
+	}
+	if err := s.influxClient.WriteRollingSummaries(ctx, summaries); err != nil {
+		slog.Error("Failed to write rolling summaries", "error", err)
+		return
+	}
+
+	slog.Info("Rolling summaries updated", "windows", len(summaries))
+}
@@ -0,0 +1,36 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestImportUpdatesRollingSummaries(t *testing.T) {
+	s, fakeStrava, store := newFakeStravaScheduler(t)
+	fakeStrava.ShiftActivities(time.Now().Add(-3 * time.Hour))
+
+	s.importDataJob(context.Background())
+
+	if len(store.activities) == 0 {
+		t.Fatal("no activities were imported")
+	}
+	var distance float64
+	for _, activity := range store.activities {
+		distance += activity.Distance
+	}
+
+	for _, days := range []int{7, 28, 90, 365} {
+		summary, ok := store.rolling[days]
+		if !ok {
+			t.Errorf("no %d day rolling summary after the import", days)
+			continue
+		}
+		if summary.ActivityCount != len(store.activities) || summary.TotalDistance != distance || summary.TotalTSS <= 0 {
+			t.Errorf("%d day summary = %+v, want the %d imported activities", days, summary, len(store.activities))
+		}
+		if !summary.Start.Equal(summary.End.AddDate(0, 0, -days)) || summary.End.Before(time.Now()) {
+			t.Errorf("%d day summary covers %v to %v, want the window ending today", days, summary.Start, summary.End)
+		}
+	}
+}
@@ -1,51 +1,164 @@
 package scheduler
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 
 	"stravaDataImporter/internal/auth"
 	"stravaDataImporter/internal/config"
-	"stravaDataImporter/internal/db"
 	"stravaDataImporter/internal/ftp"
+	"stravaDataImporter/internal/gear"
 	"stravaDataImporter/internal/strava"
+	"stravaDataImporter/internal/threshold"
 	"stravaDataImporter/internal/twitter"
+	"stravaDataImporter/internal/weight"
 
 	"github.com/robfig/cron/v3"
 )
 
+// ActivityStore is the subset of the InfluxDB client used by the scheduler
+type ActivityStore interface {
+	WriteActivity(ctx context.Context, activity *strava.ActivityData) error
+	WriteActivityStreams(ctx context.Context, activity *strava.ActivityData, streams *strava.ActivityStreams) error
+	WritePowerCurve(ctx context.Context, curve *strava.PowerCurve) error
+	GetPowerCurves(ctx context.Context, start, end time.Time) ([]strava.PowerCurve, error)
+	WriteCriticalPower(ctx context.Context, fit *strava.CriticalPower) error
+	GetCriticalPower(ctx context.Context, start, end time.Time) ([]strava.CriticalPower, error)
+	WriteWeight(ctx context.Context, record *strava.WeightRecord) error
+	GetWeights(ctx context.Context) ([]strava.WeightRecord, error)
+	WriteFTPSuggestion(ctx context.Context, suggestion *strava.FTPSuggestion) error
+	GetFTPSuggestions(ctx context.Context) ([]strava.FTPSuggestion, error)
+	WriteLaps(ctx context.Context, laps []strava.LapData) error
+	WriteSegmentEfforts(ctx context.Context, efforts []strava.SegmentEffortData) error
+	MarkActivityDeleted(ctx context.Context, activityID int64) error
+	GetActivitySeries(ctx context.Context, activityID int64) ([]strava.ActivityData, error)
+	DeleteActivityPoints(ctx context.Context, activityID int64, activityType string, start, stop time.Time) error
+	DeleteLegacyActivitySeries(ctx context.Context, activityID int64) error
+	GetActivities(ctx context.Context, start, end time.Time) ([]strava.ActivityData, error)
+	WriteWeeklySummary(ctx context.Context, summary *strava.WeeklySummary) error
+	WriteMonthlySummary(ctx context.Context, summary *strava.MonthlySummary) error
+	WriteYearlySummary(ctx context.Context, summary *strava.YearlySummary) error
+	WriteRollingSummaries(ctx context.Context, summaries []strava.RollingSummary) error
+	SaveBackfillState(ctx context.Context, state *strava.BackfillState) error
+	LoadBackfillState(ctx context.Context) (*strava.BackfillState, error)
+	WriteGearStats(ctx context.Context, stats []strava.GearStats) error
+	WriteMaintenanceStatus(ctx context.Context, statuses []strava.MaintenanceStatus) error
+	GetMaintenanceStatus(ctx context.Context) ([]strava.MaintenanceStatus, error)
+	WriteAthleteZones(ctx context.Context, zones *strava.AthleteZones) error
+	LoadAthleteZones(ctx context.Context) (*strava.AthleteZones, error)
+	WriteFitness(ctx context.Context, days []strava.FitnessDay) error
+	GetFitness(ctx context.Context, start, end time.Time) ([]strava.FitnessDay, error)
+	WriteWorkload(ctx context.Context, days []strava.WorkloadDay) error
+	GetWorkload(ctx context.Context, start, end time.Time) ([]strava.WorkloadDay, error)
+}
+
+// Notifier publishes new activities, gear maintenance reminders and
+// overload alerts
+type Notifier interface {
+	PostActivity(activity *strava.ActivityData) error
+	PostMaintenanceReminder(status *strava.MaintenanceStatus) error
+	PostOverloadAlert(alert *strava.OverloadAlert) error
+}
+
+// errStopped is returned for work submitted after Stop was called
+var errStopped = errors.New("scheduler is stopped")
+
 type Scheduler struct {
-	config        *config.Config
-	cron          *cron.Cron
-	stravaClient  *strava.Client
-	tokenStore    *auth.TokenStore
-	ftpManager    *ftp.FTPManager
-	influxClient  *db.InfluxDBClient
-	twitterClient *twitter.Client
+	config             *config.Config
+	cron               *cron.Cron
+	stravaClient       *strava.Client
+	tokenStore         *auth.TokenStore
+	ftpManager         *ftp.FTPManager
+	thresholdManager   *threshold.ThresholdManager
+	weightManager      *weight.WeightManager
+	maintenanceManager *gear.MaintenanceManager
+	influxClient       ActivityStore
+	notifier           Notifier
+
+	// ftpModTime is the modification time of the FTP file when it was last
+	// applied to the stored activities
+	ftpMu      sync.Mutex
+	ftpModTime time.Time
+
+	// maintenanceModTime is the modification time of the maintenance file
+	// when it was last read; zero while the file does not exist
+	maintenanceMu      sync.Mutex
+	maintenanceModTime time.Time
+
+	// zones caches the athlete zones synced from Strava
+	zonesMu       sync.Mutex
+	zones         *strava.AthleteZones
+	zonesSyncedAt time.Time
+
+	// weightSyncedAt is when the Strava profile weight was last fetched;
+	// weightsLoaded is set once the stored Strava weights were added
+	weightMu       sync.Mutex
+	weightSyncedAt time.Time
+	weightsLoaded  bool
+
+	// ctx is cancelled by Stop to abort running jobs; wg tracks them
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	stopping bool
 }
 
-func New(cfg *config.Config, influxClient *db.InfluxDBClient) *Scheduler {
+func New(cfg *config.Config, influxClient ActivityStore, tokenStore *auth.TokenStore) *Scheduler {
+	stravaClient := strava.NewClient(cfg)
+	if tokenStore != nil {
+		stravaClient.SetTokenSource(tokenStore)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &Scheduler{
-		config:        cfg,
-		cron:          cron.New(cron.WithSeconds()),
-		stravaClient:  strava.NewClient(cfg),
-		tokenStore:    auth.NewTokenStore(influxClient),
-		ftpManager:    ftp.NewFTPManager(cfg.FTPFilePath),
-		influxClient:  influxClient,
-		twitterClient: twitter.NewClient(cfg),
+		ctx:                ctx,
+		cancel:             cancel,
+		config:             cfg,
+		cron:               cron.New(cron.WithSeconds(), cron.WithLocation(cfg.AthleteLocation())),
+		stravaClient:       stravaClient,
+		tokenStore:         tokenStore,
+		ftpManager:         ftp.NewFTPManager(cfg.FTPFilePath, cfg.AthleteLocation()),
+		thresholdManager:   threshold.NewThresholdManager(cfg.ThresholdFilePath, cfg.AthleteLocation()),
+		weightManager:      weight.NewWeightManager(cfg.WeightFilePath, cfg.AthleteLocation()),
+		maintenanceManager: gear.NewMaintenanceManager(cfg.MaintenanceFilePath, cfg.AthleteLocation()),
+		influxClient:       influxClient,
+		notifier:           twitter.NewClient(cfg),
 	}
 }
 
-func (s *Scheduler) Start() {
+// Start schedules the jobs. Running jobs are cancelled when ctx is done.
+func (s *Scheduler) Start(ctx context.Context) {
 	slog.Info("Starting scheduler")
+	context.AfterFunc(ctx, s.cancel)
 
 	// Load FTP data on startup
 	if err := s.ftpManager.LoadFTPData(); err != nil {
 		slog.Warn("Failed to load FTP data", "error", err)
 	}
 
+	// Load heart rate thresholds on startup
+	if err := s.thresholdManager.LoadThresholdData(); err != nil {
+		slog.Warn("Failed to load threshold data", "error", err)
+	}
+
+	// Load body weights on startup
+	if err := s.weightManager.LoadWeightData(); err != nil {
+		slog.Warn("Failed to load weight data", "error", err)
+	}
+
+	// Load gear maintenance intervals on startup
+	if err := s.maintenanceManager.LoadMaintenanceData(); err != nil {
+		slog.Warn("Failed to load maintenance data", "error", err)
+	}
+
 	// Schedule token refresh using config
-	_, err := s.cron.AddFunc(s.config.TokenRefreshCron, s.refreshTokenJob)
+	_, err := s.cron.AddFunc(s.config.TokenRefreshCron, s.cronJob(s.refreshTokenJob))
 	if err != nil {
 		slog.Error("Failed to schedule token refresh job", "error", err, "cron", s.config.TokenRefreshCron)
 	} else {
@@ -53,15 +166,31 @@ func (s *Scheduler) Start() {
 	}
 
 	// Schedule data import using config
-	_, err = s.cron.AddFunc(s.config.DataImportCron, s.importDataJob)
+	_, err = s.cron.AddFunc(s.config.DataImportCron, s.cronJob(s.importDataJob))
 	if err != nil {
 		slog.Error("Failed to schedule data import job", "error", err, "cron", s.config.DataImportCron)
 	} else {
 		slog.Info("Scheduled data import job", "cron", s.config.DataImportCron)
 	}
 
+	// Schedule reconciliation with Strava using config
+	_, err = s.cron.AddFunc(s.config.ReconcileCron, s.cronJob(s.reconcileJob))
+	if err != nil {
+		slog.Error("Failed to schedule reconcile job", "error", err, "cron", s.config.ReconcileCron)
+	} else {
+		slog.Info("Scheduled reconcile job", "cron", s.config.ReconcileCron)
+	}
+
+	// Schedule the daily fitness update using config
+	_, err = s.cron.AddFunc(s.config.FitnessCron, s.cronJob(s.fitnessJob))
+	if err != nil {
+		slog.Error("Failed to schedule fitness job", "error", err, "cron", s.config.FitnessCron)
+	} else {
+		slog.Info("Scheduled fitness job", "cron", s.config.FitnessCron)
+	}
+
 	// Schedule weekly summary calculation using config
-	_, err = s.cron.AddFunc(s.config.WeeklySummaryCron, s.calculateWeeklySummaryJob)
+	_, err = s.cron.AddFunc(s.config.WeeklySummaryCron, s.cronJob(s.calculateWeeklySummaryJob))
 	if err != nil {
 		slog.Error("Failed to schedule weekly summary job", "error", err, "cron", s.config.WeeklySummaryCron)
 	} else {
@@ -69,7 +198,7 @@ func (s *Scheduler) Start() {
 	}
 
 	// Schedule monthly summary calculation using config
-	_, err = s.cron.AddFunc(s.config.MonthlySummaryCron, s.calculateMonthlySummaryJob)
+	_, err = s.cron.AddFunc(s.config.MonthlySummaryCron, s.cronJob(s.calculateMonthlySummaryJob))
 	if err != nil {
 		slog.Error("Failed to schedule monthly summary job", "error", err, "cron", s.config.MonthlySummaryCron)
 	} else {
@@ -77,7 +206,7 @@ func (s *Scheduler) Start() {
 	}
 
 	// Schedule yearly summary calculation using config
-	_, err = s.cron.AddFunc(s.config.YearlySummaryCron, s.calculateYearlySummaryJob)
+	_, err = s.cron.AddFunc(s.config.YearlySummaryCron, s.cronJob(s.calculateYearlySummaryJob))
 	if err != nil {
 		slog.Error("Failed to schedule yearly summary job", "error", err, "cron", s.config.YearlySummaryCron)
 	} else {
@@ -86,40 +215,99 @@ func (s *Scheduler) Start() {
 
 	s.cron.Start()
 	slog.Info("Scheduler started successfully")
+
+	// Walk the full activity history in the background when requested
+	if s.config.BackfillOnStartup {
+		go s.cronJob(s.backfillJob)()
+	}
 }
 
-func (s *Scheduler) Stop() {
+// Stop cancels running jobs and waits for them to return until ctx is done
+func (s *Scheduler) Stop(ctx context.Context) error {
 	slog.Info("Stopping scheduler")
-	s.cron.Stop()
+
+	s.mu.Lock()
+	s.stopping = true
+	s.mu.Unlock()
+
+	cronDone := s.cron.Stop()
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		<-cronDone.Done()
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		slog.Info("Scheduler stopped")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("timed out waiting for running jobs: %w", ctx.Err())
+	}
+}
+
+// begin registers running work with the scheduler. The returned context is
+// cancelled when either ctx or the scheduler is stopped; end must be called
+// once the work has finished.
+func (s *Scheduler) begin(ctx context.Context) (context.Context, func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopping {
+		return nil, nil, errStopped
+	}
+	s.wg.Add(1)
+
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(s.ctx, cancel)
+	end := func() {
+		stop()
+		cancel()
+		s.wg.Done()
+	}
+	return ctx, end, nil
+}
+
+// cronJob adapts a job to the cron scheduler
+func (s *Scheduler) cronJob(job func(ctx context.Context)) func() {
+	return func() {
+		ctx, end, err := s.begin(s.ctx)
+		if err != nil {
+			return
+		}
+		defer end()
+		job(ctx)
+	}
 }
 
-func (s *Scheduler) refreshTokenJob() {
+func (s *Scheduler) refreshTokenJob(ctx context.Context) {
 	slog.Info("Starting token refresh job")
 
-	token, err := s.tokenStore.LoadToken()
+	token, err := s.tokenStore.LoadToken(ctx)
 	if err != nil || token == nil {
 		slog.Warn("No token found for refresh")
 		return
 	}
 
-	newToken, err := s.stravaClient.RefreshToken(token.RefreshToken)
-	if err != nil {
+	// Tokens are also refreshed on demand before they expire; this job keeps
+	// the refresh token rotating while no API calls are made
+	if _, err := s.tokenStore.Refresh(ctx, token); err != nil {
 		slog.Error("Failed to refresh token", "error", err)
 		return
 	}
 
-	if err := s.tokenStore.SaveToken(newToken); err != nil {
-		slog.Error("Failed to save refreshed token", "error", err)
-		return
-	}
-
 	slog.Info("Token refreshed successfully")
 }
 
-func (s *Scheduler) importDataJob() {
+func (s *Scheduler) importDataJob(ctx context.Context) {
 	slog.Info("Starting data import job")
 
-	token, err := s.tokenStore.LoadToken()
+	s.reloadFTP(ctx)
+
+	token, err := s.tokenStore.LoadToken(ctx)
 	if err != nil || token == nil {
 		slog.Warn("No token found for data import")
 		return
@@ -127,7 +315,7 @@ func (s *Scheduler) importDataJob() {
 
 	// Get activities from the last 2 days to ensure we don't miss any
 	since := time.Now().AddDate(0, 0, -2)
-	activities, err := s.stravaClient.GetActivities(token.AccessToken, since, 200)
+	activities, err := s.stravaClient.GetActivities(ctx, since, 200)
 	if err != nil {
 		slog.Error("Failed to fetch activities", "error", err)
 		return
@@ -135,122 +323,500 @@ func (s *Scheduler) importDataJob() {
 
 	slog.Info("Fetched activities", "count", len(activities))
 
-	for _, activity := range activities {
-		ftp := s.ftpManager.GetFTPForDate(time.Now()) // Use current FTP for simplicity
+	if s.importActivities(ctx, activities, false, true) > 0 {
+		s.syncGear(ctx)
+	}
+
+	usage := s.stravaClient.RateLimiter().Usage()
+	slog.Info("Data import job completed", "rate_limit_short_usage", usage.ShortUsage, "rate_limit_daily_usage", usage.DailyUsage)
+}
+
+// ImportActivity fetches a single activity and imports it immediately.
+// It is used for activities announced by the Strava webhook; created marks
+// a create event, which is posted when the activity was not stored yet.
+func (s *Scheduler) ImportActivity(ctx context.Context, activityID int64, created bool) error {
+	ctx, end, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	token, err := s.tokenStore.LoadToken(ctx)
+	if err != nil || token == nil {
+		return fmt.Errorf("no token found for activity import")
+	}
+
+	activity, err := s.stravaClient.GetActivity(ctx, activityID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch activity %d: %w", activityID, err)
+	}
+
+	stored, err := s.influxClient.GetActivitySeries(ctx, activityID)
+	if err != nil {
+		return fmt.Errorf("failed to load stored activity %d: %w", activityID, err)
+	}
+	activityData, err := s.replaceActivity(ctx, *activity, stored, false, created && len(stored) == 0)
+	if err != nil {
+		return err
+	}
+	changed := []time.Time{activityData.StartDate}
+	for _, series := range stored {
+		changed = append(changed, series.StartDate)
+	}
+	s.activitiesChanged(ctx, changed)
+	if activity.GearID != "" {
+		s.syncGear(ctx)
+	}
+
+	slog.Info("Activity imported from webhook event", "activity_id", activityID)
+	return nil
+}
+
+// DeleteActivity marks an activity deleted on Strava as deleted in InfluxDB
+func (s *Scheduler) DeleteActivity(ctx context.Context, activityID int64) error {
+	ctx, end, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	// Delete events arrive on a public route; only trust them once Strava
+	// no longer knows the activity
+	if _, err := s.stravaClient.GetActivity(ctx, activityID); err == nil {
+		slog.Warn("Ignoring delete event for an activity that still exists", "activity_id", activityID)
+		return nil
+	} else if !errors.Is(err, strava.ErrActivityNotFound) {
+		return fmt.Errorf("failed to confirm activity deletion: %w", err)
+	}
+
+	return s.influxClient.MarkActivityDeleted(ctx, activityID)
+}
 
-		activityData, err := strava.ConvertToActivityData(activity, ftp)
+// RevokeAuthorization clears the stored token after the athlete
+// deauthorized the application on Strava
+func (s *Scheduler) RevokeAuthorization(ctx context.Context, athleteID int64) error {
+	ctx, end, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	token, err := s.tokenStore.LoadToken(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load token: %w", err)
+	}
+	if token != nil && token.AthleteID != 0 && token.AthleteID != athleteID {
+		slog.Warn("Ignoring deauthorization for another athlete", "athlete_id", athleteID, "token_athlete_id", token.AthleteID)
+		return nil
+	}
+
+	// Only clear the token once Strava rejects it as well
+	if _, err := s.stravaClient.GetAthlete(ctx); err == nil {
+		slog.Warn("Ignoring deauthorization while the token is still accepted", "athlete_id", athleteID)
+		return nil
+	} else if !errors.Is(err, strava.ErrUnauthorized) {
+		return fmt.Errorf("failed to confirm deauthorization: %w", err)
+	}
+
+	if err := s.tokenStore.ClearToken(ctx); err != nil {
+		return err
+	}
+
+	slog.Info("Authorization revoked by athlete", "athlete_id", athleteID)
+	return nil
+}
+
+// backfillJob imports the athlete's whole activity history, resuming from the
+// last saved cursor when a previous run was interrupted
+func (s *Scheduler) backfillJob(ctx context.Context) {
+	slog.Info("Starting backfill job")
+
+	token, err := s.tokenStore.LoadToken(ctx)
+	if err != nil || token == nil {
+		slog.Warn("No token found for backfill")
+		return
+	}
+
+	state, err := s.influxClient.LoadBackfillState(ctx)
+	if err != nil {
+		slog.Error("Failed to load backfill state", "error", err)
+		return
+	}
+	if state == nil {
+		state = &strava.BackfillState{}
+	}
+	if state.Completed {
+		slog.Info("Backfill already completed, skipping", "imported", state.Imported)
+		return
+	}
+	if !state.Before.IsZero() {
+		slog.Info("Resuming backfill", "before", state.Before, "imported", state.Imported)
+	}
+
+	if err := s.waitForBackgroundBudget(ctx); err != nil {
+		slog.Info("Backfill cancelled", "error", err)
+		return
+	}
+	err = s.stravaClient.BackfillActivities(ctx, state.Before, s.config.BackfillPageSize, func(activities []strava.StravaActivity, next time.Time) error {
+		state.Imported += s.importActivities(ctx, activities, true, false)
+		if err := ctx.Err(); err != nil {
+			// The page may be incomplete, so keep the previous cursor
+			return err
+		}
+		state.Before = next
+		if err := s.influxClient.SaveBackfillState(ctx, state); err != nil {
+			return err
+		}
+		return s.waitForBackgroundBudget(ctx)
+	})
+	if err != nil {
+		slog.Error("Backfill stopped", "error", err, "before", state.Before, "imported", state.Imported)
+		return
+	}
+
+	state.Completed = true
+	if err := s.influxClient.SaveBackfillState(ctx, state); err != nil {
+		slog.Error("Failed to save backfill state", "error", err)
+	}
+	s.syncGear(ctx)
+
+	slog.Info("Backfill job completed", "imported", state.Imported)
+}
+
+// importActivities converts and writes activities to InfluxDB and returns
+// the number written successfully. Deferrable imports wait for rate limit
+// headroom before fetching streams. With announceNew the activities that
+// were not stored before are posted. It stops early when ctx is cancelled.
+func (s *Scheduler) importActivities(ctx context.Context, activities []strava.StravaActivity, deferrable, announceNew bool) int {
+	var stored map[int64]bool
+	if announceNew {
+		stored = s.storedActivityIDs(ctx, activities)
+	}
+
+	imported := 0
+	var startDates []time.Time
+	defer func() { s.activitiesChanged(ctx, startDates) }()
+	for i, activity := range activities {
+		if ctx.Err() != nil {
+			slog.Info("Import cancelled", "imported", imported, "remaining", len(activities)-i)
+			break
+		}
+
+		activityData, err := s.importActivity(ctx, activity, deferrable, stored != nil && !stored[activity.ID])
 		if err != nil {
-			slog.Error("Failed to convert activity data", "activity_id", activity.ID, "error", err)
+			if ctx.Err() != nil {
+				slog.Info("Import cancelled", "imported", imported, "remaining", len(activities)-i)
+				break
+			}
+			slog.Error("Failed to import activity", "activity_id", activity.ID, "error", err)
 			continue
 		}
+		imported++
+		startDates = append(startDates, activityData.StartDate)
+	}
+	return imported
+}
 
-		if err := s.influxClient.WriteActivity(activityData); err != nil {
-			slog.Error("Failed to write activity to InfluxDB", "activity_id", activity.ID, "error", err)
+// storedActivityIDs returns the IDs of the given activities that are already
+// stored, or nil when they cannot be loaded so that nothing is posted twice
+func (s *Scheduler) storedActivityIDs(ctx context.Context, activities []strava.StravaActivity) map[int64]bool {
+	var start, end time.Time
+	for _, activity := range activities {
+		startDate, err := time.Parse(time.RFC3339, activity.StartDate)
+		if err != nil {
+			continue
+		}
+		if start.IsZero() || startDate.Before(start) {
+			start = startDate
+		}
+		if end.IsZero() || startDate.After(end) {
+			end = startDate
 		}
+	}
+	if start.IsZero() {
+		return nil
+	}
+
+	existing, err := s.influxClient.GetActivities(ctx, start, end.Add(time.Second))
+	if err != nil {
+		slog.Error("Failed to load stored activities, not posting this import", "error", err)
+		return nil
+	}
+	stored := make(map[int64]bool, len(existing))
+	for _, activity := range existing {
+		stored[activity.ID] = true
+	}
+	return stored
+}
 
-		// Post to Twitter for new activities (within last hour)
-		if activityData.StartDate.After(time.Now().Add(-1 * time.Hour)) {
-			go s.postToTwitter(activityData)
+// importActivity fetches the details and streams of an activity, converts it
+// and writes it to InfluxDB, overwriting the points stored for it before.
+// With announce the activity is posted once it is written.
+func (s *Scheduler) importActivity(ctx context.Context, activity strava.StravaActivity, deferrable, announce bool) (*strava.ActivityData, error) {
+	// Summaries from the activity list carry no laps or segment efforts
+	if s.config.ImportLaps && !activity.Manual && activity.Laps == nil {
+		if deferrable {
+			if err := s.waitForBackgroundBudget(ctx); err != nil {
+				return nil, err
+			}
+		}
+		if detailed, err := s.stravaClient.GetActivity(ctx, activity.ID); err != nil {
+			slog.Error("Failed to fetch detailed activity", "activity_id", activity.ID, "error", err)
+		} else {
+			activity = *detailed
 		}
 	}
 
-	slog.Info("Data import job completed")
+	// Apply the FTP that was valid when the activity took place
+	ftpDate := time.Now()
+	if startDate, err := time.Parse(time.RFC3339, activity.StartDate); err == nil {
+		ftpDate = startDate
+	}
+	ftp := s.ftpManager.GetFTPForDate(ftpDate)
+
+	activityData, err := strava.ConvertToActivityData(activity, ftp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert activity %d: %w", activity.ID, err)
+	}
+
+	var streams *strava.ActivityStreams
+	if s.config.ImportStreams && !activity.Manual {
+		if deferrable {
+			if err := s.waitForBackgroundBudget(ctx); err != nil {
+				return nil, err
+			}
+		}
+		streams = s.fetchStreams(ctx, activity.ID)
+		strava.ApplyPowerStream(activityData, streams)
+		strava.ApplyAerobicMetrics(activityData, streams)
+		if streams != nil {
+			strava.ApplyZones(activityData, streams, s.athleteZones(ctx))
+		}
+		if streams != nil && len(streams.Watts) > 0 {
+			if fit := s.criticalPowerAt(ctx, activityData.StartDate); fit != nil {
+				strava.ApplyWPrimeBalance(activityData, streams, fit.CP, fit.WPrime)
+			}
+		}
+	}
+
+	// Runs and swims are scored by pace; heart rate based load covers
+	// activities without a power or pace based TSS
+	thresholds := s.thresholdManager.GetThresholdsForDate(ftpDate)
+	strava.ApplyPaceLoad(activityData, streams, thresholds)
+	strava.ApplyHeartRateLoad(activityData, streams, thresholds)
+	strava.ApplyWeight(activityData, s.weightForDate(ctx, ftpDate))
+
+	if err := s.influxClient.WriteActivity(ctx, activityData); err != nil {
+		return nil, fmt.Errorf("failed to write activity %d: %w", activity.ID, err)
+	}
+
+	if streams != nil && streams.Len() > 0 {
+		if err := s.influxClient.WriteActivityStreams(ctx, activityData, streams); err != nil {
+			slog.Error("Failed to write activity streams to InfluxDB", "activity_id", activity.ID, "error", err)
+		}
+	}
+	if streams != nil && len(streams.Watts) > 0 {
+		curve := &strava.PowerCurve{
+			ActivityID: activityData.ID,
+			StartDate:  activityData.StartDate,
+			Watts:      strava.CalculatePowerCurve(streams.Watts, streams.Time),
+			Weight:     activityData.Weight,
+		}
+		if err := s.influxClient.WritePowerCurve(ctx, curve); err != nil {
+			slog.Error("Failed to write power curve to InfluxDB", "activity_id", activity.ID, "error", err)
+		}
+	}
+
+	if len(activity.Laps) > 0 {
+		if err := s.influxClient.WriteLaps(ctx, strava.ConvertLaps(activity, ftp, streams)); err != nil {
+			slog.Error("Failed to write laps to InfluxDB", "activity_id", activity.ID, "error", err)
+		}
+	}
+	if len(activity.SegmentEfforts) > 0 {
+		if err := s.influxClient.WriteSegmentEfforts(ctx, strava.ConvertSegmentEfforts(activity)); err != nil {
+			slog.Error("Failed to write segment efforts to InfluxDB", "activity_id", activity.ID, "error", err)
+		}
+	}
+
+	if announce {
+		s.postToTwitter(activityData)
+	}
+	return activityData, nil
+}
+
+// waitForBackgroundBudget holds back non-urgent work while the Strava rate
+// limit usage is above the configured threshold
+func (s *Scheduler) waitForBackgroundBudget(ctx context.Context) error {
+	limiter := s.stravaClient.RateLimiter()
+	if limiter.ShouldDefer(s.config.RateLimitDeferThreshold) {
+		slog.Info("Deferring background work until the next rate limit window", "usage", limiter.Usage())
+	}
+	return limiter.WaitBelow(ctx, s.config.RateLimitDeferThreshold)
+}
+
+// fetchStreams fetches the recorded time series of an activity, returning nil
+// when the activity has none or the request fails
+func (s *Scheduler) fetchStreams(ctx context.Context, activityID int64) *strava.ActivityStreams {
+	streams, err := s.stravaClient.GetActivityStreams(ctx, activityID)
+	if err != nil {
+		slog.Error("Failed to fetch activity streams", "activity_id", activityID, "error", err)
+		return nil
+	}
+	return streams
+}
+
+// activitiesChanged updates the summaries, the fitness and workload series
+// and the critical power fit and looks for FTP evidence after activities
+// starting at the given times were written or deleted
+func (s *Scheduler) activitiesChanged(ctx context.Context, startDates []time.Time) {
+	if len(startDates) == 0 || ctx.Err() != nil {
+		return
+	}
+	s.updateSummaries(ctx, startDates)
+	s.updateRollingSummaries(ctx)
+
+	earliest := startDates[0]
+	for _, startDate := range startDates[1:] {
+		if startDate.Before(earliest) {
+			earliest = startDate
+		}
+	}
+	s.updateFitness(ctx, earliest)
+	s.updateWorkload(ctx, earliest)
+	s.updateCriticalPower(ctx)
+	s.suggestFTP(ctx)
+}
+
+// updateSummaries recomputes the weekly, monthly and yearly summaries of the
+// periods containing the given activity start dates
+func (s *Scheduler) updateSummaries(ctx context.Context, startDates []time.Time) {
+	if len(startDates) == 0 || ctx.Err() != nil {
+		return
+	}
+
+	weeks := make(map[time.Time]bool)
+	months := make(map[time.Time]bool)
+	years := make(map[time.Time]bool)
+	for _, startDate := range startDates {
+		local := startDate.In(s.config.AthleteLocation())
+		weeks[strava.GetWeekStart(local)] = true
+		months[strava.GetMonthStart(local)] = true
+		years[strava.GetYearStart(local)] = true
+	}
+
+	for weekStart := range weeks {
+		s.calculateWeeklySummary(ctx, weekStart)
+	}
+	for monthStart := range months {
+		s.calculateMonthlySummary(ctx, monthStart)
+	}
+	for yearStart := range years {
+		s.calculateYearlySummary(ctx, yearStart)
+	}
 }
 
-func (s *Scheduler) calculateWeeklySummaryJob() {
+func (s *Scheduler) calculateWeeklySummaryJob(ctx context.Context) {
 	slog.Info("Starting weekly summary calculation job")
 
 	// Calculate for the current week and previous week
-	now := time.Now()
+	now := time.Now().In(s.config.AthleteLocation())
 	currentWeek := strava.GetWeekStart(now)
 	previousWeek := currentWeek.AddDate(0, 0, -7)
 
-	s.calculateWeeklySummary(currentWeek)
-	s.calculateWeeklySummary(previousWeek)
+	s.calculateWeeklySummary(ctx, currentWeek)
+	s.calculateWeeklySummary(ctx, previousWeek)
 
 	slog.Info("Weekly summary calculation job completed")
 }
 
-func (s *Scheduler) calculateMonthlySummaryJob() {
+func (s *Scheduler) calculateMonthlySummaryJob(ctx context.Context) {
 	slog.Info("Starting monthly summary calculation job")
 
 	// Calculate for the current month and previous month
-	now := time.Now()
+	now := time.Now().In(s.config.AthleteLocation())
 	currentMonth := strava.GetMonthStart(now)
 	previousMonth := currentMonth.AddDate(0, -1, 0)
 
-	s.calculateMonthlySummary(currentMonth)
-	s.calculateMonthlySummary(previousMonth)
+	s.calculateMonthlySummary(ctx, currentMonth)
+	s.calculateMonthlySummary(ctx, previousMonth)
 
 	slog.Info("Monthly summary calculation job completed")
 }
 
-func (s *Scheduler) calculateYearlySummaryJob() {
+func (s *Scheduler) calculateYearlySummaryJob(ctx context.Context) {
 	slog.Info("Starting yearly summary calculation job")
 
 	// Calculate for the current year and previous year
-	now := time.Now()
+	now := time.Now().In(s.config.AthleteLocation())
 	currentYear := strava.GetYearStart(now)
 	previousYear := currentYear.AddDate(-1, 0, 0)
 
-	s.calculateYearlySummary(currentYear)
-	s.calculateYearlySummary(previousYear)
+	s.calculateYearlySummary(ctx, currentYear)
+	s.calculateYearlySummary(ctx, previousYear)
 
 	slog.Info("Yearly summary calculation job completed")
 }
 
-func (s *Scheduler) calculateWeeklySummary(weekStart time.Time) {
-	// This is a simplified implementation
-	// In a real scenario, you would query InfluxDB for activities in this week
+func (s *Scheduler) calculateWeeklySummary(ctx context.Context, weekStart time.Time) {
 	slog.Info("Calculating weekly summary", "week_start", weekStart)
 
-	summary := strava.WeeklySummary{
-		WeekStart:          weekStart,
-		TotalTSS:           0,
-		TotalMovingTime:    0,
-		TotalDistance:      0,
-		TotalElevationGain: 0,
+	activities, err := s.influxClient.GetActivities(ctx, weekStart, weekStart.AddDate(0, 0, 7))
+	if err != nil {
+		slog.Error("Failed to load activities for weekly summary", "error", err)
+		return
 	}
+	summary := strava.CalculateWeeklySummary(activities, weekStart)
 
-	if err := s.influxClient.WriteWeeklySummary(&summary); err != nil {
+	if err := s.influxClient.WriteWeeklySummary(ctx, &summary); err != nil {
 		slog.Error("Failed to write weekly summary", "error", err)
 	}
 }
 
-func (s *Scheduler) calculateMonthlySummary(monthStart time.Time) {
+func (s *Scheduler) calculateMonthlySummary(ctx context.Context, monthStart time.Time) {
 	slog.Info("Calculating monthly summary", "month_start", monthStart)
 
-	summary := strava.MonthlySummary{
-		MonthStart:      monthStart,
-		TotalTSS:        0,
-		TotalMovingTime: 0,
-		TotalDistance:   0,
+	activities, err := s.influxClient.GetActivities(ctx, monthStart, monthStart.AddDate(0, 1, 0))
+	if err != nil {
+		slog.Error("Failed to load activities for monthly summary", "error", err)
+		return
 	}
+	summary := strava.CalculateMonthlySummary(activities, monthStart)
 
-	if err := s.influxClient.WriteMonthlySummary(&summary); err != nil {
+	if err := s.influxClient.WriteMonthlySummary(ctx, &summary); err != nil {
 		slog.Error("Failed to write monthly summary", "error", err)
 	}
 }
 
-func (s *Scheduler) calculateYearlySummary(yearStart time.Time) {
+func (s *Scheduler) calculateYearlySummary(ctx context.Context, yearStart time.Time) {
 	slog.Info("Calculating yearly summary", "year_start", yearStart)
 
-	summary := strava.YearlySummary{
-		YearStart:       yearStart,
-		TotalTSS:        0,
-		TotalMovingTime: 0,
-		TotalDistance:   0,
+	activities, err := s.influxClient.GetActivities(ctx, yearStart, yearStart.AddDate(1, 0, 0))
+	if err != nil {
+		slog.Error("Failed to load activities for yearly summary", "error", err)
+		return
 	}
+	summary := strava.CalculateYearlySummary(activities, yearStart)
 
-	if err := s.influxClient.WriteYearlySummary(&summary); err != nil {
+	if err := s.influxClient.WriteYearlySummary(ctx, &summary); err != nil {
 		slog.Error("Failed to write yearly summary", "error", err)
 	}
 }
 
+// postToTwitter posts the activity in the background. The post is tracked
+// like any other work so that Stop waits for it to finish.
 func (s *Scheduler) postToTwitter(activity *strava.ActivityData) {
-	slog.Info("Posting activity to Twitter", "activity_id", activity.ID)
-
-	if err := s.twitterClient.PostActivity(activity); err != nil {
-		slog.Error("Failed to post to Twitter", "activity_id", activity.ID, "error", err)
+	_, end, err := s.begin(s.ctx)
+	if err != nil {
+		slog.Warn("Scheduler is stopping, not posting activity", "activity_id", activity.ID)
+		return
 	}
+
+	go func() {
+		defer end()
+		slog.Info("Posting activity to Twitter", "activity_id", activity.ID)
+
+		if err := s.notifier.PostActivity(activity); err != nil {
+			slog.Error("Failed to post to Twitter", "activity_id", activity.ID, "error", err)
+		}
+	}()
 }
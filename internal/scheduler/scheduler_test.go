@@ -1,12 +1,625 @@
 package scheduler
 
 import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 
+	"stravaDataImporter/internal/auth"
 	"stravaDataImporter/internal/config"
+	"stravaDataImporter/internal/strava"
+	"stravaDataImporter/internal/strava/fake"
+	"stravaDataImporter/internal/threshold"
 )
 
+// memoryActivityStore records what the scheduler writes
+type memoryActivityStore struct {
+	mu             sync.Mutex
+	activities     map[int64]*strava.ActivityData
+	streams        map[int64]*strava.ActivityStreams
+	powerCurves    map[int64]*strava.PowerCurve
+	laps           map[int64][]strava.LapData
+	segmentEfforts map[int64][]strava.SegmentEffortData
+	deleted        []int64
+	staleDeletes   []staleDelete
+	writeErr       error
+	gearStats      [][]strava.GearStats
+	maintenance    []strava.MaintenanceStatus
+	zones          *strava.AthleteZones
+	fitness        map[int64]strava.FitnessDay
+	workload       map[int64]strava.WorkloadDay
+	criticalPower  map[int64]strava.CriticalPower
+	ftpSuggestions []strava.FTPSuggestion
+	weights        []strava.WeightRecord
+	backfillState  *strava.BackfillState
+	weeklySummary  []strava.WeeklySummary
+	monthlySummary []strava.MonthlySummary
+	yearlySummary  []strava.YearlySummary
+	rolling        map[int]strava.RollingSummary
+	rollingWrites  int
+}
+
+// staleDelete is a DeleteActivityPoints call
+type staleDelete struct {
+	activityID   int64
+	activityType string
+	start, stop  time.Time
+}
+
+func newMemoryActivityStore() *memoryActivityStore {
+	return &memoryActivityStore{
+		activities:     make(map[int64]*strava.ActivityData),
+		streams:        make(map[int64]*strava.ActivityStreams),
+		powerCurves:    make(map[int64]*strava.PowerCurve),
+		laps:           make(map[int64][]strava.LapData),
+		segmentEfforts: make(map[int64][]strava.SegmentEffortData),
+		fitness:        make(map[int64]strava.FitnessDay),
+		workload:       make(map[int64]strava.WorkloadDay),
+		criticalPower:  make(map[int64]strava.CriticalPower),
+		rolling:        make(map[int]strava.RollingSummary),
+	}
+}
+
+func (m *memoryActivityStore) WriteActivity(ctx context.Context, activity *strava.ActivityData) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.writeErr != nil {
+		return m.writeErr
+	}
+	m.activities[activity.ID] = activity
+	return nil
+}
+
+func (m *memoryActivityStore) WriteActivityStreams(ctx context.Context, activity *strava.ActivityData, streams *strava.ActivityStreams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.streams[activity.ID] = streams
+	return nil
+}
+
+func (m *memoryActivityStore) WritePowerCurve(ctx context.Context, curve *strava.PowerCurve) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.powerCurves[curve.ActivityID] = curve
+	return nil
+}
+
+func (m *memoryActivityStore) WriteLaps(ctx context.Context, laps []strava.LapData) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, lap := range laps {
+		m.laps[lap.ActivityID] = append(m.laps[lap.ActivityID], lap)
+	}
+	return nil
+}
+
+func (m *memoryActivityStore) WriteSegmentEfforts(ctx context.Context, efforts []strava.SegmentEffortData) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, effort := range efforts {
+		m.segmentEfforts[effort.ActivityID] = append(m.segmentEfforts[effort.ActivityID], effort)
+	}
+	return nil
+}
+
+func (m *memoryActivityStore) MarkActivityDeleted(ctx context.Context, activityID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deleted = append(m.deleted, activityID)
+	if activity, ok := m.activities[activityID]; ok {
+		activity.Deleted = true
+	}
+	delete(m.powerCurves, activityID)
+	return nil
+}
+
+func (m *memoryActivityStore) GetActivitySeries(ctx context.Context, activityID int64) ([]strava.ActivityData, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if activity, ok := m.activities[activityID]; ok {
+		return []strava.ActivityData{*activity}, nil
+	}
+	return nil, nil
+}
+
+func (m *memoryActivityStore) DeleteLegacyActivitySeries(ctx context.Context, activityID int64) error {
+	return nil
+}
+
+// DeleteActivityPoints records the deletion; the memory store keeps one
+// series per activity, which every rewrite replaces
+func (m *memoryActivityStore) DeleteActivityPoints(ctx context.Context, activityID int64, activityType string, start, stop time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.staleDeletes = append(m.staleDeletes, staleDelete{activityID: activityID, activityType: activityType, start: start, stop: stop})
+	return nil
+}
+
+func (m *memoryActivityStore) GetActivities(ctx context.Context, start, end time.Time) ([]strava.ActivityData, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var activities []strava.ActivityData
+	for _, activity := range m.activities {
+		if !activity.StartDate.Before(start) && activity.StartDate.Before(end) {
+			activities = append(activities, *activity)
+		}
+	}
+	return activities, nil
+}
+
+func (m *memoryActivityStore) WriteWeeklySummary(ctx context.Context, summary *strava.WeeklySummary) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.weeklySummary {
+		if m.weeklySummary[i].WeekStart.Equal(summary.WeekStart) {
+			m.weeklySummary[i] = *summary
+			return nil
+		}
+	}
+	m.weeklySummary = append(m.weeklySummary, *summary)
+	return nil
+}
+
+func (m *memoryActivityStore) WriteMonthlySummary(ctx context.Context, summary *strava.MonthlySummary) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.monthlySummary {
+		if m.monthlySummary[i].MonthStart.Equal(summary.MonthStart) {
+			m.monthlySummary[i] = *summary
+			return nil
+		}
+	}
+	m.monthlySummary = append(m.monthlySummary, *summary)
+	return nil
+}
+
+func (m *memoryActivityStore) WriteYearlySummary(ctx context.Context, summary *strava.YearlySummary) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.yearlySummary {
+		if m.yearlySummary[i].YearStart.Equal(summary.YearStart) {
+			m.yearlySummary[i] = *summary
+			return nil
+		}
+	}
+	m.yearlySummary = append(m.yearlySummary, *summary)
+	return nil
+}
+
+func (m *memoryActivityStore) SaveBackfillState(ctx context.Context, state *strava.BackfillState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	saved := *state
+	m.backfillState = &saved
+	return nil
+}
+
+func (m *memoryActivityStore) LoadBackfillState(ctx context.Context) (*strava.BackfillState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.backfillState, nil
+}
+
+func (m *memoryActivityStore) WriteGearStats(ctx context.Context, stats []strava.GearStats) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gearStats = append(m.gearStats, stats)
+	return nil
+}
+
+func (m *memoryActivityStore) WriteMaintenanceStatus(ctx context.Context, statuses []strava.MaintenanceStatus) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maintenance = statuses
+	return nil
+}
+
+func (m *memoryActivityStore) GetMaintenanceStatus(ctx context.Context) ([]strava.MaintenanceStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.maintenance, nil
+}
+
+func (m *memoryActivityStore) WriteAthleteZones(ctx context.Context, zones *strava.AthleteZones) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.zones = zones
+	return nil
+}
+
+func (m *memoryActivityStore) LoadAthleteZones(ctx context.Context) (*strava.AthleteZones, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.zones, nil
+}
+
+func (m *memoryActivityStore) WriteFitness(ctx context.Context, days []strava.FitnessDay) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, day := range days {
+		m.fitness[day.Date.Unix()] = day
+	}
+	return nil
+}
+
+func (m *memoryActivityStore) GetFitness(ctx context.Context, start, end time.Time) ([]strava.FitnessDay, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var days []strava.FitnessDay
+	for _, day := range m.fitness {
+		if !day.Date.Before(start) && day.Date.Before(end) {
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date.Before(days[j].Date) })
+	return days, nil
+}
+
+func (m *memoryActivityStore) WriteRollingSummaries(ctx context.Context, summaries []strava.RollingSummary) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, summary := range summaries {
+		m.rolling[summary.Days] = summary
+	}
+	m.rollingWrites++
+	return nil
+}
+
+func (m *memoryActivityStore) WriteWorkload(ctx context.Context, days []strava.WorkloadDay) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, day := range days {
+		m.workload[day.Date.Unix()] = day
+	}
+	return nil
+}
+
+func (m *memoryActivityStore) GetWorkload(ctx context.Context, start, end time.Time) ([]strava.WorkloadDay, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var days []strava.WorkloadDay
+	for _, day := range m.workload {
+		if !day.Date.Before(start) && day.Date.Before(end) {
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date.Before(days[j].Date) })
+	return days, nil
+}
+
+func (m *memoryActivityStore) GetPowerCurves(ctx context.Context, start, end time.Time) ([]strava.PowerCurve, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var curves []strava.PowerCurve
+	for _, curve := range m.powerCurves {
+		if !curve.StartDate.Before(start) && curve.StartDate.Before(end) {
+			curves = append(curves, *curve)
+		}
+	}
+	sort.Slice(curves, func(i, j int) bool { return curves[i].StartDate.Before(curves[j].StartDate) })
+	return curves, nil
+}
+
+func (m *memoryActivityStore) WriteCriticalPower(ctx context.Context, fit *strava.CriticalPower) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.criticalPower[fit.Date.Unix()] = *fit
+	return nil
+}
+
+func (m *memoryActivityStore) GetCriticalPower(ctx context.Context, start, end time.Time) ([]strava.CriticalPower, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var fits []strava.CriticalPower
+	for _, fit := range m.criticalPower {
+		if !fit.Date.Before(start) && fit.Date.Before(end) {
+			fits = append(fits, fit)
+		}
+	}
+	sort.Slice(fits, func(i, j int) bool { return fits[i].Date.Before(fits[j].Date) })
+	return fits, nil
+}
+
+func (m *memoryActivityStore) WriteFTPSuggestion(ctx context.Context, suggestion *strava.FTPSuggestion) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.ftpSuggestions {
+		if m.ftpSuggestions[i].ID == suggestion.ID {
+			m.ftpSuggestions[i] = *suggestion
+			return nil
+		}
+	}
+	m.ftpSuggestions = append(m.ftpSuggestions, *suggestion)
+	return nil
+}
+
+func (m *memoryActivityStore) GetFTPSuggestions(ctx context.Context) ([]strava.FTPSuggestion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]strava.FTPSuggestion(nil), m.ftpSuggestions...), nil
+}
+
+func (m *memoryActivityStore) WriteWeight(ctx context.Context, record *strava.WeightRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.weights = append(m.weights, *record)
+	return nil
+}
+
+func (m *memoryActivityStore) GetWeights(ctx context.Context) ([]strava.WeightRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]strava.WeightRecord(nil), m.weights...), nil
+}
+
+// recordingNotifier records what the scheduler would publish
+type recordingNotifier struct {
+	mu        sync.Mutex
+	posted    []int64
+	reminders []strava.MaintenanceStatus
+	alerts    []strava.OverloadAlert
+}
+
+func (n *recordingNotifier) PostActivity(activity *strava.ActivityData) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.posted = append(n.posted, activity.ID)
+	return nil
+}
+
+// postedActivities returns a copy of the activities posted so far
+func (n *recordingNotifier) postedActivities() []int64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]int64(nil), n.posted...)
+}
+
+func (n *recordingNotifier) PostMaintenanceReminder(status *strava.MaintenanceStatus) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.reminders = append(n.reminders, *status)
+	return nil
+}
+
+func (n *recordingNotifier) PostOverloadAlert(alert *strava.OverloadAlert) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.alerts = append(n.alerts, *alert)
+	return nil
+}
+
+// memoryTokenStore keeps the token in memory in place of InfluxDB
+type memoryTokenStore struct {
+	mu    sync.Mutex
+	token *strava.TokenData
+}
+
+func (m *memoryTokenStore) SaveToken(ctx context.Context, token *strava.TokenData) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.token = token
+	return nil
+}
+
+func (m *memoryTokenStore) LoadToken(ctx context.Context) (*strava.TokenData, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.token, nil
+}
+
+func (m *memoryTokenStore) ClearToken(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.token = nil
+	return nil
+}
+
+// newFakeStravaScheduler returns a scheduler wired to a fake Strava server
+// and in-memory stores, already holding a valid token
+func newFakeStravaScheduler(t *testing.T) (*Scheduler, *fake.Server, *memoryActivityStore) {
+	t.Helper()
+
+	fakeStrava, err := fake.New()
+	if err != nil {
+		t.Fatalf("fake.New() error = %v", err)
+	}
+	server := httptest.NewServer(fakeStrava)
+	t.Cleanup(server.Close)
+
+	ftpFile := filepath.Join(t.TempDir(), "ftp.csv")
+	if err := os.WriteFile(ftpFile, []byte("date,ftp\n2020-01-01,250\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write FTP file: %v", err)
+	}
+
+	cfg := &config.Config{
+		StravaClientID:     "test_client_id",
+		StravaClientSecret: "test_client_secret",
+		FTPFilePath:        ftpFile,
+		ImportStreams:      true,
+		ImportLaps:         true,
+		BackfillPageSize:   2,
+		FitnessCTLDays:     42,
+		FitnessATLDays:     7,
+		CPWindowDays:       90,
+	}
+	fake.Configure(cfg, server.URL)
+
+	tokenStore := auth.NewTokenStore(&memoryTokenStore{token: fakeStrava.IssueToken()})
+	tokenStore.SetRefresher(strava.NewClient(cfg))
+	store := newMemoryActivityStore()
+	s := New(cfg, store, tokenStore)
+	if err := s.ftpManager.LoadFTPData(); err != nil {
+		t.Fatalf("LoadFTPData() error = %v", err)
+	}
+
+	return s, fakeStrava, store
+}
+
+func TestImportDataJobWithFakeStrava(t *testing.T) {
+	s, fakeStrava, store := newFakeStravaScheduler(t)
+
+	// Only the two most recent fixture activities fall inside the import window
+	fakeStrava.ShiftActivities(time.Now().Add(-3 * time.Hour))
+
+	s.importDataJob(context.Background())
+
+	if len(store.activities) != 2 {
+		t.Fatalf("imported %d activities, want 2", len(store.activities))
+	}
+
+	ride := store.activities[1001]
+	if ride == nil {
+		t.Fatal("ride 1001 was not imported")
+	}
+	if ride.NPSource != strava.NPSourceStream {
+		t.Errorf("ride NPSource = %q, want %q", ride.NPSource, strava.NPSourceStream)
+	}
+	if ride.FTP != 250 || ride.TSS <= 0 {
+		t.Errorf("ride FTP = %v, TSS = %v, want FTP 250 and positive TSS", ride.FTP, ride.TSS)
+	}
+	// The 10 minute ride is too short for decoupling
+	if ride.VI < 1 || ride.EF <= 0 || ride.Decoupling != 0 {
+		t.Errorf("ride VI = %v, EF = %v, decoupling = %v, want VI and EF from the streams", ride.VI, ride.EF, ride.Decoupling)
+	}
+	if streams := store.streams[1001]; streams == nil || streams.Len() != 600 {
+		t.Errorf("ride streams were not written with 600 samples")
+	}
+
+	laps := store.laps[1001]
+	if len(laps) != 2 {
+		t.Fatalf("ride has %d laps, want 2", len(laps))
+	}
+	if laps[0].LapIndex != 1 || laps[0].NP <= 0 || laps[0].IF <= 0 || laps[0].AverageHeartrate <= 0 {
+		t.Errorf("first lap = %+v, want NP, IF and heart rate", laps[0])
+	}
+	if !laps[0].StartDate.Equal(ride.StartDate) {
+		t.Errorf("first lap starts at %v, want the ride start %v", laps[0].StartDate, ride.StartDate)
+	}
+	efforts := store.segmentEfforts[1001]
+	if len(efforts) != 1 || efforts[0].SegmentID != 7001 || efforts[0].PRRank != 1 || efforts[0].ElapsedTime != 300 {
+		t.Errorf("segment efforts = %+v, want a PR on segment 7001", efforts)
+	}
+
+	if len(ride.PowerZoneSeconds) != 7 || sum(ride.PowerZoneSeconds) != 600 {
+		t.Errorf("ride power zone seconds = %v, want 7 zones totalling 600", ride.PowerZoneSeconds)
+	}
+	if len(ride.HeartRateZoneSeconds) != 5 || sum(ride.HeartRateZoneSeconds) == 0 {
+		t.Errorf("ride heart rate zone seconds = %v, want 5 zones", ride.HeartRateZoneSeconds)
+	}
+	if store.zones == nil || store.zones.Power == nil || len(store.zones.Power.Zones) != 7 {
+		t.Errorf("athlete zones were not stored: %+v", store.zones)
+	}
+
+	curve := store.powerCurves[1001]
+	if curve == nil || len(curve.Watts) != len(strava.PowerCurveDurations) {
+		t.Fatalf("ride power curve = %+v, want one value per duration", curve)
+	}
+	if curve.Watts[0] < curve.Watts[7] || curve.Watts[7] <= 0 || curve.Watts[8] != 0 {
+		t.Errorf("ride power curve = %v, want a falling curve up to the 10 minute ride", curve.Watts)
+	}
+	if store.powerCurves[1002] != nil {
+		t.Error("run 1002 has no power stream and should have no power curve")
+	}
+
+	if store.activities[1002] == nil {
+		t.Error("run 1002 was not imported")
+	}
+	if store.activities[1003] != nil {
+		t.Error("activity 1003 is outside the import window and should not be imported")
+	}
+}
+
+func TestImportUpdatesSummaries(t *testing.T) {
+	s, fakeStrava, store := newFakeStravaScheduler(t)
+	weekStart := strava.GetWeekStart(time.Now())
+	fakeStrava.ShiftActivities(weekStart.Add(2 * time.Hour))
+
+	if _, err := s.Reconcile(context.Background(), weekStart.AddDate(0, 0, -14)); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	summary := findWeeklySummary(store, weekStart)
+	if summary == nil {
+		t.Fatalf("no weekly summary for %v after import: %+v", weekStart, store.weeklySummary)
+	}
+	ride := store.activities[1001]
+	if summary.ActivityCount == 0 || summary.TotalTSS < ride.TSS || summary.TotalDistance <= 0 || summary.TotalKilojoules <= 0 {
+		t.Errorf("summary = %+v, want the imported activities", summary)
+	}
+	if len(summary.PowerZoneSeconds) != 7 || sum(summary.PowerZoneSeconds) != sum(ride.PowerZoneSeconds) {
+		t.Errorf("summary power zone seconds = %v, want %v", summary.PowerZoneSeconds, ride.PowerZoneSeconds)
+	}
+	if len(store.monthlySummary) == 0 || len(store.yearlySummary) == 0 {
+		t.Error("monthly and yearly summaries were not updated after the import")
+	}
+
+	// Recomputing the week gives the same result
+	written := len(store.weeklySummary)
+	want := *summary
+	s.calculateWeeklySummary(context.Background(), weekStart)
+	if len(store.weeklySummary) != written {
+		t.Errorf("recomputing wrote %d weekly summaries, want %d", len(store.weeklySummary), written)
+	}
+	if got := findWeeklySummary(store, weekStart); got.ActivityCount != want.ActivityCount || got.TotalTSS != want.TotalTSS {
+		t.Errorf("recomputed summary = %+v, want %+v", got, want)
+	}
+}
+
+func findWeeklySummary(store *memoryActivityStore, weekStart time.Time) *strava.WeeklySummary {
+	for i := range store.weeklySummary {
+		if store.weeklySummary[i].WeekStart.Equal(weekStart) {
+			return &store.weeklySummary[i]
+		}
+	}
+	return nil
+}
+
+func sum(values []int) int {
+	total := 0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+func TestImportDataJobRefreshesExpiredToken(t *testing.T) {
+	s, fakeStrava, store := newFakeStravaScheduler(t)
+	fakeStrava.ShiftActivities(time.Now().Add(-3 * time.Hour))
+
+	// The stored token is rejected, so the client must refresh it and retry
+	fakeStrava.ExpireAccessTokens()
+
+	s.importDataJob(context.Background())
+
+	if len(store.activities) != 2 {
+		t.Fatalf("imported %d activities after token refresh, want 2", len(store.activities))
+	}
+}
+
+func TestBackfillJobWithFakeStrava(t *testing.T) {
+	s, _, store := newFakeStravaScheduler(t)
+
+	s.backfillJob(context.Background())
+
+	if len(store.activities) != 3 {
+		t.Fatalf("backfilled %d activities, want 3", len(store.activities))
+	}
+	if store.backfillState == nil || !store.backfillState.Completed || store.backfillState.Imported != 3 {
+		t.Errorf("backfill state = %+v, want completed with 3 imported", store.backfillState)
+	}
+	if _, ok := store.streams[1003]; ok {
+		t.Error("streams should not be fetched for manual activity 1003")
+	}
+}
+
 func TestNew(t *testing.T) {
 	cfg := &config.Config{
 		StravaClientID:     "test_client_id",
@@ -14,7 +627,7 @@ func TestNew(t *testing.T) {
 		FTPFilePath:        "./test_ftp.csv",
 	}
 
-	scheduler := New(cfg, nil)
+	scheduler := New(cfg, nil, nil)
 	if scheduler == nil {
 		t.Fatal("New() returned nil")
 	}
@@ -35,15 +648,90 @@ func TestSchedulerStartStop(t *testing.T) {
 		FTPFilePath:        "./test_ftp.csv",
 	}
 
-	scheduler := New(cfg, nil)
+	scheduler := New(cfg, nil, nil)
 
 	// Test Start
-	scheduler.Start()
+	scheduler.Start(context.Background())
 
 	// Test Stop
-	scheduler.Stop()
+	if err := scheduler.Stop(context.Background()); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+
+	// Work submitted after Stop is rejected
+	if err := scheduler.ImportActivity(context.Background(), 1, true); !errors.Is(err, errStopped) {
+		t.Errorf("ImportActivity() after Stop error = %v, want %v", err, errStopped)
+	}
+}
+
+func TestStopCancelsRunningJobs(t *testing.T) {
+	s, fakeStrava, store := newFakeStravaScheduler(t)
+	fakeStrava.ShiftActivities(time.Now().Add(-3 * time.Hour))
+
+	// Hold the budget so that the backfill blocks until it is cancelled
+	s.stravaClient.RateLimiter().Update(http.Header{
+		"X-Ratelimit-Limit": []string{"100,1000"},
+		"X-Ratelimit-Usage": []string{"100,1000"},
+	})
+	s.config.RateLimitDeferThreshold = 0.8
+	defer s.stravaClient.RateLimiter().Update(http.Header{
+		"X-Ratelimit-Limit": []string{"100,1000"},
+		"X-Ratelimit-Usage": []string{"0,0"},
+	})
+
+	started := make(chan struct{})
+	go s.cronJob(func(ctx context.Context) {
+		close(started)
+		s.backfillJob(ctx)
+	})()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Stop(ctx); err != nil {
+		t.Fatalf("Stop() error = %v, want running jobs to be cancelled", err)
+	}
+	if len(store.activities) != 0 {
+		t.Errorf("imported %d activities after cancellation, want 0", len(store.activities))
+	}
+}
+
+// blockingNotifier holds activity posts until release is closed
+type blockingNotifier struct {
+	recordingNotifier
+	started chan struct{}
+	release chan struct{}
+}
 
-	// Should not panic
+func (n *blockingNotifier) PostActivity(activity *strava.ActivityData) error {
+	close(n.started)
+	<-n.release
+	return n.recordingNotifier.PostActivity(activity)
+}
+
+func TestStopWaitsForPosts(t *testing.T) {
+	s, _, _ := newFakeStravaScheduler(t)
+	notifier := &blockingNotifier{started: make(chan struct{}), release: make(chan struct{})}
+	s.notifier = notifier
+
+	if err := s.ImportActivity(context.Background(), 1002, true); err != nil {
+		t.Fatalf("ImportActivity() error = %v", err)
+	}
+	<-notifier.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.Stop(ctx); err == nil {
+		t.Fatal("Stop() returned while the post was still running")
+	}
+
+	close(notifier.release)
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if posted := notifier.postedActivities(); len(posted) != 1 {
+		t.Errorf("posted = %v, want the post to finish before Stop returns", posted)
+	}
 }
 
 func TestGetWeekStart(t *testing.T) {
@@ -103,3 +791,59 @@ func getMonthStart(date time.Time) time.Time {
 func getYearStart(date time.Time) time.Time {
 	return time.Date(date.Year(), time.January, 1, 0, 0, 0, 0, date.Location())
 }
+
+func TestImportHeartRateLoad(t *testing.T) {
+	s, fakeStrava, store := newFakeStravaScheduler(t)
+	fakeStrava.ShiftActivities(time.Now().Add(-3 * time.Hour))
+
+	thresholdFile := filepath.Join(t.TempDir(), "thresholds.csv")
+	if err := os.WriteFile(thresholdFile, []byte("date,lthr,resting_hr,max_hr\n2020-01-01,165,50,190\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write threshold file: %v", err)
+	}
+	s.thresholdManager = threshold.NewThresholdManager(thresholdFile, s.config.AthleteLocation())
+	if err := s.thresholdManager.LoadThresholdData(); err != nil {
+		t.Fatalf("LoadThresholdData() error = %v", err)
+	}
+
+	s.importDataJob(context.Background())
+
+	run := store.activities[1002]
+	if run == nil {
+		t.Fatal("run 1002 was not imported")
+	}
+	if run.LoadModel != strava.LoadModelHeartRate || run.TSS <= 0 || run.TRIMP <= 0 || run.LTHR != 165 {
+		t.Errorf("run load = %s, TSS %v, TRIMP %v, LTHR %v, want a heart rate based TSS", run.LoadModel, run.TSS, run.TRIMP, run.LTHR)
+	}
+
+	ride := store.activities[1001]
+	if ride.LoadModel != strava.LoadModelPower || ride.TRIMP <= 0 {
+		t.Errorf("ride load = %s, TRIMP %v, want a power based TSS and a TRIMP", ride.LoadModel, ride.TRIMP)
+	}
+}
+
+func TestImportPaceLoad(t *testing.T) {
+	s, fakeStrava, store := newFakeStravaScheduler(t)
+	fakeStrava.ShiftActivities(time.Now().Add(-3 * time.Hour))
+
+	thresholdFile := filepath.Join(t.TempDir(), "thresholds.csv")
+	if err := os.WriteFile(thresholdFile, []byte("date,lthr,resting_hr,max_hr,threshold_pace,css\n2020-01-01,165,50,190,4:30,1:45\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write threshold file: %v", err)
+	}
+	s.thresholdManager = threshold.NewThresholdManager(thresholdFile, s.config.AthleteLocation())
+	if err := s.thresholdManager.LoadThresholdData(); err != nil {
+		t.Fatalf("LoadThresholdData() error = %v", err)
+	}
+
+	s.importDataJob(context.Background())
+
+	run := store.activities[1002]
+	if run == nil {
+		t.Fatal("run 1002 was not imported")
+	}
+	if run.LoadModel != strava.LoadModelRunPace || run.TSS <= 0 || run.NGP <= 0 || run.TRIMP <= 0 {
+		t.Errorf("run load = %s, TSS %v, NGP %v, TRIMP %v, want rTSS from the streams and a TRIMP", run.LoadModel, run.TSS, run.NGP, run.TRIMP)
+	}
+	if math.Abs(run.ThresholdSpeed-1000.0/270) > 1e-9 {
+		t.Errorf("run threshold speed = %v, want 4:30/km", run.ThresholdSpeed)
+	}
+}
@@ -0,0 +1,77 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"time"
+
+	"stravaDataImporter/internal/strava"
+)
+
+// weightSyncInterval is how long the Strava profile weight is trusted before
+// the athlete is fetched again
+const weightSyncInterval = time.Hour
+
+// weightChangeThreshold is the smallest change in kg of the Strava profile
+// weight that is recorded as a new weight
+const weightChangeThreshold = 0.05
+
+// weightForDate returns the body weight valid on the given date, or 0 when
+// none is known. The Strava profile weight is synced first.
+func (s *Scheduler) weightForDate(ctx context.Context, date time.Time) float64 {
+	s.syncWeight(ctx)
+	return s.weightManager.GetWeightForDate(date)
+}
+
+// syncWeight records the Strava profile weight as today's weight when it
+// differs from the weight history, fetching the athlete at most once per
+// weightSyncInterval. Strava only reports the current weight, so the
+// recorded values are stored to keep the history across restarts.
+func (s *Scheduler) syncWeight(ctx context.Context) {
+	s.weightMu.Lock()
+	defer s.weightMu.Unlock()
+
+	if !s.weightsLoaded {
+		records, err := s.influxClient.GetWeights(ctx)
+		if err != nil {
+			slog.Error("Failed to load stored weights", "error", err)
+		} else {
+			var fromStrava []strava.WeightRecord
+			for _, record := range records {
+				if record.Source == strava.WeightSourceStrava {
+					fromStrava = append(fromStrava, record)
+				}
+			}
+			s.weightManager.AddRecords(fromStrava...)
+			s.weightsLoaded = true
+		}
+	}
+
+	if !s.weightSyncedAt.IsZero() && time.Since(s.weightSyncedAt) < weightSyncInterval {
+		return
+	}
+	s.weightSyncedAt = time.Now()
+
+	athlete, err := s.stravaClient.GetAthlete(ctx)
+	if err != nil {
+		slog.Warn("Failed to fetch athlete weight", "error", err)
+		return
+	}
+	if athlete.Weight <= 0 {
+		return
+	}
+
+	today := strava.GetDayStart(time.Now().In(s.config.AthleteLocation()))
+	if math.Abs(s.weightManager.GetWeightForDate(time.Now())-athlete.Weight) < weightChangeThreshold {
+		return
+	}
+
+	record := strava.WeightRecord{Date: today, Weight: athlete.Weight, Source: strava.WeightSourceStrava}
+	if err := s.influxClient.WriteWeight(ctx, &record); err != nil {
+		slog.Error("Failed to write weight", "error", err)
+		return
+	}
+	s.weightManager.AddRecords(record)
+	slog.Info("Recorded Strava profile weight", "date", today, "weight", athlete.Weight)
+}
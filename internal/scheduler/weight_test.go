@@ -0,0 +1,87 @@
+package scheduler
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"stravaDataImporter/internal/strava"
+	"stravaDataImporter/internal/weight"
+)
+
+func TestImportWeight(t *testing.T) {
+	s, fakeStrava, store := newFakeStravaScheduler(t)
+	fakeStrava.ShiftActivities(time.Now().Add(-3 * time.Hour))
+
+	s.importDataJob(context.Background())
+
+	// The fixture athlete weighs 68.5 kg on Strava
+	if len(store.weights) != 1 || store.weights[0].Weight != 68.5 || store.weights[0].Source != strava.WeightSourceStrava {
+		t.Fatalf("weights = %+v, want the Strava profile weight recorded once", store.weights)
+	}
+
+	ride := store.activities[1001]
+	if ride == nil {
+		t.Fatal("ride 1001 was not imported")
+	}
+	if ride.Weight != 68.5 || math.Abs(ride.FTPPerKg-250/68.5) > 1e-9 || math.Abs(ride.NPPerKg-ride.NP/68.5) > 1e-9 {
+		t.Errorf("ride = weight %v, FTP %v W/kg, NP %v W/kg, want 68.5 kg and %v W/kg FTP", ride.Weight, ride.FTPPerKg, ride.NPPerKg, 250/68.5)
+	}
+	if curve := store.powerCurves[1001]; curve == nil || curve.Weight != 68.5 {
+		t.Errorf("power curve = %+v, want the weight on the activity date", curve)
+	}
+}
+
+func TestSyncWeightUsesStoredHistory(t *testing.T) {
+	s, _, store := newFakeStravaScheduler(t)
+
+	// A restart finds the weight already recorded from Strava earlier
+	recorded := strava.WeightRecord{Date: strava.GetDayStart(time.Now()).AddDate(0, 0, -10), Weight: 68.5, Source: strava.WeightSourceStrava}
+	_ = store.WriteWeight(context.Background(), &recorded)
+
+	if weight := s.weightForDate(context.Background(), time.Now()); weight != 68.5 {
+		t.Errorf("weightForDate() = %v, want 68.5", weight)
+	}
+	if weight := s.weightForDate(context.Background(), recorded.Date.AddDate(0, 0, -1)); weight != 0 {
+		t.Errorf("weightForDate() before the first record = %v, want 0", weight)
+	}
+	if len(store.weights) != 1 {
+		t.Errorf("weights = %+v, want no new record for an unchanged weight", store.weights)
+	}
+}
+
+func TestSyncWeightInAthleteTimezone(t *testing.T) {
+	s, _, store := newFakeStravaScheduler(t)
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("Asia/Tokyo not available: %v", err)
+	}
+	s.config.AthleteTimezone = tokyo
+	today := strava.GetDayStart(time.Now().In(tokyo))
+
+	weightFile := filepath.Join(t.TempDir(), "weight.csv")
+	if err := os.WriteFile(weightFile, []byte("date,weight\n"+today.Format("2006-01-02")+",70.0\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write weight file: %v", err)
+	}
+	s.weightManager = weight.NewWeightManager(weightFile, tokyo)
+	if err := s.weightManager.LoadWeightData(); err != nil {
+		t.Fatalf("LoadWeightData() error = %v", err)
+	}
+
+	// Early in the Tokyo morning the file weight of the same day wins over
+	// the Strava profile weight recorded for that day
+	morning := today.Add(7 * time.Hour)
+	if weight := s.weightForDate(context.Background(), morning); weight != 70.0 {
+		t.Errorf("weightForDate(%v) = %v, want the file weight 70.0", morning, weight)
+	}
+	if len(store.weights) != 1 || !store.weights[0].Date.Equal(today) {
+		t.Errorf("weights = %+v, want the Strava weight dated %v", store.weights, today)
+	}
+	if weight := s.weightForDate(context.Background(), today.Add(-time.Minute)); weight != 0 {
+		t.Errorf("weightForDate() the day before = %v, want 0", weight)
+	}
+}
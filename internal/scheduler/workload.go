@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"stravaDataImporter/internal/strava"
+)
+
+// updateWorkload recomputes the workload series from the day containing
+// since through today from the stored fitness days. Without a stored day
+// before since the whole history is recomputed. Metrics of today that
+// crossed their danger threshold raise an overload alert.
+func (s *Scheduler) updateWorkload(ctx context.Context, since time.Time) {
+	loc := s.config.AthleteLocation()
+	today := strava.GetDayStart(time.Now().In(loc))
+	from := strava.GetDayStart(since.In(loc))
+	if from.After(today) {
+		from = today
+	}
+
+	seed, err := s.influxClient.GetWorkload(ctx, from.AddDate(0, 0, -1), from)
+	if err != nil {
+		slog.Error("Failed to load workload", "error", err)
+		return
+	}
+	fitnessStart := from.AddDate(0, 0, 1-strava.WorkloadChronicDays)
+	if len(seed) == 0 {
+		from = time.Unix(0, 0).In(loc)
+		fitnessStart = from
+	}
+
+	fitness, err := s.influxClient.GetFitness(ctx, fitnessStart, today.AddDate(0, 0, 1))
+	if err != nil {
+		slog.Error("Failed to load fitness for workload", "error", err)
+		return
+	}
+	days := strava.CalculateWorkload(fitness, from)
+	if len(days) == 0 {
+		return
+	}
+
+	// Metrics that were in danger yesterday or at an earlier update today
+	// have been reported already
+	previous, err := s.influxClient.GetWorkload(ctx, today.AddDate(0, 0, -1), today.AddDate(0, 0, 1))
+	if err != nil {
+		slog.Error("Failed to load previous workload", "error", err)
+		return
+	}
+
+	if err := s.influxClient.WriteWorkload(ctx, days); err != nil {
+		slog.Error("Failed to write workload", "error", err)
+		return
+	}
+
+	latest := days[len(days)-1]
+	slog.Info("Workload updated", "days", len(days), "acwr", latest.ACWR, "monotony", latest.Monotony, "strain", latest.Strain)
+
+	if !latest.Date.Equal(today) {
+		return
+	}
+	for _, alert := range s.overloadAlerts(latest, previous) {
+		slog.Warn("Overload threshold crossed", "metric", alert.Metric, "value", alert.Value, "threshold", alert.Threshold)
+		if err := s.notifier.PostOverloadAlert(&alert); err != nil {
+			slog.Error("Failed to post overload alert", "metric", alert.Metric, "error", err)
+		}
+	}
+}
+
+// overloadAlerts returns an alert for every metric of the day that is past
+// its configured threshold but was not in any of the previous days
+func (s *Scheduler) overloadAlerts(day strava.WorkloadDay, previous []strava.WorkloadDay) []strava.OverloadAlert {
+	rules := []struct {
+		metric    string
+		threshold float64
+		value     func(strava.WorkloadDay) float64
+	}{
+		{strava.WorkloadMetricACWR, s.config.ACWRAlertThreshold, func(d strava.WorkloadDay) float64 { return d.ACWR }},
+		{strava.WorkloadMetricMonotony, s.config.MonotonyAlertThreshold, func(d strava.WorkloadDay) float64 { return d.Monotony }},
+		{strava.WorkloadMetricTSB, s.config.TSBAlertThreshold, func(d strava.WorkloadDay) float64 { return d.TSB }},
+	}
+
+	var alerts []strava.OverloadAlert
+	for _, rule := range rules {
+		if rule.threshold == 0 || !inDanger(rule.metric, rule.value(day), rule.threshold) {
+			continue
+		}
+		reported := false
+		for _, p := range previous {
+			if inDanger(rule.metric, rule.value(p), rule.threshold) {
+				reported = true
+				break
+			}
+		}
+		if !reported {
+			alerts = append(alerts, strava.OverloadAlert{Date: day.Date, Metric: rule.metric, Value: rule.value(day), Threshold: rule.threshold})
+		}
+	}
+	return alerts
+}
+
+// inDanger reports whether a workload metric is past its threshold. TSB is
+// dangerous below the threshold, the ratios above it.
+func inDanger(metric string, value, threshold float64) bool {
+	if metric == strava.WorkloadMetricTSB {
+		return value < threshold
+	}
+	return value > threshold
+}
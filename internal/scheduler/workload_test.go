@@ -0,0 +1,56 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"stravaDataImporter/internal/strava"
+)
+
+func TestUpdateWorkloadAlerts(t *testing.T) {
+	s, _, store := newFakeStravaScheduler(t)
+	notifier := &recordingNotifier{}
+	s.notifier = notifier
+	s.config.ACWRAlertThreshold = 1.5
+	s.config.MonotonyAlertThreshold = 0
+	s.config.TSBAlertThreshold = -30
+
+	// Four weeks of 50 TSS a day, then a week ramped to about 150
+	today := strava.GetDayStart(time.Now())
+	var fitness []strava.FitnessDay
+	for i := 34; i >= 0; i-- {
+		day := strava.FitnessDay{Date: today.AddDate(0, 0, -i), TSS: 50, TSB: -5}
+		if i < 7 {
+			day.TSS = float64(130 + 10*(i%3))
+			day.TSB = -20
+		}
+		fitness = append(fitness, day)
+	}
+	_ = store.WriteFitness(context.Background(), fitness)
+
+	s.updateWorkload(context.Background(), today)
+
+	days, _ := store.GetWorkload(context.Background(), time.Unix(0, 0), today.AddDate(0, 0, 1))
+	if len(days) != len(fitness) {
+		t.Fatalf("got %d workload days, want the whole history of %d days", len(days), len(fitness))
+	}
+	latest := days[len(days)-1]
+	if latest.ACWR <= 1.5 || latest.Monotony <= 0 || latest.Strain <= 0 {
+		t.Errorf("today = %+v, want an ACWR above 1.5 with monotony and strain", latest)
+	}
+	if days[20].ACWR != 0 {
+		t.Errorf("day 21 = %+v, want no ACWR before 28 days of history", days[20])
+	}
+	if len(notifier.alerts) != 1 || notifier.alerts[0].Metric != strava.WorkloadMetricACWR {
+		t.Fatalf("alerts = %+v, want one ACWR alert", notifier.alerts)
+	}
+
+	// A later update on the same day reports only newly crossed thresholds
+	fitness[len(fitness)-1].TSB = -35
+	_ = store.WriteFitness(context.Background(), fitness)
+	s.updateWorkload(context.Background(), today)
+	if len(notifier.alerts) != 2 || notifier.alerts[1].Metric != strava.WorkloadMetricTSB || notifier.alerts[1].Value != -35 {
+		t.Errorf("alerts = %+v, want a single new TSB alert", notifier.alerts)
+	}
+}
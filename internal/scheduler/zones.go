@@ -0,0 +1,43 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"stravaDataImporter/internal/strava"
+)
+
+// zonesSyncInterval is how long synced athlete zones are reused before they
+// are fetched from Strava again
+const zonesSyncInterval = time.Hour
+
+// athleteZones returns the athlete's heart rate and power zones, syncing them
+// from Strava at most once per zonesSyncInterval. When Strava cannot be
+// reached the last synced zones are used; nil means no zones are known.
+func (s *Scheduler) athleteZones(ctx context.Context) *strava.AthleteZones {
+	s.zonesMu.Lock()
+	defer s.zonesMu.Unlock()
+
+	if !s.zonesSyncedAt.IsZero() && time.Since(s.zonesSyncedAt) < zonesSyncInterval {
+		return s.zones
+	}
+	s.zonesSyncedAt = time.Now()
+
+	zones, err := s.stravaClient.GetAthleteZones(ctx)
+	if err != nil {
+		slog.Warn("Failed to fetch athlete zones", "error", err)
+		if s.zones == nil {
+			if s.zones, err = s.influxClient.LoadAthleteZones(ctx); err != nil {
+				slog.Error("Failed to load athlete zones", "error", err)
+			}
+		}
+		return s.zones
+	}
+
+	if err := s.influxClient.WriteAthleteZones(ctx, zones); err != nil {
+		slog.Error("Failed to write athlete zones", "error", err)
+	}
+	s.zones = zones
+	return zones
+}
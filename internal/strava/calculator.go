@@ -2,10 +2,23 @@ package strava
 
 import (
 	"fmt"
+	"math"
+	"sort"
 	"time"
 )
 
-// CalculateWeeklySummary calculates weekly summary from activities
+const (
+	// npWindowSeconds is the rolling average window used for Normalized Power
+	npWindowSeconds = 30
+
+	// maxHoldGapSeconds is the longest recording gap bridged by repeating the
+	// previous sample (smart recording). Longer gaps are treated as stopped.
+	maxHoldGapSeconds = 5
+)
+
+// CalculateWeeklySummary calculates weekly summary from activities. The week
+// includes activities starting exactly at weekStart; its location decides
+// which local midnight the week starts at.
 func CalculateWeeklySummary(activities []ActivityData, weekStart time.Time) WeeklySummary {
 	weekEnd := weekStart.AddDate(0, 0, 7)
 
@@ -13,14 +26,19 @@ func CalculateWeeklySummary(activities []ActivityData, weekStart time.Time) Week
 		WeekStart: weekStart,
 	}
 
-	for _, activity := range activities {
-		if activity.StartDate.After(weekStart) && activity.StartDate.Before(weekEnd) {
+	for _, activity := range UniqueActivities(activities) {
+		if !activity.StartDate.Before(weekStart) && activity.StartDate.Before(weekEnd) {
 			summary.TotalTSS += activity.TSS
 			summary.TotalMovingTime += activity.MovingTime
 			summary.TotalDistance += activity.Distance
 			summary.TotalElevationGain += activity.TotalElevationGain
+			summary.TotalKilojoules += activity.Kilojoules
+			summary.ActivityCount++
+			summary.PowerZoneSeconds = addZoneSeconds(summary.PowerZoneSeconds, activity.PowerZoneSeconds)
+			summary.HeartRateZoneSeconds = addZoneSeconds(summary.HeartRateZoneSeconds, activity.HeartRateZoneSeconds)
 		}
 	}
+	summary.ByType, summary.BySport = sportBreakdown(activities, weekStart, weekEnd)
 
 	return summary
 }
@@ -33,13 +51,19 @@ func CalculateMonthlySummary(activities []ActivityData, monthStart time.Time) Mo
 		MonthStart: monthStart,
 	}
 
-	for _, activity := range activities {
-		if activity.StartDate.After(monthStart) && activity.StartDate.Before(monthEnd) {
+	for _, activity := range UniqueActivities(activities) {
+		if !activity.StartDate.Before(monthStart) && activity.StartDate.Before(monthEnd) {
 			summary.TotalTSS += activity.TSS
 			summary.TotalMovingTime += activity.MovingTime
 			summary.TotalDistance += activity.Distance
+			summary.TotalElevationGain += activity.TotalElevationGain
+			summary.TotalKilojoules += activity.Kilojoules
+			summary.ActivityCount++
+			summary.PowerZoneSeconds = addZoneSeconds(summary.PowerZoneSeconds, activity.PowerZoneSeconds)
+			summary.HeartRateZoneSeconds = addZoneSeconds(summary.HeartRateZoneSeconds, activity.HeartRateZoneSeconds)
 		}
 	}
+	summary.ByType, summary.BySport = sportBreakdown(activities, monthStart, monthEnd)
 
 	return summary
 }
@@ -52,17 +76,132 @@ func CalculateYearlySummary(activities []ActivityData, yearStart time.Time) Year
 		YearStart: yearStart,
 	}
 
-	for _, activity := range activities {
-		if activity.StartDate.After(yearStart) && activity.StartDate.Before(yearEnd) {
+	for _, activity := range UniqueActivities(activities) {
+		if !activity.StartDate.Before(yearStart) && activity.StartDate.Before(yearEnd) {
 			summary.TotalTSS += activity.TSS
 			summary.TotalMovingTime += activity.MovingTime
 			summary.TotalDistance += activity.Distance
+			summary.TotalElevationGain += activity.TotalElevationGain
+			summary.TotalKilojoules += activity.Kilojoules
+			summary.ActivityCount++
+			summary.PowerZoneSeconds = addZoneSeconds(summary.PowerZoneSeconds, activity.PowerZoneSeconds)
+			summary.HeartRateZoneSeconds = addZoneSeconds(summary.HeartRateZoneSeconds, activity.HeartRateZoneSeconds)
 		}
 	}
+	summary.ByType, summary.BySport = sportBreakdown(activities, yearStart, yearEnd)
 
 	return summary
 }
 
+// RollingSummaryDays are the window lengths of the rolling summaries
+var RollingSummaryDays = []int{7, 28, 90, 365}
+
+// CalculateRollingSummary totals the activities of the days days before end,
+// i.e. starting in [end - days, end). Pass the start of tomorrow as end to
+// include today.
+func CalculateRollingSummary(activities []ActivityData, end time.Time, days int) RollingSummary {
+	start := end.AddDate(0, 0, -days)
+
+	summary := RollingSummary{
+		Days:  days,
+		Start: start,
+		End:   end,
+	}
+
+	for _, activity := range UniqueActivities(activities) {
+		if !activity.StartDate.Before(start) && activity.StartDate.Before(end) {
+			summary.TotalTSS += activity.TSS
+			summary.TotalMovingTime += activity.MovingTime
+			summary.TotalDistance += activity.Distance
+			summary.TotalElevationGain += activity.TotalElevationGain
+			summary.ActivityCount++
+		}
+	}
+
+	return summary
+}
+
+// sportBreakdown totals the activities starting in [start, end) per activity
+// type and per sport group, each ordered by name
+func sportBreakdown(activities []ActivityData, start, end time.Time) (byType, bySport []SportTotals) {
+	types := make(map[string]*SportTotals)
+	sports := make(map[string]*SportTotals)
+	for _, activity := range UniqueActivities(activities) {
+		if activity.StartDate.Before(start) || !activity.StartDate.Before(end) {
+			continue
+		}
+		addSportTotals(types, activity.Type, activity)
+		addSportTotals(sports, SportGroup(activity.Type), activity)
+	}
+	return sortedSportTotals(types), sortedSportTotals(sports)
+}
+
+func addSportTotals(totals map[string]*SportTotals, key string, activity ActivityData) {
+	sport, ok := totals[key]
+	if !ok {
+		sport = &SportTotals{Type: key}
+		totals[key] = sport
+	}
+	sport.TotalTSS += activity.TSS
+	sport.TotalMovingTime += activity.MovingTime
+	sport.TotalDistance += activity.Distance
+	sport.TotalElevationGain += activity.TotalElevationGain
+	sport.TotalKilojoules += activity.Kilojoules
+	sport.ActivityCount++
+}
+
+func sortedSportTotals(totals map[string]*SportTotals) []SportTotals {
+	sorted := make([]SportTotals, 0, len(totals))
+	for _, sport := range totals {
+		sorted = append(sorted, *sport)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Type < sorted[j].Type })
+	return sorted
+}
+
+// SportGroup returns the sport group an activity type is summarized under
+func SportGroup(activityType string) string {
+	switch {
+	case IsRide(activityType):
+		return SportGroupRide
+	case IsRun(activityType):
+		return SportGroupRun
+	case IsSwim(activityType):
+		return SportGroupSwim
+	case activityType == "Walk" || activityType == "Hike":
+		return SportGroupWalk
+	default:
+		return SportGroupOther
+	}
+}
+
+// UniqueActivities drops deleted activities and repeated series of the same
+// activity, which can remain until the reconcile job rewrites them
+func UniqueActivities(activities []ActivityData) []ActivityData {
+	seen := make(map[int64]bool, len(activities))
+	unique := make([]ActivityData, 0, len(activities))
+	for _, activity := range activities {
+		if activity.Deleted || (activity.ID != 0 && seen[activity.ID]) {
+			continue
+		}
+		seen[activity.ID] = true
+		unique = append(unique, activity)
+	}
+	return unique
+}
+
+// addZoneSeconds adds the time in zone of an activity to a running total.
+// Zone lists of different lengths are aligned from zone 1.
+func addZoneSeconds(total, seconds []int) []int {
+	for len(total) < len(seconds) {
+		total = append(total, 0)
+	}
+	for i, value := range seconds {
+		total[i] += value
+	}
+	return total
+}
+
 // GetWeekStart returns the Monday 00:00:00 of the week containing the given date
 func GetWeekStart(date time.Time) time.Time {
 	weekday := int(date.Weekday())
@@ -84,6 +223,151 @@ func GetYearStart(date time.Time) time.Time {
 	return time.Date(date.Year(), time.January, 1, 0, 0, 0, 0, date.Location())
 }
 
+// GetDayStart returns midnight of the day containing the given date
+func GetDayStart(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+}
+
+// GetSeasonStart returns the first day 00:00:00 of the season containing the
+// given date, for seasons starting on the first of startMonth (January when
+// unset)
+func GetSeasonStart(date time.Time, startMonth time.Month) time.Time {
+	if startMonth < time.January || startMonth > time.December {
+		startMonth = time.January
+	}
+	year := date.Year()
+	if date.Month() < startMonth {
+		year--
+	}
+	return time.Date(year, startMonth, 1, 0, 0, 0, 0, date.Location())
+}
+
+// CalculateFitness rolls the daily TSS of the activities into exponentially
+// weighted CTL and ATL for every day from "from" through "to", continuing
+// from the given previous day (zero to start from scratch). Days follow the
+// location of from.
+func CalculateFitness(activities []ActivityData, previous FitnessDay, from, to time.Time, ctlDays, atlDays int) []FitnessDay {
+	from = GetDayStart(from)
+	loc := from.Location()
+
+	dailyTSS := make(map[string]float64)
+	for _, activity := range UniqueActivities(activities) {
+		dailyTSS[activity.StartDate.In(loc).Format("2006-01-02")] += activity.TSS
+	}
+
+	ctlDecay := 1 - math.Exp(-1/float64(ctlDays))
+	atlDecay := 1 - math.Exp(-1/float64(atlDays))
+
+	var days []FitnessDay
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		tss := dailyTSS[day.Format("2006-01-02")]
+		current := FitnessDay{
+			Date:    day,
+			TSS:     tss,
+			CTL:     previous.CTL + (tss-previous.CTL)*ctlDecay,
+			ATL:     previous.ATL + (tss-previous.ATL)*atlDecay,
+			TSB:     previous.CTL - previous.ATL,
+			CTLDays: ctlDays,
+			ATLDays: atlDays,
+		}
+		days = append(days, current)
+		previous = current
+	}
+	return days
+}
+
+// Windows of the acute:chronic workload ratio and of monotony and strain in
+// days
+const (
+	WorkloadAcuteDays   = 7
+	WorkloadChronicDays = 28
+)
+
+// CalculateWorkload computes the workload of every fitness day from "from" on.
+// The fitness days must be consecutive and include the WorkloadChronicDays-1
+// days before from for the windows to be filled.
+func CalculateWorkload(fitness []FitnessDay, from time.Time) []WorkloadDay {
+	var days []WorkloadDay
+	for i, fitnessDay := range fitness {
+		if fitnessDay.Date.Before(from) {
+			continue
+		}
+		day := WorkloadDay{Date: fitnessDay.Date, TSB: fitnessDay.TSB}
+
+		if i+1 >= WorkloadAcuteDays {
+			week := fitness[i+1-WorkloadAcuteDays : i+1]
+			var total float64
+			for _, d := range week {
+				total += d.TSS
+			}
+			mean := total / WorkloadAcuteDays
+			var variance float64
+			for _, d := range week {
+				variance += (d.TSS - mean) * (d.TSS - mean)
+			}
+			// The same load every day leaves monotony undefined
+			if sd := math.Sqrt(variance / WorkloadAcuteDays); sd > 0 {
+				day.Monotony = mean / sd
+				day.Strain = total * day.Monotony
+			}
+			day.AcuteLoad = mean
+		}
+
+		if i+1 >= WorkloadChronicDays {
+			var total float64
+			for _, d := range fitness[i+1-WorkloadChronicDays : i+1] {
+				total += d.TSS
+			}
+			day.ChronicLoad = total / WorkloadChronicDays
+			if day.ChronicLoad > 0 {
+				day.ACWR = day.AcuteLoad / day.ChronicLoad
+			}
+		}
+
+		days = append(days, day)
+	}
+	return days
+}
+
+// ApplyFTP recalculates IF and TSS of an activity for a different FTP. The
+// TSS keeps the duration it was originally based on.
+func ApplyFTP(activity *ActivityData, ftp float64) {
+	if activity.NP <= 0 || ftp <= 0 {
+		return
+	}
+	if activity.LoadModel == LoadModelRunPace || activity.LoadModel == LoadModelSwimPace {
+		// FTP is a cycling threshold; runs and swims keep their pace based TSS
+		activity.FTP = ftp
+		ApplyWeight(activity, activity.Weight)
+		return
+	}
+
+	switch {
+	case activity.LoadModel != LoadModelHeartRate && activity.FTP > 0 && activity.TSS > 0:
+		ratio := activity.FTP / ftp
+		activity.TSS *= ratio * ratio
+	case activity.NPSource == NPSourceStream:
+		activity.TSS = CalculateTSS(activity.NP, ftp, activity.ElapsedTime)
+	default:
+		activity.TSS = CalculateTSS(activity.NP, ftp, activity.MovingTime)
+	}
+	activity.FTP = ftp
+	activity.IF = CalculateIntensityFactor(activity.NP, ftp)
+	activity.LoadModel = LoadModelPower
+	ApplyWeight(activity, activity.Weight)
+}
+
+// ApplyWeight sets the body weight of an activity and its FTP and NP in W/kg.
+// It does nothing without a weight.
+func ApplyWeight(activity *ActivityData, weight float64) {
+	if weight <= 0 {
+		return
+	}
+	activity.Weight = weight
+	activity.FTPPerKg = activity.FTP / weight
+	activity.NPPerKg = activity.NP / weight
+}
+
 // CalculateTSS calculates Training Stress Score
 func CalculateTSS(normalizedPower, ftp float64, durationSeconds int) float64 {
 	if ftp <= 0 {
@@ -94,6 +378,653 @@ func CalculateTSS(normalizedPower, ftp float64, durationSeconds int) float64 {
 	return (float64(durationSeconds) * normalizedPower * intensityFactor) / (ftp * 3600) * 100
 }
 
+// ResampleToSeconds spreads a stream onto a 1 Hz grid covering the elapsed
+// time from the first to the last sample. Short recording gaps repeat the
+// previous value and longer gaps are filled with zero. Samples whose offset
+// does not advance past the previous sample are skipped. When timeOffsets is
+// nil the samples are assumed to already be 1 Hz.
+func ResampleToSeconds(values []float64, timeOffsets []int) []float64 {
+	if len(values) == 0 {
+		return nil
+	}
+	if len(timeOffsets) < len(values) {
+		return append([]float64(nil), values...)
+	}
+
+	kept := make([]int, 0, len(values))
+	for i := range values {
+		if len(kept) == 0 || timeOffsets[i] > timeOffsets[kept[len(kept)-1]] {
+			kept = append(kept, i)
+		}
+	}
+
+	start := timeOffsets[kept[0]]
+	elapsed := timeOffsets[kept[len(kept)-1]] - start + 1
+
+	resampled := make([]float64, elapsed)
+	for k, i := range kept {
+		offset := timeOffsets[i] - start
+		resampled[offset] = values[i]
+
+		if k+1 < len(kept) {
+			gap := timeOffsets[kept[k+1]] - timeOffsets[i]
+			if gap <= maxHoldGapSeconds {
+				for j := 1; j < gap; j++ {
+					resampled[offset+j] = values[i]
+				}
+			}
+		}
+	}
+	return resampled
+}
+
+// CalculateNormalizedPower calculates Normalized Power from a power stream:
+// the 30-second rolling average is raised to the fourth power, averaged and
+// the fourth root taken. It returns the NP and the elapsed seconds covered by
+// the stream, or zeros when the stream is shorter than the rolling window.
+func CalculateNormalizedPower(watts []float64, timeOffsets []int) (float64, int) {
+	samples := ResampleToSeconds(watts, timeOffsets)
+	if len(samples) < npWindowSeconds {
+		return 0, 0
+	}
+
+	var windowSum, fourthPowerSum float64
+	count := 0
+	for i, value := range samples {
+		windowSum += value
+		if i >= npWindowSeconds {
+			windowSum -= samples[i-npWindowSeconds]
+		}
+		if i >= npWindowSeconds-1 {
+			rolling := windowSum / npWindowSeconds
+			fourthPowerSum += math.Pow(rolling, 4)
+			count++
+		}
+	}
+
+	return math.Pow(fourthPowerSum/float64(count), 0.25), len(samples)
+}
+
+// CalculatePowerCurve returns the best average power for each of
+// PowerCurveDurations. Recording gaps count as zero watts, so an effort has
+// to be continuous. Returns nil without samples.
+func CalculatePowerCurve(watts []float64, timeOffsets []int) []float64 {
+	samples := ResampleToSeconds(watts, timeOffsets)
+	if len(samples) == 0 {
+		return nil
+	}
+
+	cumulative := make([]float64, len(samples)+1)
+	for i, value := range samples {
+		cumulative[i+1] = cumulative[i] + value
+	}
+
+	curve := make([]float64, len(PowerCurveDurations))
+	for i, duration := range PowerCurveDurations {
+		for end := duration; end < len(cumulative); end++ {
+			if average := (cumulative[end] - cumulative[end-duration]) / float64(duration); average > curve[i] {
+				curve[i] = average
+			}
+		}
+	}
+	return curve
+}
+
+// PowerCurveRecentDays is the rolling window of the recent power curve
+const PowerCurveRecentDays = 42
+
+// BestPowerCurve returns the highest power for each duration among the
+// curves of activities that started in [start, end)
+func BestPowerCurve(curves []PowerCurve, start, end time.Time) []PowerCurvePoint {
+	points := make([]PowerCurvePoint, len(PowerCurveDurations))
+	for i, duration := range PowerCurveDurations {
+		points[i].Duration = duration
+	}
+	for _, curve := range curves {
+		if curve.StartDate.Before(start) || !curve.StartDate.Before(end) {
+			continue
+		}
+		for i, watts := range curve.Watts {
+			if i < len(points) && watts > points[i].Watts {
+				points[i].Watts = watts
+				points[i].WattsPerKg = 0
+				if curve.Weight > 0 {
+					points[i].WattsPerKg = watts / curve.Weight
+				}
+				points[i].ActivityID = curve.ActivityID
+				points[i].Date = curve.StartDate
+			}
+		}
+	}
+	return points
+}
+
+// CalculatePowerCurveBests returns the best power curves of the last
+// PowerCurveRecentDays days, the season containing now and all time
+func CalculatePowerCurveBests(curves []PowerCurve, now time.Time, seasonStartMonth time.Month) *PowerCurveBests {
+	end := now.Add(time.Second)
+	seasonStart := GetSeasonStart(now, seasonStartMonth)
+	return &PowerCurveBests{
+		RecentDays:  PowerCurveRecentDays,
+		SeasonStart: seasonStart,
+		Recent:      BestPowerCurve(curves, GetDayStart(now).AddDate(0, 0, 1-PowerCurveRecentDays), end),
+		Season:      BestPowerCurve(curves, seasonStart, end),
+		AllTime:     BestPowerCurve(curves, time.Time{}, end),
+	}
+}
+
+// Durations of the best efforts the critical power model is fitted to. Below
+// two minutes anaerobic capacity dominates, beyond twenty minutes fatigue.
+const (
+	cpMinDuration = 120
+	cpMaxDuration = 1200
+)
+
+// FitCriticalPower fits the 2-parameter critical power model to the best
+// efforts between two and twenty minutes by linear regression of work on
+// duration (W = CP*t + W'). Returns ok false without at least two efforts or
+// without a plausible fit.
+func FitCriticalPower(bests []PowerCurvePoint) (cp, wPrime float64, efforts int, ok bool) {
+	var sumT, sumW, sumTT, sumTW float64
+	for _, point := range bests {
+		if point.Duration < cpMinDuration || point.Duration > cpMaxDuration || point.Watts <= 0 {
+			continue
+		}
+		t := float64(point.Duration)
+		work := point.Watts * t
+		sumT += t
+		sumW += work
+		sumTT += t * t
+		sumTW += t * work
+		efforts++
+	}
+	if efforts < 2 {
+		return 0, 0, efforts, false
+	}
+
+	n := float64(efforts)
+	denominator := n*sumTT - sumT*sumT
+	if denominator == 0 {
+		return 0, 0, efforts, false
+	}
+	cp = (n*sumTW - sumT*sumW) / denominator
+	wPrime = (sumW - cp*sumT) / n
+	return cp, wPrime, efforts, cp > 0 && wPrime > 0
+}
+
+// CalculateWPrimeBalance returns the remaining W' in J after each watts
+// sample. Work above CP depletes W' one to one; below CP it recovers
+// exponentially with a time constant of W'/(CP-P) (Skiba, integral form by
+// Froncioni and Clarke). Each sample holds its power until the next one for
+// at most maxHoldGapSeconds; the rest of a longer gap recovers at 0 W. When
+// timeOffsets is nil the samples are assumed to be 1 Hz.
+func CalculateWPrimeBalance(watts []float64, timeOffsets []int, cp, wPrime float64) []float64 {
+	if cp <= 0 || wPrime <= 0 || len(watts) == 0 {
+		return nil
+	}
+
+	step := func(current, power, duration float64) float64 {
+		if power > cp {
+			return current - (power-cp)*duration
+		}
+		return wPrime - (wPrime-current)*math.Exp(-(cp-power)*duration/wPrime)
+	}
+
+	balance := make([]float64, len(watts))
+	current := wPrime
+	for i, power := range watts {
+		duration, stopped := 1, 0
+		if len(timeOffsets) >= len(watts) && i+1 < len(watts) {
+			if gap := timeOffsets[i+1] - timeOffsets[i]; gap > 0 {
+				duration = min(gap, maxHoldGapSeconds)
+				stopped = gap - duration
+			}
+		}
+		current = step(current, power, float64(duration))
+		if stopped > 0 {
+			current = step(current, 0, float64(stopped))
+		}
+		balance[i] = current
+	}
+	return balance
+}
+
+// ApplyWPrimeBalance computes the W'bal series of the watts stream into the
+// streams and records CP, W' and the lowest W'bal on the activity
+func ApplyWPrimeBalance(activity *ActivityData, streams *ActivityStreams, cp, wPrime float64) {
+	if streams == nil {
+		return
+	}
+	balance := CalculateWPrimeBalance(streams.Watts, streams.Time, cp, wPrime)
+	if len(balance) == 0 {
+		return
+	}
+
+	minimum := balance[0]
+	for _, value := range balance[1:] {
+		minimum = math.Min(minimum, value)
+	}
+	streams.WPrimeBalance = balance
+	activity.CP = cp
+	activity.WPrime = wPrime
+	activity.WPrimeBalanceMin = minimum
+}
+
+const (
+	// twentyMinuteFTPFactor converts the best 20 minute power to an FTP
+	twentyMinuteFTPFactor = 0.95
+
+	// FTPSuggestionMinIncrease is how much the evidence has to exceed the
+	// current FTP, relatively, before a new FTP is suggested
+	FTPSuggestionMinIncrease = 0.02
+)
+
+// SuggestFTP proposes the higher of 95% of the best 20 minute power and the
+// critical power of the fit when it exceeds the current FTP by at least
+// FTPSuggestionMinIncrease. The suggestion is dated on the day of the
+// evidence in loc. Returns nil without such evidence.
+func SuggestFTP(bests []PowerCurvePoint, fit *CriticalPower, currentFTP float64, loc *time.Location) *FTPSuggestion {
+	var suggestion *FTPSuggestion
+	for _, point := range bests {
+		if point.Duration == 1200 && point.Watts > 0 {
+			suggestion = &FTPSuggestion{
+				Date:          point.Date,
+				FTP:           math.Round(point.Watts * twentyMinuteFTPFactor),
+				Source:        FTPSourceTwentyMinute,
+				EvidenceWatts: point.Watts,
+				ActivityID:    point.ActivityID,
+			}
+		}
+	}
+	if fit != nil && fit.CP > 0 && (suggestion == nil || math.Round(fit.CP) > suggestion.FTP) {
+		suggestion = &FTPSuggestion{
+			Date:          fit.Date,
+			FTP:           math.Round(fit.CP),
+			Source:        FTPSourceCriticalPower,
+			EvidenceWatts: fit.CP,
+		}
+	}
+	if suggestion == nil || suggestion.FTP < currentFTP*(1+FTPSuggestionMinIncrease) {
+		return nil
+	}
+
+	suggestion.Date = GetDayStart(suggestion.Date.In(loc))
+	suggestion.ID = suggestion.Date.Format("2006-01-02") + "-" + suggestion.Source
+	suggestion.CurrentFTP = currentFTP
+	suggestion.Status = FTPSuggestionPending
+	return suggestion
+}
+
+// ApplyPowerStream recalculates NP, IF and TSS from the watts stream, using
+// the elapsed duration of the stream for TSS. Activities without a usable
+// power stream keep the values derived from Strava's weighted average power.
+func ApplyPowerStream(activity *ActivityData, streams *ActivityStreams) {
+	if streams == nil || len(streams.Watts) == 0 {
+		return
+	}
+
+	np, elapsed := CalculateNormalizedPower(streams.Watts, streams.Time)
+	if np <= 0 {
+		return
+	}
+
+	activity.NP = np
+	activity.NPSource = NPSourceStream
+	activity.IF = CalculateIntensityFactor(np, activity.FTP)
+	activity.TSS = CalculateTSS(np, activity.FTP, elapsed)
+	if activity.TSS > 0 {
+		activity.LoadModel = LoadModelPower
+	}
+}
+
+// decouplingMinSeconds is the shortest recording whose halves are long
+// enough to compare for decoupling
+const decouplingMinSeconds = 1200
+
+// ApplyAerobicMetrics computes VI and, with a heart rate stream, EF and the
+// Pa:Hr decoupling of an activity whose NP comes from the power stream.
+// Decoupling compares the average power per heart beat of the first half of
+// the elapsed time with the second half; positive values mean the heart rate
+// drifted up relative to the power. Seconds without heart rate are ignored.
+func ApplyAerobicMetrics(activity *ActivityData, streams *ActivityStreams) {
+	if streams == nil || activity.NPSource != NPSourceStream || activity.NP <= 0 {
+		return
+	}
+
+	watts := ResampleToSeconds(streams.Watts, streams.Time)
+	var totalWatts float64
+	for _, value := range watts {
+		totalWatts += value
+	}
+	if totalWatts > 0 {
+		activity.VI = activity.NP / (totalWatts / float64(len(watts)))
+	}
+
+	heartrate := ResampleToSeconds(streams.Heartrate, streams.Time)
+	n := min(len(watts), len(heartrate))
+	var halfWatts, halfHeartrate [2]float64
+	var seconds int
+	for i := 0; i < n; i++ {
+		if heartrate[i] <= 0 {
+			continue
+		}
+		half := 0
+		if i >= n/2 {
+			half = 1
+		}
+		halfWatts[half] += watts[i]
+		halfHeartrate[half] += heartrate[i]
+		seconds++
+	}
+	if seconds == 0 {
+		return
+	}
+
+	activity.EF = activity.NP / ((halfHeartrate[0] + halfHeartrate[1]) / float64(seconds))
+
+	// Power and heart rate are summed over the same seconds, so their ratio
+	// equals the ratio of the averages
+	if n >= decouplingMinSeconds && halfHeartrate[0] > 0 && halfHeartrate[1] > 0 && halfWatts[0] > 0 {
+		first := halfWatts[0] / halfHeartrate[0]
+		second := halfWatts[1] / halfHeartrate[1]
+		activity.Decoupling = (first - second) / first * 100
+	}
+}
+
+// IsRide reports whether the activity type is a kind of cycling
+func IsRide(activityType string) bool {
+	switch activityType {
+	case "Ride", "VirtualRide", "EBikeRide", "EMountainBikeRide", "GravelRide", "MountainBikeRide", "Velomobile", "Handcycle":
+		return true
+	}
+	return false
+}
+
+// IsRun reports whether the activity type is scored with rTSS
+func IsRun(activityType string) bool {
+	switch activityType {
+	case "Run", "TrailRun", "VirtualRun":
+		return true
+	}
+	return false
+}
+
+// IsSwim reports whether the activity type is scored with sTSS
+func IsSwim(activityType string) bool {
+	return activityType == "Swim"
+}
+
+const (
+	// gradeWindowMeters is the distance over which the running grade is
+	// measured, smoothing out altitude noise
+	gradeWindowMeters = 20
+
+	// maxGrade bounds the grade to the range the cost model was fitted for
+	maxGrade = 0.45
+)
+
+// runningCost is the energy cost of running in J/kg/m at the given grade
+// (Minetti et al. 2002)
+func runningCost(grade float64) float64 {
+	return 155.4*math.Pow(grade, 5) - 30.4*math.Pow(grade, 4) - 43.3*math.Pow(grade, 3) + 46.3*grade*grade + 19.5*grade + 3.6
+}
+
+// GradeAdjustedSpeeds converts the velocity stream to the speed on flat
+// ground with the same energy cost. Without altitude and distance streams
+// the velocity is returned unchanged.
+func GradeAdjustedSpeeds(streams *ActivityStreams) []float64 {
+	speeds := streams.VelocitySmooth
+	if len(streams.Altitude) < len(speeds) || len(streams.Distance) < len(speeds) {
+		return speeds
+	}
+
+	flatCost := runningCost(0)
+	adjusted := make([]float64, len(speeds))
+	start := 0
+	for i, speed := range speeds {
+		for start < i && streams.Distance[i]-streams.Distance[start+1] >= gradeWindowMeters {
+			start++
+		}
+		grade := 0.0
+		if run := streams.Distance[i] - streams.Distance[start]; run >= gradeWindowMeters {
+			grade = (streams.Altitude[i] - streams.Altitude[start]) / run
+		}
+		grade = math.Max(-maxGrade, math.Min(maxGrade, grade))
+		adjusted[i] = speed * runningCost(grade) / flatCost
+	}
+	return adjusted
+}
+
+// ApplyPaceLoad scores runs against the threshold pace (rTSS) and swims
+// against the critical swim speed (sTSS). Runs use the normalized graded
+// pace of the streams, or the average speed without them; swims use the
+// average speed. FTP is a cycling threshold, so the pace based TSS replaces
+// a power based one.
+func ApplyPaceLoad(activity *ActivityData, streams *ActivityStreams, thresholds Thresholds) {
+	if activity.MovingTime <= 0 {
+		return
+	}
+	averageSpeed := activity.Distance / float64(activity.MovingTime)
+
+	switch {
+	case IsRun(activity.Type) && thresholds.ThresholdPace > 0:
+		speed, duration := averageSpeed, activity.MovingTime
+		if streams != nil && len(streams.VelocitySmooth) > 0 {
+			if ngp, elapsed := CalculateNormalizedPower(GradeAdjustedSpeeds(streams), streams.Time); ngp > 0 {
+				speed, duration = ngp, elapsed
+			}
+		}
+		if speed <= 0 {
+			return
+		}
+		activity.NGP = speed
+		activity.ThresholdSpeed = thresholds.ThresholdPace
+		activity.IF = speed / thresholds.ThresholdPace
+		activity.TSS = CalculateTSS(speed, thresholds.ThresholdPace, duration)
+		activity.LoadModel = LoadModelRunPace
+
+	case IsSwim(activity.Type) && thresholds.CriticalSwimSpeed > 0 && averageSpeed > 0:
+		// Swim TSS weights the intensity by its cube
+		intensity := averageSpeed / thresholds.CriticalSwimSpeed
+		activity.ThresholdSpeed = thresholds.CriticalSwimSpeed
+		activity.IF = intensity
+		activity.TSS = math.Pow(intensity, 3) * float64(activity.MovingTime) / 3600 * 100
+		activity.LoadModel = LoadModelSwimPace
+	}
+}
+
+// Banister TRIMP weighting, y = 0.64 * e^(1.92 * x) for the heart rate
+// reserve fraction x
+const (
+	trimpFactor   = 0.64
+	trimpExponent = 1.92
+)
+
+// trimpWeight is the TRIMP accumulated per minute at the given heart rate
+func trimpWeight(heartrate float64, thresholds Thresholds) float64 {
+	reserve := (heartrate - thresholds.RestingHR) / (thresholds.MaxHR - thresholds.RestingHR)
+	if reserve <= 0 {
+		return 0
+	}
+	reserve = math.Min(reserve, 1)
+	return reserve * trimpFactor * math.Exp(trimpExponent*reserve)
+}
+
+// CalculateTRIMP returns the Banister training impulse of a heart rate
+// stream. Every second is weighted by its heart rate reserve fraction.
+func CalculateTRIMP(heartrate []float64, timeOffsets []int, thresholds Thresholds) float64 {
+	if thresholds.RestingHR <= 0 || thresholds.MaxHR <= thresholds.RestingHR {
+		return 0
+	}
+
+	trimp := 0.0
+	for _, value := range ResampleToSeconds(heartrate, timeOffsets) {
+		trimp += trimpWeight(value, thresholds) / 60
+	}
+	return trimp
+}
+
+// CalculateHRTSS normalizes a TRIMP to an hour at the lactate threshold,
+// which scores 100 like an hour at FTP
+func CalculateHRTSS(trimp float64, thresholds Thresholds) float64 {
+	if thresholds.LTHR <= thresholds.RestingHR {
+		return 0
+	}
+	thresholdHour := 60 * trimpWeight(thresholds.LTHR, thresholds)
+	if thresholdHour <= 0 {
+		return 0
+	}
+	return trimp / thresholdHour * 100
+}
+
+// ApplyHeartRateLoad sets the TRIMP of an activity from its heart rate
+// stream, or from the average heart rate over the moving time without one.
+// Activities without a power based TSS get the hrTSS as their TSS.
+func ApplyHeartRateLoad(activity *ActivityData, streams *ActivityStreams, thresholds Thresholds) {
+	if thresholds.RestingHR <= 0 || thresholds.MaxHR <= thresholds.RestingHR {
+		return
+	}
+
+	switch {
+	case streams != nil && len(streams.Heartrate) > 0:
+		activity.TRIMP = CalculateTRIMP(streams.Heartrate, streams.Time, thresholds)
+	case activity.AverageHeartrate > 0:
+		activity.TRIMP = float64(activity.MovingTime) / 60 * trimpWeight(activity.AverageHeartrate, thresholds)
+	default:
+		return
+	}
+
+	if activity.LoadModel != "" && activity.LoadModel != LoadModelHeartRate {
+		return
+	}
+	if tss := CalculateHRTSS(activity.TRIMP, thresholds); tss > 0 {
+		activity.TSS = tss
+		activity.LTHR = thresholds.LTHR
+		activity.LoadModel = LoadModelHeartRate
+	}
+}
+
+// CalculateTimeInZones returns the seconds spent in each zone. Every sample
+// counts for the time until the next one; gaps longer than maxHoldGapSeconds
+// count as a single second. Samples outside every zone are not counted. When
+// timeOffsets is nil the samples are assumed to be 1 Hz.
+func CalculateTimeInZones(values []float64, timeOffsets []int, zones []ZoneRange) []int {
+	if len(zones) == 0 || len(values) == 0 {
+		return nil
+	}
+
+	seconds := make([]int, len(zones))
+	for i, value := range values {
+		duration := 1
+		if len(timeOffsets) >= len(values) && i+1 < len(values) {
+			if gap := timeOffsets[i+1] - timeOffsets[i]; gap > 0 && gap <= maxHoldGapSeconds {
+				duration = gap
+			}
+		}
+		for z, zone := range zones {
+			if zone.Contains(value) {
+				seconds[z] += duration
+				break
+			}
+		}
+	}
+	return seconds
+}
+
+// ApplyZones calculates the time in power and heart rate zones from the
+// streams. Heart rate samples of zero are dropouts and are not counted.
+func ApplyZones(activity *ActivityData, streams *ActivityStreams, zones *AthleteZones) {
+	if streams == nil || zones == nil {
+		return
+	}
+
+	if zones.Power != nil && len(streams.Watts) > 0 {
+		activity.PowerZoneSeconds = CalculateTimeInZones(streams.Watts, streams.Time, zones.Power.Zones)
+	}
+
+	if zones.HeartRate != nil && len(zones.HeartRate.Zones) > 0 && len(streams.Heartrate) > 0 {
+		hrZones := append([]ZoneRange(nil), zones.HeartRate.Zones...)
+		hrZones[0].Min = max(hrZones[0].Min, 1)
+		activity.HeartRateZoneSeconds = CalculateTimeInZones(streams.Heartrate, streams.Time, hrZones)
+	}
+}
+
+// ConvertLaps converts the laps of a detailed activity. NP and IF are
+// calculated from the slice of the power stream covered by each lap and are
+// left at zero without a power stream.
+func ConvertLaps(activity StravaActivity, ftp float64, streams *ActivityStreams) []LapData {
+	laps := make([]LapData, 0, len(activity.Laps))
+	for _, lap := range activity.Laps {
+		startDate, err := time.Parse(time.RFC3339, lap.StartDate)
+		if err != nil {
+			continue
+		}
+
+		data := LapData{
+			ActivityID:       activity.ID,
+			ActivityType:     activity.Type,
+			LapIndex:         lap.LapIndex,
+			Name:             lap.Name,
+			StartDate:        startDate,
+			ElapsedTime:      lap.ElapsedTime,
+			MovingTime:       lap.MovingTime,
+			Distance:         lap.Distance,
+			AverageWatts:     lap.AverageWatts,
+			AverageHeartrate: lap.AverageHeartrate,
+			MaxHeartrate:     lap.MaxHeartrate,
+			AverageSpeed:     lap.AverageSpeed,
+			AverageCadence:   lap.AverageCadence,
+		}
+
+		if streams != nil && lap.StartIndex >= 0 && lap.EndIndex < len(streams.Watts) && lap.StartIndex <= lap.EndIndex {
+			var timeOffsets []int
+			if lap.EndIndex < len(streams.Time) {
+				timeOffsets = streams.Time[lap.StartIndex : lap.EndIndex+1]
+			}
+			data.NP, _ = CalculateNormalizedPower(streams.Watts[lap.StartIndex:lap.EndIndex+1], timeOffsets)
+			data.IF = CalculateIntensityFactor(data.NP, ftp)
+		}
+
+		laps = append(laps, data)
+	}
+	return laps
+}
+
+// ConvertSegmentEfforts converts the segment efforts of a detailed activity
+func ConvertSegmentEfforts(activity StravaActivity) []SegmentEffortData {
+	efforts := make([]SegmentEffortData, 0, len(activity.SegmentEfforts))
+	for _, effort := range activity.SegmentEfforts {
+		startDate, err := time.Parse(time.RFC3339, effort.StartDate)
+		if err != nil {
+			continue
+		}
+
+		data := SegmentEffortData{
+			ID:               effort.ID,
+			ActivityID:       activity.ID,
+			SegmentID:        effort.Segment.ID,
+			SegmentName:      effort.Segment.Name,
+			StartDate:        startDate,
+			ElapsedTime:      effort.ElapsedTime,
+			MovingTime:       effort.MovingTime,
+			Distance:         effort.Distance,
+			AverageGrade:     effort.Segment.AverageGrade,
+			AverageWatts:     effort.AverageWatts,
+			AverageHeartrate: effort.AverageHeartrate,
+			MaxHeartrate:     effort.MaxHeartrate,
+		}
+		if effort.PRRank != nil {
+			data.PRRank = *effort.PRRank
+		}
+		if effort.KOMRank != nil {
+			data.KOMRank = *effort.KOMRank
+		}
+
+		efforts = append(efforts, data)
+	}
+	return efforts
+}
+
 // CalculateIntensityFactor calculates Intensity Factor
 func CalculateIntensityFactor(normalizedPower, ftp float64) float64 {
 	if ftp <= 0 {
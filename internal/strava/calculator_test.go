@@ -0,0 +1,834 @@
+package strava
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCalculateNormalizedPowerSteady(t *testing.T) {
+	watts := make([]float64, 600)
+	for i := range watts {
+		watts[i] = 200
+	}
+
+	np, elapsed := CalculateNormalizedPower(watts, nil)
+	if math.Abs(np-200) > 1e-9 {
+		t.Errorf("NP = %v, want 200 for steady power", np)
+	}
+	if elapsed != 600 {
+		t.Errorf("elapsed = %v, want 600", elapsed)
+	}
+}
+
+func TestCalculateNormalizedPowerIntervals(t *testing.T) {
+	// 5 minutes at 300W followed by 5 minutes at 100W
+	watts := make([]float64, 600)
+	for i := range watts {
+		if i < 300 {
+			watts[i] = 300
+		} else {
+			watts[i] = 100
+		}
+	}
+
+	np, _ := CalculateNormalizedPower(watts, nil)
+	average := 200.0
+	if np <= average {
+		t.Errorf("NP = %v, want above average power %v for variable effort", np, average)
+	}
+	if np >= 300 {
+		t.Errorf("NP = %v, want below peak power 300", np)
+	}
+}
+
+func TestCalculateNormalizedPowerUsesElapsedTime(t *testing.T) {
+	// Two 60 second blocks separated by a 60 second stop (no samples recorded)
+	var watts []float64
+	var offsets []int
+	for i := 0; i < 60; i++ {
+		watts = append(watts, 250)
+		offsets = append(offsets, i)
+	}
+	for i := 120; i < 180; i++ {
+		watts = append(watts, 250)
+		offsets = append(offsets, i)
+	}
+
+	np, elapsed := CalculateNormalizedPower(watts, offsets)
+	if elapsed != 180 {
+		t.Errorf("elapsed = %v, want 180", elapsed)
+	}
+	if np >= 250 {
+		t.Errorf("NP = %v, want below 250 because the stop counts as zero watts", np)
+	}
+
+	// Smart recording gaps of a few seconds repeat the previous sample
+	np, elapsed = CalculateNormalizedPower([]float64{250, 250, 250, 250}, []int{0, 3, 40, 43})
+	if elapsed != 44 {
+		t.Errorf("elapsed = %v, want 44", elapsed)
+	}
+	if np <= 0 {
+		t.Errorf("NP = %v, want positive", np)
+	}
+}
+
+func TestCalculateNormalizedPowerTooShort(t *testing.T) {
+	np, elapsed := CalculateNormalizedPower([]float64{300, 300, 300}, nil)
+	if np != 0 || elapsed != 0 {
+		t.Errorf("CalculateNormalizedPower() = (%v, %v), want (0, 0) for short stream", np, elapsed)
+	}
+}
+
+func TestResampleToSeconds(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []float64
+		offsets []int
+		want    []float64
+	}{
+		{"1 Hz without offsets", []float64{1, 2, 3}, nil, []float64{1, 2, 3}},
+		{"short gap held", []float64{1, 2}, []int{0, 3}, []float64{1, 1, 1, 2}},
+		{"long gap zeroed", []float64{1, 2}, []int{0, 7}, []float64{1, 0, 0, 0, 0, 0, 0, 2}},
+		{"repeated offset skipped", []float64{1, 2, 3}, []int{0, 1, 1}, []float64{1, 2}},
+		{"offset going back skipped", []float64{1, 2, 3, 4}, []int{10, 12, 11, 13}, []float64{1, 1, 2, 4}},
+		{"offsets before the first skipped", []float64{1, 2, 3}, []int{5, 0, 6}, []float64{1, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResampleToSeconds(tt.values, tt.offsets); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ResampleToSeconds() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyPowerStream(t *testing.T) {
+	activity := &ActivityData{
+		FTP:         250,
+		MovingTime:  3000,
+		ElapsedTime: 3600,
+		NP:          220,
+		NPSource:    NPSourceStravaWeighted,
+	}
+
+	watts := make([]float64, 3600)
+	for i := range watts {
+		watts[i] = 250
+	}
+	ApplyPowerStream(activity, &ActivityStreams{Watts: watts})
+
+	if activity.NPSource != NPSourceStream {
+		t.Errorf("NPSource = %v, want %v", activity.NPSource, NPSourceStream)
+	}
+	if math.Abs(activity.NP-250) > 1e-9 {
+		t.Errorf("NP = %v, want 250", activity.NP)
+	}
+	if math.Abs(activity.IF-1) > 1e-9 {
+		t.Errorf("IF = %v, want 1", activity.IF)
+	}
+	// One hour at FTP is 100 TSS
+	if math.Abs(activity.TSS-100) > 1e-9 {
+		t.Errorf("TSS = %v, want 100", activity.TSS)
+	}
+
+	// Without a power stream the Strava weighted values are kept
+	fallback := &ActivityData{FTP: 250, NP: 220, NPSource: NPSourceStravaWeighted, TSS: 77}
+	ApplyPowerStream(fallback, &ActivityStreams{Heartrate: []float64{120}})
+	if fallback.NPSource != NPSourceStravaWeighted || fallback.NP != 220 || fallback.TSS != 77 {
+		t.Errorf("ApplyPowerStream() changed activity without power stream: %+v", fallback)
+	}
+}
+
+func TestApplyAerobicMetrics(t *testing.T) {
+	// A steady hour whose heart rate drifts from 130 to 143 bpm
+	watts := make([]float64, 3600)
+	heartrate := make([]float64, 3600)
+	for i := range watts {
+		watts[i] = 200
+		heartrate[i] = 130
+		if i >= 1800 {
+			heartrate[i] = 143
+		}
+	}
+	streams := &ActivityStreams{Watts: watts, Heartrate: heartrate}
+	activity := &ActivityData{NP: 200, NPSource: NPSourceStream}
+	ApplyAerobicMetrics(activity, streams)
+
+	if math.Abs(activity.VI-1) > 1e-9 {
+		t.Errorf("VI = %v, want 1 for a steady power", activity.VI)
+	}
+	if math.Abs(activity.EF-200/136.5) > 1e-9 {
+		t.Errorf("EF = %v, want %v", activity.EF, 200/136.5)
+	}
+	// 200/130 against 200/143 is a 9.09% drop in power per beat
+	if math.Abs(activity.Decoupling-(1-130.0/143)*100) > 1e-9 {
+		t.Errorf("Decoupling = %v, want %v", activity.Decoupling, (1-130.0/143)*100)
+	}
+
+	// Short activities get VI and EF but no decoupling
+	short := &ActivityData{NP: 200, NPSource: NPSourceStream}
+	ApplyAerobicMetrics(short, &ActivityStreams{Watts: watts[:600], Heartrate: heartrate[:600]})
+	if short.VI == 0 || short.EF == 0 || short.Decoupling != 0 {
+		t.Errorf("short = VI %v EF %v decoupling %v, want VI and EF only", short.VI, short.EF, short.Decoupling)
+	}
+
+	// Without heart rate only VI is set
+	powerOnly := &ActivityData{NP: 200, NPSource: NPSourceStream}
+	ApplyAerobicMetrics(powerOnly, &ActivityStreams{Watts: watts})
+	if powerOnly.VI == 0 || powerOnly.EF != 0 || powerOnly.Decoupling != 0 {
+		t.Errorf("powerOnly = VI %v EF %v decoupling %v, want VI only", powerOnly.VI, powerOnly.EF, powerOnly.Decoupling)
+	}
+
+	// The Strava weighted NP is not paired with the streams
+	weighted := &ActivityData{NP: 200, NPSource: NPSourceStravaWeighted}
+	ApplyAerobicMetrics(weighted, streams)
+	if weighted.VI != 0 || weighted.EF != 0 || weighted.Decoupling != 0 {
+		t.Errorf("weighted = %+v, want no metrics without a stream NP", weighted)
+	}
+}
+
+func TestConvertLaps(t *testing.T) {
+	watts := make([]float64, 600)
+	for i := range watts {
+		watts[i] = 150
+		if i >= 300 {
+			watts[i] = 300
+		}
+	}
+	activity := StravaActivity{
+		ID:   1,
+		Type: "Ride",
+		Laps: []Lap{
+			{Name: "Warmup", LapIndex: 1, StartDate: "2024-06-03T22:00:00Z", ElapsedTime: 300, StartIndex: 0, EndIndex: 299, AverageWatts: 150},
+			{Name: "Interval", LapIndex: 2, StartDate: "2024-06-03T22:05:00Z", ElapsedTime: 300, StartIndex: 300, EndIndex: 599, AverageWatts: 300},
+			{Name: "Out of range", LapIndex: 3, StartDate: "2024-06-03T22:10:00Z", StartIndex: 600, EndIndex: 700},
+		},
+	}
+
+	laps := ConvertLaps(activity, 300, &ActivityStreams{Watts: watts})
+	if len(laps) != 3 {
+		t.Fatalf("got %d laps, want 3", len(laps))
+	}
+	if math.Abs(laps[0].NP-150) > 1e-9 || math.Abs(laps[0].IF-0.5) > 1e-9 {
+		t.Errorf("warmup NP = %v, IF = %v, want 150 and 0.5", laps[0].NP, laps[0].IF)
+	}
+	if math.Abs(laps[1].NP-300) > 1e-9 || math.Abs(laps[1].IF-1) > 1e-9 {
+		t.Errorf("interval NP = %v, IF = %v, want 300 and 1", laps[1].NP, laps[1].IF)
+	}
+	if laps[2].NP != 0 {
+		t.Errorf("lap outside the stream NP = %v, want 0", laps[2].NP)
+	}
+	if laps[1].ActivityID != 1 || laps[1].ActivityType != "Ride" || laps[1].StartDate.Minute() != 5 {
+		t.Errorf("interval lap = %+v", laps[1])
+	}
+
+	// Without streams only Strava's averages are kept
+	if laps := ConvertLaps(activity, 300, nil); laps[1].NP != 0 || laps[1].AverageWatts != 300 {
+		t.Errorf("lap without streams = %+v", laps[1])
+	}
+}
+
+func TestConvertSegmentEfforts(t *testing.T) {
+	prRank := 2
+	activity := StravaActivity{
+		ID: 1,
+		SegmentEfforts: []SegmentEffort{
+			{ID: 10, StartDate: "2024-06-03T22:02:00Z", ElapsedTime: 300, PRRank: &prRank, Segment: Segment{ID: 7, Name: "Climb", AverageGrade: 4.2}},
+			{ID: 11, StartDate: "2024-06-03T22:08:00Z", ElapsedTime: 60, Segment: Segment{ID: 8, Name: "Sprint"}},
+		},
+	}
+
+	efforts := ConvertSegmentEfforts(activity)
+	if len(efforts) != 2 {
+		t.Fatalf("got %d efforts, want 2", len(efforts))
+	}
+	if efforts[0].SegmentID != 7 || efforts[0].SegmentName != "Climb" || efforts[0].PRRank != 2 || efforts[0].AverageGrade != 4.2 {
+		t.Errorf("first effort = %+v", efforts[0])
+	}
+	if efforts[1].PRRank != 0 || efforts[1].KOMRank != 0 {
+		t.Errorf("unranked effort = %+v, want zero ranks", efforts[1])
+	}
+}
+
+func TestCalculateTimeInZones(t *testing.T) {
+	zones := []ZoneRange{{Min: 0, Max: 150}, {Min: 150, Max: 250}, {Min: 250, Max: -1}}
+
+	// 1 Hz samples: 10 s in zone 1, 5 s in zone 2, 3 s in the open-ended zone 3
+	var watts []float64
+	for i := 0; i < 10; i++ {
+		watts = append(watts, 100)
+	}
+	for i := 0; i < 5; i++ {
+		watts = append(watts, 200)
+	}
+	watts = append(watts, 250, 400, 1000)
+
+	seconds := CalculateTimeInZones(watts, nil, zones)
+	if len(seconds) != 3 || seconds[0] != 10 || seconds[1] != 5 || seconds[2] != 3 {
+		t.Errorf("time in zones = %v, want [10 5 3]", seconds)
+	}
+
+	// Smart recording: a 4 s gap is held, a 60 s pause counts as one second
+	seconds = CalculateTimeInZones([]float64{100, 200, 300}, []int{0, 4, 64}, zones)
+	if seconds[0] != 4 || seconds[1] != 1 || seconds[2] != 1 {
+		t.Errorf("time in zones with gaps = %v, want [4 1 1]", seconds)
+	}
+
+	if CalculateTimeInZones(watts, nil, nil) != nil {
+		t.Error("CalculateTimeInZones() without zones should return nil")
+	}
+}
+
+func TestApplyZones(t *testing.T) {
+	zones := &AthleteZones{
+		HeartRate: &ZoneRanges{Zones: []ZoneRange{{Min: 0, Max: 140}, {Min: 140, Max: -1}}},
+		Power:     &ZoneRanges{Zones: []ZoneRange{{Min: 0, Max: 200}, {Min: 200, Max: -1}}},
+	}
+	streams := &ActivityStreams{
+		Watts:     []float64{0, 100, 250, 300},
+		Heartrate: []float64{0, 0, 130, 150},
+	}
+
+	activity := &ActivityData{}
+	ApplyZones(activity, streams, zones)
+
+	if len(activity.PowerZoneSeconds) != 2 || activity.PowerZoneSeconds[0] != 2 || activity.PowerZoneSeconds[1] != 2 {
+		t.Errorf("PowerZoneSeconds = %v, want [2 2]", activity.PowerZoneSeconds)
+	}
+	// Heart rate dropouts are not counted as zone 1
+	if len(activity.HeartRateZoneSeconds) != 2 || activity.HeartRateZoneSeconds[0] != 1 || activity.HeartRateZoneSeconds[1] != 1 {
+		t.Errorf("HeartRateZoneSeconds = %v, want [1 1]", activity.HeartRateZoneSeconds)
+	}
+	if zones.HeartRate.Zones[0].Min != 0 {
+		t.Error("ApplyZones() modified the athlete zones")
+	}
+
+	noPower := &ActivityData{}
+	ApplyZones(noPower, streams, &AthleteZones{HeartRate: zones.HeartRate})
+	if noPower.PowerZoneSeconds != nil {
+		t.Errorf("PowerZoneSeconds = %v without power zones, want nil", noPower.PowerZoneSeconds)
+	}
+}
+
+func TestCalculateWeeklySummaryTimeInZones(t *testing.T) {
+	weekStart := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+	activities := []ActivityData{
+		{StartDate: weekStart.Add(8 * time.Hour), TSS: 50, PowerZoneSeconds: []int{60, 120}, HeartRateZoneSeconds: []int{30}},
+		{StartDate: weekStart.Add(32 * time.Hour), TSS: 70, PowerZoneSeconds: []int{10, 20, 30}},
+		{StartDate: weekStart.Add(56 * time.Hour), TSS: 40, PowerZoneSeconds: []int{1000}, Deleted: true},
+	}
+
+	summary := CalculateWeeklySummary(activities, weekStart)
+	if summary.TotalTSS != 120 {
+		t.Errorf("TotalTSS = %v, want 120 without the deleted activity", summary.TotalTSS)
+	}
+	if len(summary.PowerZoneSeconds) != 3 || summary.PowerZoneSeconds[0] != 70 || summary.PowerZoneSeconds[1] != 140 || summary.PowerZoneSeconds[2] != 30 {
+		t.Errorf("PowerZoneSeconds = %v, want [70 140 30]", summary.PowerZoneSeconds)
+	}
+	if len(summary.HeartRateZoneSeconds) != 1 || summary.HeartRateZoneSeconds[0] != 30 {
+		t.Errorf("HeartRateZoneSeconds = %v, want [30]", summary.HeartRateZoneSeconds)
+	}
+}
+
+func TestCalculateWeeklySummaryLocalBoundaries(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("Asia/Tokyo timezone not available: %v", err)
+	}
+
+	// A 7 AM Monday ride in Tokyo is Sunday 22:00 UTC
+	mondayRide := time.Date(2024, 6, 3, 7, 0, 0, 0, tokyo)
+	weekStart := GetWeekStart(mondayRide)
+	if !weekStart.Equal(time.Date(2024, 6, 3, 0, 0, 0, 0, tokyo)) {
+		t.Fatalf("GetWeekStart() = %v, want Monday 2024-06-03 00:00 in Tokyo", weekStart)
+	}
+
+	activities := []ActivityData{
+		{StartDate: mondayRide.UTC(), TSS: 50},
+		{StartDate: weekStart.UTC(), TSS: 30},
+		{StartDate: weekStart.Add(-time.Second).UTC(), TSS: 100},
+		{StartDate: weekStart.AddDate(0, 0, 7).UTC(), TSS: 100},
+	}
+
+	summary := CalculateWeeklySummary(activities, weekStart)
+	if summary.TotalTSS != 80 {
+		t.Errorf("TotalTSS = %v, want 80 including the ride at the week start", summary.TotalTSS)
+	}
+}
+
+func TestCalculateMonthlySummaryTotals(t *testing.T) {
+	monthStart := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	activities := []ActivityData{
+		{ID: 1, StartDate: monthStart.Add(8 * time.Hour), TSS: 50, MovingTime: 3600, Distance: 30000, TotalElevationGain: 300, Kilojoules: 700},
+		{ID: 1, StartDate: monthStart.Add(8 * time.Hour), TSS: 50, MovingTime: 3600, Distance: 30000, TotalElevationGain: 300, Kilojoules: 700},
+		{ID: 2, StartDate: monthStart.AddDate(0, 0, 10), TSS: 70, MovingTime: 1800, Distance: 10000, TotalElevationGain: 100},
+		{ID: 3, StartDate: monthStart.AddDate(0, 1, 0), TSS: 100},
+	}
+
+	summary := CalculateMonthlySummary(activities, monthStart)
+	if summary.ActivityCount != 2 {
+		t.Errorf("ActivityCount = %d, want 2 without the repeated series", summary.ActivityCount)
+	}
+	if summary.TotalTSS != 120 || summary.TotalMovingTime != 5400 || summary.TotalDistance != 40000 {
+		t.Errorf("summary = %+v, want TSS 120, 5400 s and 40 km", summary)
+	}
+	if summary.TotalElevationGain != 400 || summary.TotalKilojoules != 700 {
+		t.Errorf("TotalElevationGain = %v, TotalKilojoules = %v, want 400 and 700", summary.TotalElevationGain, summary.TotalKilojoules)
+	}
+}
+
+func TestCalculateYearlySummaryBySport(t *testing.T) {
+	yearStart := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	activities := []ActivityData{
+		{ID: 1, Type: "Ride", StartDate: yearStart.AddDate(0, 1, 0), TSS: 80, MovingTime: 7200, Distance: 60000},
+		{ID: 2, Type: "VirtualRide", StartDate: yearStart.AddDate(0, 2, 0), TSS: 60, MovingTime: 3600, Distance: 30000},
+		{ID: 3, Type: "Run", StartDate: yearStart.AddDate(0, 3, 0), TSS: 50, MovingTime: 3000, Distance: 10000},
+		{ID: 4, Type: "Yoga", StartDate: yearStart.AddDate(0, 4, 0), TSS: 10, MovingTime: 1800},
+		{ID: 5, Type: "Run", StartDate: yearStart.AddDate(1, 0, 0), TSS: 100},
+	}
+
+	summary := CalculateYearlySummary(activities, yearStart)
+	if summary.TotalTSS != 200 || summary.ActivityCount != 4 {
+		t.Fatalf("summary = TSS %v count %d, want the combined TSS 200 of 4 activities", summary.TotalTSS, summary.ActivityCount)
+	}
+
+	wantTypes := []SportTotals{
+		{Type: "Ride", TotalTSS: 80, TotalMovingTime: 7200, TotalDistance: 60000, ActivityCount: 1},
+		{Type: "Run", TotalTSS: 50, TotalMovingTime: 3000, TotalDistance: 10000, ActivityCount: 1},
+		{Type: "VirtualRide", TotalTSS: 60, TotalMovingTime: 3600, TotalDistance: 30000, ActivityCount: 1},
+		{Type: "Yoga", TotalTSS: 10, TotalMovingTime: 1800, ActivityCount: 1},
+	}
+	if !reflect.DeepEqual(summary.ByType, wantTypes) {
+		t.Errorf("ByType = %+v, want %+v", summary.ByType, wantTypes)
+	}
+
+	wantSports := []SportTotals{
+		{Type: SportGroupOther, TotalTSS: 10, TotalMovingTime: 1800, ActivityCount: 1},
+		{Type: SportGroupRide, TotalTSS: 140, TotalMovingTime: 10800, TotalDistance: 90000, ActivityCount: 2},
+		{Type: SportGroupRun, TotalTSS: 50, TotalMovingTime: 3000, TotalDistance: 10000, ActivityCount: 1},
+	}
+	if !reflect.DeepEqual(summary.BySport, wantSports) {
+		t.Errorf("BySport = %+v, want %+v", summary.BySport, wantSports)
+	}
+}
+
+func TestCalculateRollingSummary(t *testing.T) {
+	end := time.Date(2024, 6, 29, 0, 0, 0, 0, time.UTC)
+	activities := []ActivityData{
+		{ID: 1, StartDate: end.Add(-2 * time.Hour), TSS: 50, MovingTime: 3600, Distance: 30000, TotalElevationGain: 300},
+		{ID: 2, StartDate: end.AddDate(0, 0, -7), TSS: 70, MovingTime: 1800, Distance: 10000, TotalElevationGain: 100},
+		{ID: 3, StartDate: end.AddDate(0, 0, -7).Add(-time.Second), TSS: 100, MovingTime: 7200},
+		{ID: 4, StartDate: end.AddDate(0, 0, -3), TSS: 40, Deleted: true},
+		{ID: 5, StartDate: end, TSS: 100},
+	}
+
+	week := CalculateRollingSummary(activities, end, 7)
+	if !week.Start.Equal(end.AddDate(0, 0, -7)) || !week.End.Equal(end) || week.Days != 7 {
+		t.Errorf("window = %v to %v (%d days), want the 7 days before %v", week.Start, week.End, week.Days, end)
+	}
+	if week.ActivityCount != 2 || week.TotalTSS != 120 || week.TotalMovingTime != 5400 || week.TotalDistance != 40000 || week.TotalElevationGain != 400 {
+		t.Errorf("7 day summary = %+v, want 2 activities with TSS 120, 5400 s, 40 km and 400 m", week)
+	}
+
+	if month := CalculateRollingSummary(activities, end, 28); month.ActivityCount != 3 || month.TotalTSS != 220 {
+		t.Errorf("28 day summary = %+v, want 3 activities with TSS 220", month)
+	}
+}
+
+func TestCalculateFitness(t *testing.T) {
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	activities := []ActivityData{
+		{ID: 1, StartDate: from.Add(8 * time.Hour), TSS: 100},
+		{ID: 2, StartDate: from.Add(18 * time.Hour), TSS: 50},
+		{ID: 3, StartDate: from.AddDate(0, 0, 2).Add(8 * time.Hour), TSS: 80, Deleted: true},
+	}
+
+	days := CalculateFitness(activities, FitnessDay{}, from, from.AddDate(0, 0, 2), 42, 7)
+	if len(days) != 3 {
+		t.Fatalf("got %d days, want 3", len(days))
+	}
+
+	ctl := 150 * (1 - math.Exp(-1.0/42))
+	atl := 150 * (1 - math.Exp(-1.0/7))
+	if days[0].TSS != 150 || math.Abs(days[0].CTL-ctl) > 1e-9 || math.Abs(days[0].ATL-atl) > 1e-9 || days[0].TSB != 0 {
+		t.Errorf("day 1 = %+v, want TSS 150, CTL %v, ATL %v and TSB 0", days[0], ctl, atl)
+	}
+	if math.Abs(days[1].TSB-(ctl-atl)) > 1e-9 || days[1].CTL >= days[0].CTL {
+		t.Errorf("day 2 = %+v, want TSB from day 1 and decaying CTL", days[1])
+	}
+	if days[2].TSS != 0 {
+		t.Errorf("day 3 TSS = %v, want 0 without the deleted activity", days[2].TSS)
+	}
+
+	// Continuing from a stored day gives the same series
+	continued := CalculateFitness(activities, days[0], from.AddDate(0, 0, 1), from.AddDate(0, 0, 2), 42, 7)
+	if continued[1] != days[2] {
+		t.Errorf("continued = %+v, want %+v", continued[1], days[2])
+	}
+}
+
+func TestApplyFTP(t *testing.T) {
+	activity := &ActivityData{NP: 250, FTP: 250, IF: 1, TSS: 100, MovingTime: 3600}
+	ApplyFTP(activity, 200)
+	if activity.FTP != 200 || activity.IF != 1.25 || math.Abs(activity.TSS-156.25) > 1e-9 {
+		t.Errorf("activity = %+v, want FTP 200, IF 1.25 and TSS 156.25", activity)
+	}
+
+	// Without a previous FTP the TSS is calculated from the duration
+	activity = &ActivityData{NP: 200, NPSource: NPSourceStream, ElapsedTime: 3600, MovingTime: 1800}
+	ApplyFTP(activity, 200)
+	if activity.TSS != 100 {
+		t.Errorf("TSS = %v, want 100 over the elapsed time", activity.TSS)
+	}
+}
+
+func TestCalculateHRTSS(t *testing.T) {
+	thresholds := Thresholds{LTHR: 165, RestingHR: 50, MaxHR: 190}
+
+	// An hour at the lactate threshold scores 100
+	heartrate := make([]float64, 3600)
+	for i := range heartrate {
+		heartrate[i] = 165
+	}
+	trimp := CalculateTRIMP(heartrate, nil, thresholds)
+	reserve := (165.0 - 50) / (190 - 50)
+	if want := 60 * reserve * 0.64 * math.Exp(1.92*reserve); math.Abs(trimp-want) > 1e-6 {
+		t.Errorf("CalculateTRIMP() = %v, want %v", trimp, want)
+	}
+	if tss := CalculateHRTSS(trimp, thresholds); math.Abs(tss-100) > 1e-6 {
+		t.Errorf("CalculateHRTSS() = %v, want 100", tss)
+	}
+
+	// Heart rate at or below resting adds nothing
+	if trimp := CalculateTRIMP([]float64{40, 50, 0}, nil, thresholds); trimp != 0 {
+		t.Errorf("CalculateTRIMP() = %v, want 0 at resting heart rate", trimp)
+	}
+	if trimp := CalculateTRIMP(heartrate, nil, Thresholds{LTHR: 165}); trimp != 0 {
+		t.Errorf("CalculateTRIMP() = %v, want 0 without resting and max heart rate", trimp)
+	}
+}
+
+func TestGradeAdjustedSpeeds(t *testing.T) {
+	streams := &ActivityStreams{
+		VelocitySmooth: []float64{3, 3, 3, 3},
+		Distance:       []float64{0, 30, 60, 90},
+		Altitude:       []float64{100, 100, 103, 100},
+	}
+
+	got := GradeAdjustedSpeeds(streams)
+	if got[0] != 3 || got[1] != 3 {
+		t.Errorf("flat speeds = %v, want 3", got[:2])
+	}
+	if got[2] <= 3 {
+		t.Errorf("uphill speed = %v, want more than 3", got[2])
+	}
+	if got[3] >= 3 {
+		t.Errorf("downhill speed = %v, want less than 3", got[3])
+	}
+
+	flat := GradeAdjustedSpeeds(&ActivityStreams{VelocitySmooth: []float64{3, 4}})
+	if flat[0] != 3 || flat[1] != 4 {
+		t.Errorf("speeds without altitude = %v, want the velocity unchanged", flat)
+	}
+}
+
+func TestApplyPaceLoad(t *testing.T) {
+	thresholds := Thresholds{ThresholdPace: 4, CriticalSwimSpeed: 1.25}
+
+	run := &ActivityData{Type: "Run", Distance: 14400, MovingTime: 3600}
+	ApplyPaceLoad(run, nil, thresholds)
+	if run.LoadModel != LoadModelRunPace || math.Abs(run.TSS-100) > 1e-6 || run.IF != 1 || run.NGP != 4 {
+		t.Errorf("run = %+v, want rTSS 100 at threshold pace", run)
+	}
+
+	streamed := &ActivityData{Type: "TrailRun", Distance: 3000, MovingTime: 1200, NP: 250, TSS: 40, LoadModel: LoadModelPower}
+	streams := &ActivityStreams{Time: make([]int, 1200), VelocitySmooth: make([]float64, 1200)}
+	for i := range streams.Time {
+		streams.Time[i] = i
+		streams.VelocitySmooth[i] = 2
+	}
+	ApplyPaceLoad(streamed, streams, thresholds)
+	if streamed.LoadModel != LoadModelRunPace || math.Abs(streamed.NGP-2) > 1e-6 || math.Abs(streamed.TSS-CalculateTSS(2, 4, 1200)) > 1e-6 {
+		t.Errorf("trail run = %+v, want rTSS from the normalized graded pace replacing the power TSS", streamed)
+	}
+
+	// A later FTP only updates the cycling threshold
+	ApplyFTP(streamed, 300)
+	if streamed.LoadModel != LoadModelRunPace || streamed.FTP != 300 || math.Abs(streamed.TSS-CalculateTSS(2, 4, 1200)) > 1e-6 {
+		t.Errorf("trail run = %+v, want the pace based TSS kept", streamed)
+	}
+
+	swim := &ActivityData{Type: "Swim", Distance: 2250, MovingTime: 1800}
+	ApplyPaceLoad(swim, nil, thresholds)
+	if swim.LoadModel != LoadModelSwimPace || math.Abs(swim.TSS-50) > 1e-6 || swim.ThresholdSpeed != 1.25 {
+		t.Errorf("swim = %+v, want sTSS 50 at critical swim speed for half an hour", swim)
+	}
+
+	ride := &ActivityData{Type: "Ride", Distance: 30000, MovingTime: 3600}
+	ApplyPaceLoad(ride, nil, thresholds)
+	if ride.LoadModel != "" || ride.TSS != 0 {
+		t.Errorf("ride = %+v, want no pace based load", ride)
+	}
+
+	unknown := &ActivityData{Type: "Run", Distance: 10000, MovingTime: 3000}
+	ApplyPaceLoad(unknown, nil, Thresholds{})
+	if unknown.LoadModel != "" {
+		t.Errorf("run = %+v, want no load without a threshold pace", unknown)
+	}
+}
+
+func TestApplyHeartRateLoad(t *testing.T) {
+	thresholds := Thresholds{LTHR: 165, RestingHR: 50, MaxHR: 190}
+
+	run := &ActivityData{Type: "Run", MovingTime: 3600, AverageHeartrate: 165}
+	ApplyHeartRateLoad(run, nil, thresholds)
+	if run.LoadModel != LoadModelHeartRate || math.Abs(run.TSS-100) > 1e-6 || run.LTHR != 165 {
+		t.Errorf("run = %+v, want hrTSS 100 from the average heart rate", run)
+	}
+
+	ride := &ActivityData{Type: "Ride", MovingTime: 3600, AverageHeartrate: 165, TSS: 80, LoadModel: LoadModelPower}
+	ApplyHeartRateLoad(ride, nil, thresholds)
+	if ride.TSS != 80 || ride.LoadModel != LoadModelPower || ride.TRIMP <= 0 {
+		t.Errorf("ride = %+v, want the power TSS kept and a TRIMP", ride)
+	}
+
+	// A power TSS replaces the heart rate one once an FTP is known
+	run.NP = 200
+	ApplyFTP(run, 250)
+	if run.LoadModel != LoadModelPower || run.TSS != CalculateTSS(200, 250, 3600) {
+		t.Errorf("run = %+v, want a power based TSS", run)
+	}
+}
+
+func TestApplyWeight(t *testing.T) {
+	ride := &ActivityData{Type: "Ride", NP: 210, FTP: 280, ElapsedTime: 3600, NPSource: NPSourceStream}
+	ApplyWeight(ride, 70)
+	if ride.Weight != 70 || ride.FTPPerKg != 4 || ride.NPPerKg != 3 {
+		t.Errorf("ride = %+v, want 4 W/kg FTP and 3 W/kg NP at 70 kg", ride)
+	}
+
+	// A new FTP from the history updates the FTP in W/kg
+	ApplyFTP(ride, 245)
+	if ride.FTPPerKg != 3.5 {
+		t.Errorf("FTPPerKg = %v after an FTP change, want 3.5", ride.FTPPerKg)
+	}
+
+	unknown := &ActivityData{NP: 210, FTP: 280}
+	ApplyWeight(unknown, 0)
+	if unknown.Weight != 0 || unknown.FTPPerKg != 0 || unknown.NPPerKg != 0 {
+		t.Errorf("unknown = %+v, want no W/kg without a weight", unknown)
+	}
+}
+
+func TestCalculateWorkload(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	var fitness []FitnessDay
+	for i := 0; i < 28; i++ {
+		tss := 0.0
+		if i%2 == 0 {
+			tss = 100
+		}
+		fitness = append(fitness, FitnessDay{Date: start.AddDate(0, 0, i), TSS: tss, TSB: float64(-i)})
+	}
+
+	days := CalculateWorkload(fitness, start.AddDate(0, 0, 5))
+	if len(days) != 23 || !days[0].Date.Equal(start.AddDate(0, 0, 5)) {
+		t.Fatalf("got %d days from %v, want 23 days from the 6th", len(days), days[0].Date)
+	}
+	if days[0].AcuteLoad != 0 || days[0].Monotony != 0 {
+		t.Errorf("day 6 = %+v, want no load before a full week", days[0])
+	}
+
+	// The 7th day covers 4 days of 100 and 3 rest days
+	week := days[1]
+	mean := 400.0 / 7
+	sd := math.Sqrt((4*(100-mean)*(100-mean) + 3*mean*mean) / 7)
+	if math.Abs(week.AcuteLoad-mean) > 1e-9 || math.Abs(week.Monotony-mean/sd) > 1e-9 || math.Abs(week.Strain-400*mean/sd) > 1e-9 {
+		t.Errorf("day 7 = %+v, want load %v, monotony %v", week, mean, mean/sd)
+	}
+	if week.ACWR != 0 || week.TSB != -6 {
+		t.Errorf("day 7 = %+v, want no ACWR yet and the fitness TSB", week)
+	}
+
+	// The 28th day fills the chronic window
+	last := days[len(days)-1]
+	if math.Abs(last.ChronicLoad-50) > 1e-9 || math.Abs(last.ACWR-last.AcuteLoad/50) > 1e-9 {
+		t.Errorf("day 28 = %+v, want a chronic load of 50", last)
+	}
+
+	// The same load every day has no defined monotony
+	steady := []FitnessDay{}
+	for i := 0; i < 7; i++ {
+		steady = append(steady, FitnessDay{Date: start.AddDate(0, 0, i), TSS: 80})
+	}
+	if day := CalculateWorkload(steady, start)[6]; day.Monotony != 0 || day.Strain != 0 || day.AcuteLoad != 80 {
+		t.Errorf("steady week = %+v, want load 80 without monotony", day)
+	}
+}
+
+func TestCalculatePowerCurve(t *testing.T) {
+	// 5 seconds at 400 W within 65 seconds at 200 W
+	watts := make([]float64, 65)
+	for i := range watts {
+		watts[i] = 200
+		if i >= 10 && i < 15 {
+			watts[i] = 400
+		}
+	}
+
+	curve := CalculatePowerCurve(watts, nil)
+	if len(curve) != len(PowerCurveDurations) {
+		t.Fatalf("len(curve) = %d, want %d", len(curve), len(PowerCurveDurations))
+	}
+	if curve[0] != 400 || curve[1] != 400 {
+		t.Errorf("1s/5s = %v/%v, want 400", curve[0], curve[1])
+	}
+	if want := (5*400.0 + 10*200) / 15; math.Abs(curve[2]-want) > 1e-9 {
+		t.Errorf("15s = %v, want %v", curve[2], want)
+	}
+	if want := (5*400.0 + 55*200) / 60; math.Abs(curve[4]-want) > 1e-9 {
+		t.Errorf("1m = %v, want %v", curve[4], want)
+	}
+	if curve[5] != 0 {
+		t.Errorf("2m = %v, want 0 for a shorter activity", curve[5])
+	}
+
+	// A recording gap breaks the effort
+	gapped := CalculatePowerCurve([]float64{300, 300, 300}, []int{0, 1, 120})
+	if gapped[1] >= 300 {
+		t.Errorf("5s across a gap = %v, want less than 300", gapped[1])
+	}
+
+	if CalculatePowerCurve(nil, nil) != nil {
+		t.Error("Expected nil curve without samples")
+	}
+}
+
+func TestCalculatePowerCurveBests(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	curve := func(id int64, date time.Time, watts float64) PowerCurve {
+		values := make([]float64, len(PowerCurveDurations))
+		for i := range values {
+			values[i] = watts
+		}
+		return PowerCurve{ActivityID: id, StartDate: date, Watts: values}
+	}
+	curves := []PowerCurve{
+		curve(1, time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC), 400),
+		curve(2, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), 350),
+		curve(3, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), 300),
+	}
+	curves[2].Weight = 60
+
+	bests := CalculatePowerCurveBests(curves, now, time.January)
+	if bests.RecentDays != 42 || !bests.SeasonStart.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("bests window = %d days, season from %v", bests.RecentDays, bests.SeasonStart)
+	}
+	if bests.Recent[0].Watts != 300 || bests.Recent[0].ActivityID != 3 || bests.Recent[0].WattsPerKg != 5 {
+		t.Errorf("recent best = %+v, want 300 W and 5 W/kg from activity 3", bests.Recent[0])
+	}
+	if bests.Season[0].Watts != 350 || bests.Season[0].ActivityID != 2 || bests.Season[0].WattsPerKg != 0 {
+		t.Errorf("season best = %+v, want 350 W from activity 2 without a weight", bests.Season[0])
+	}
+	if bests.AllTime[0].Watts != 400 || bests.AllTime[0].Duration != 1 {
+		t.Errorf("all-time best = %+v, want 400 W for 1 s", bests.AllTime[0])
+	}
+
+	// A season starting in October began in the previous year
+	if got := GetSeasonStart(now, time.October); !got.Equal(time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("GetSeasonStart() = %v, want 2023-10-01", got)
+	}
+}
+
+func TestFitCriticalPower(t *testing.T) {
+	var bests []PowerCurvePoint
+	for _, duration := range PowerCurveDurations {
+		bests = append(bests, PowerCurvePoint{Duration: duration, Watts: 300 + 18000/float64(duration)})
+	}
+
+	cp, wPrime, efforts, ok := FitCriticalPower(bests)
+	if !ok || efforts != 4 || math.Abs(cp-300) > 1e-6 || math.Abs(wPrime-18000) > 1e-3 {
+		t.Errorf("FitCriticalPower() = %v, %v, %d, %v, want 300 W and 18000 J from 4 efforts", cp, wPrime, efforts, ok)
+	}
+
+	if _, _, efforts, ok := FitCriticalPower([]PowerCurvePoint{{Duration: 300, Watts: 320}, {Duration: 3600, Watts: 280}}); ok || efforts != 1 {
+		t.Errorf("FitCriticalPower() = %d efforts, ok %v, want no fit from a single effort", efforts, ok)
+	}
+
+	// Longer efforts at higher power give no plausible W'
+	if _, _, _, ok := FitCriticalPower([]PowerCurvePoint{{Duration: 120, Watts: 200}, {Duration: 1200, Watts: 250}}); ok {
+		t.Error("FitCriticalPower() ok for an implausible curve")
+	}
+}
+
+func TestCalculateWPrimeBalance(t *testing.T) {
+	// 60 s at 100 W above CP, then 60 s at 100 W below CP
+	watts := make([]float64, 120)
+	for i := range watts {
+		watts[i] = 300
+		if i >= 60 {
+			watts[i] = 100
+		}
+	}
+
+	balance := CalculateWPrimeBalance(watts, nil, 200, 20000)
+	if len(balance) != len(watts) {
+		t.Fatalf("len(balance) = %d, want %d", len(balance), len(watts))
+	}
+	if balance[59] != 14000 {
+		t.Errorf("W'bal after the effort = %v, want 14000", balance[59])
+	}
+	want := 20000 - 6000*math.Exp(-100*60/20000.0)
+	if math.Abs(balance[119]-want) > 1e-6 {
+		t.Errorf("W'bal after recovery = %v, want %v", balance[119], want)
+	}
+
+	activity := &ActivityData{}
+	streams := &ActivityStreams{Watts: watts}
+	ApplyWPrimeBalance(activity, streams, 200, 20000)
+	if activity.WPrimeBalanceMin != 14000 || activity.CP != 200 || len(streams.WPrimeBalance) != 120 {
+		t.Errorf("activity = CP %v min %v, want the lowest W'bal 14000", activity.CP, activity.WPrimeBalanceMin)
+	}
+
+	if CalculateWPrimeBalance(watts, nil, 0, 20000) != nil {
+		t.Error("Expected no W'bal without CP")
+	}
+
+	// A long pause after a hard sample holds the power only briefly and
+	// recovers for the rest of the gap
+	paused := CalculateWPrimeBalance([]float64{400, 0, 0}, []int{0, 600, 601}, 250, 20000)
+	for i, value := range paused {
+		if value < 0 || value > 20000 {
+			t.Errorf("W'bal[%d] after a long pause = %v, want within [0, 20000]", i, value)
+		}
+	}
+	if want := 20000 - 150.0*maxHoldGapSeconds*math.Exp(-250*595/20000.0); math.Abs(paused[0]-want) > 1e-6 {
+		t.Errorf("W'bal after the pause = %v, want %v", paused[0], want)
+	}
+}
+
+func TestSuggestFTP(t *testing.T) {
+	loc := time.FixedZone("JST", 9*3600)
+	effort := time.Date(2024, 6, 1, 20, 0, 0, 0, time.UTC)
+	bests := []PowerCurvePoint{{Duration: 300, Watts: 320}, {Duration: 1200, Watts: 280, ActivityID: 7, Date: effort}}
+
+	suggestion := SuggestFTP(bests, nil, 250, loc)
+	if suggestion == nil || suggestion.FTP != 266 || suggestion.ActivityID != 7 || suggestion.Status != FTPSuggestionPending {
+		t.Fatalf("SuggestFTP() = %+v, want 266 W from the 20 minute effort", suggestion)
+	}
+	if suggestion.ID != "2024-06-02-20min" || !suggestion.Date.Equal(time.Date(2024, 6, 2, 0, 0, 0, 0, loc)) {
+		t.Errorf("suggestion dated %v (%s), want the local day of the effort", suggestion.Date, suggestion.ID)
+	}
+
+	fit := &CriticalPower{Date: time.Date(2024, 6, 3, 0, 0, 0, 0, loc), CP: 271.4}
+	if suggestion := SuggestFTP(bests, fit, 250, loc); suggestion == nil || suggestion.FTP != 271 || suggestion.Source != FTPSourceCriticalPower {
+		t.Errorf("SuggestFTP() = %+v, want 271 W from the higher critical power", suggestion)
+	}
+
+	if suggestion := SuggestFTP(bests, nil, 263, loc); suggestion != nil {
+		t.Errorf("SuggestFTP() = %+v, want nil within 2%% of the current FTP", suggestion)
+	}
+}
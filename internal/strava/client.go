@@ -1,13 +1,16 @@
 package strava
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"stravaDataImporter/internal/config"
@@ -15,27 +18,67 @@ import (
 	"golang.org/x/oauth2"
 )
 
+// Default Strava endpoints, used when the configuration leaves them empty
 const (
 	stravaBaseURL  = "https://www.strava.com/api/v3"
 	stravaAuthURL  = "https://www.strava.com/oauth/authorize"
 	stravaTokenURL = "https://www.strava.com/oauth/token"
 )
 
+// streamKeys lists the activity streams requested from Strava
+const streamKeys = "time,watts,heartrate,cadence,velocity_smooth,altitude,latlng,distance,temp,moving"
+
+// maxActivitiesPerPage is the largest page size accepted by /athlete/activities
+const maxActivitiesPerPage = 200
+
+var (
+	// ErrActivityNotFound is returned when Strava has no such activity
+	ErrActivityNotFound = errors.New("activity not found on Strava")
+	// ErrUnauthorized is returned when Strava rejects the athlete's
+	// authorization, even after refreshing the token
+	ErrUnauthorized = errors.New("authorization rejected by Strava")
+)
+
 type Client struct {
 	config      *config.Config
 	httpClient  *http.Client
 	oauthConfig *oauth2.Config
+	baseURL     string
+	tokenURL    string
+	rateLimiter *RateLimiter
+	tokenSource TokenSource
+}
+
+// TokenSource supplies the access token attached to API calls
+type TokenSource interface {
+	// Token returns a token that stays valid for at least a few more minutes
+	Token(ctx context.Context) (*TokenData, error)
+	// Refresh replaces a token that Strava rejected with 401
+	Refresh(ctx context.Context, rejected *TokenData) (*TokenData, error)
+}
+
+// ActivityListOptions holds the query parameters for /athlete/activities.
+// Zero values are omitted from the request.
+type ActivityListOptions struct {
+	Before  time.Time
+	After   time.Time
+	Page    int
+	PerPage int
 }
 
 func NewClient(cfg *config.Config) *Client {
+	baseURL := valueOrDefault(cfg.StravaAPIBaseURL, stravaBaseURL)
+	authURL := valueOrDefault(cfg.StravaAuthURL, stravaAuthURL)
+	tokenURL := valueOrDefault(cfg.StravaTokenURL, stravaTokenURL)
+
 	oauthConfig := &oauth2.Config{
 		ClientID:     cfg.StravaClientID,
 		ClientSecret: cfg.StravaClientSecret,
 		RedirectURL:  cfg.StravaRedirectURL,
 		Scopes:       []string{"read,activity:read_all"},
 		Endpoint: oauth2.Endpoint{
-			AuthURL:  stravaAuthURL,
-			TokenURL: stravaTokenURL,
+			AuthURL:  authURL,
+			TokenURL: tokenURL,
 		},
 	}
 
@@ -43,21 +86,113 @@ func NewClient(cfg *config.Config) *Client {
 		config:      cfg,
 		httpClient:  &http.Client{Timeout: 30 * time.Second},
 		oauthConfig: oauthConfig,
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		tokenURL:    tokenURL,
+		rateLimiter: sharedRateLimiter,
+	}
+}
+
+func valueOrDefault(value, defaultValue string) string {
+	if value != "" {
+		return value
 	}
+	return defaultValue
+}
+
+// SetTokenSource sets the source of access tokens for athlete API calls
+func (c *Client) SetTokenSource(tokenSource TokenSource) {
+	c.tokenSource = tokenSource
+}
+
+// RateLimiter returns the Strava API budget shared by all clients
+func (c *Client) RateLimiter() *RateLimiter {
+	return c.rateLimiter
+}
+
+// do sends an API request within the shared rate limit budget. Requests
+// rejected with 429 are retried once the next rate limit window opens.
+// Waiting is cancelled together with the request's context.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := c.rateLimiter.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		c.rateLimiter.Update(resp.Header)
+
+		if resp.StatusCode != http.StatusTooManyRequests || attempt >= maxRateLimitRetries {
+			return resp, nil
+		}
+
+		_ = resp.Body.Close()
+		c.rateLimiter.Exhausted()
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			req.Body = body
+		}
+	}
+}
+
+// doAuthorized sends an athlete API request with a token from the token
+// source. A 401 response triggers one refresh and retry.
+func (c *Client) doAuthorized(req *http.Request) (*http.Response, error) {
+	if c.tokenSource == nil {
+		return nil, fmt.Errorf("no token source configured")
+	}
+
+	token, err := c.tokenSource.Token(req.Context())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	resp, err := c.do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	_ = resp.Body.Close()
+
+	slog.Warn("Strava rejected the access token, refreshing", "athlete_id", token.AthleteID)
+	token, err = c.tokenSource.Refresh(req.Context(), token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh access token: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	return c.do(req)
 }
 
 func (c *Client) GetAuthURL(state string) string {
 	return c.oauthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline)
 }
 
-func (c *Client) ExchangeCodeForToken(code string) (*TokenData, error) {
+// postTokenForm posts an OAuth grant to the token endpoint
+func (c *Client) postTokenForm(ctx context.Context, data url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.tokenURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return c.httpClient.Do(req)
+}
+
+func (c *Client) ExchangeCodeForToken(ctx context.Context, code string) (*TokenData, error) {
 	data := url.Values{}
 	data.Set("client_id", c.config.StravaClientID)
 	data.Set("client_secret", c.config.StravaClientSecret)
 	data.Set("code", code)
 	data.Set("grant_type", "authorization_code")
 
-	resp, err := c.httpClient.PostForm(stravaTokenURL, data)
+	resp, err := c.postTokenForm(ctx, data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to exchange code for token: %w", err)
 	}
@@ -82,19 +217,23 @@ func (c *Client) ExchangeCodeForToken(code string) (*TokenData, error) {
 	}, nil
 }
 
-func (c *Client) RefreshToken(refreshToken string) (*TokenData, error) {
+func (c *Client) RefreshToken(ctx context.Context, refreshToken string) (*TokenData, error) {
 	data := url.Values{}
 	data.Set("client_id", c.config.StravaClientID)
 	data.Set("client_secret", c.config.StravaClientSecret)
 	data.Set("refresh_token", refreshToken)
 	data.Set("grant_type", "refresh_token")
 
-	resp, err := c.httpClient.PostForm(stravaTokenURL, data)
+	resp, err := c.postTokenForm(ctx, data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to refresh token: %w", err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%w: token refresh failed with status %d: %s", ErrUnauthorized, resp.StatusCode, string(body))
+	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("token refresh failed with status %d: %s", resp.StatusCode, string(body))
@@ -114,20 +253,33 @@ func (c *Client) RefreshToken(refreshToken string) (*TokenData, error) {
 	}, nil
 }
 
-func (c *Client) GetActivities(accessToken string, after time.Time, perPage int) ([]StravaActivity, error) {
-	req, err := http.NewRequest("GET", stravaBaseURL+"/athlete/activities", nil)
+func (c *Client) GetActivities(ctx context.Context, after time.Time, perPage int) ([]StravaActivity, error) {
+	return c.ListActivities(ctx, ActivityListOptions{After: after, PerPage: perPage})
+}
+
+// ListActivities fetches a single page of the athlete's activities
+func (c *Client) ListActivities(ctx context.Context, opts ActivityListOptions) ([]StravaActivity, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/athlete/activities", nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	q := req.URL.Query()
-	q.Add("after", strconv.FormatInt(after.Unix(), 10))
-	q.Add("per_page", strconv.Itoa(perPage))
+	if !opts.Before.IsZero() {
+		q.Add("before", strconv.FormatInt(opts.Before.Unix(), 10))
+	}
+	if !opts.After.IsZero() {
+		q.Add("after", strconv.FormatInt(opts.After.Unix(), 10))
+	}
+	if opts.Page > 0 {
+		q.Add("page", strconv.Itoa(opts.Page))
+	}
+	if opts.PerPage > 0 {
+		q.Add("per_page", strconv.Itoa(opts.PerPage))
+	}
 	req.URL.RawQuery = q.Encode()
 
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.doAuthorized(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch activities: %w", err)
 	}
@@ -143,25 +295,101 @@ func (c *Client) GetActivities(accessToken string, after time.Time, perPage int)
 		return nil, fmt.Errorf("failed to decode activities response: %w", err)
 	}
 
-	slog.Info("Fetched activities from Strava", "count", len(activities))
+	slog.Info("Fetched activities from Strava", "count", len(activities), "page", opts.Page, "before", opts.Before, "after", opts.After)
 	return activities, nil
 }
 
-func (c *Client) GetActivity(accessToken string, activityID int64) (*StravaActivity, error) {
-	url := fmt.Sprintf("%s/activities/%d", stravaBaseURL, activityID)
-	req, err := http.NewRequest("GET", url, nil)
+// BackfillActivities walks the athlete's history backwards from before until
+// Strava returns an empty page. Each page is requested with a "before" cursor
+// one second after the oldest activity of the previous page, so uploads made
+// while the walk is running cannot shift the page boundaries and activities
+// starting in the same second as the oldest one are not skipped. Activities
+// already seen on the previous page are dropped. handle receives every page
+// together with the cursor to resume from; returning an error stops the walk.
+func (c *Client) BackfillActivities(ctx context.Context, before time.Time, perPage int, handle func(activities []StravaActivity, next time.Time) error) error {
+	if before.IsZero() {
+		before = time.Now()
+	}
+	if perPage <= 0 || perPage > maxActivitiesPerPage {
+		perPage = maxActivitiesPerPage
+	}
+
+	var seen map[int64]bool
+	for {
+		activities, err := c.ListActivities(ctx, ActivityListOptions{Before: before, Page: 1, PerPage: perPage})
+		if err != nil {
+			return err
+		}
+
+		if len(activities) == 0 {
+			slog.Info("Reached the end of the activity history", "before", before)
+			return nil
+		}
+
+		oldest := oldestStartDate(activities)
+		if oldest.IsZero() || !oldest.Before(before) {
+			return fmt.Errorf("backfill cursor did not advance past %s", before.Format(time.RFC3339))
+		}
+
+		fresh := make([]StravaActivity, 0, len(activities))
+		for _, activity := range activities {
+			if !seen[activity.ID] {
+				fresh = append(fresh, activity)
+			}
+		}
+		seen = make(map[int64]bool, len(activities))
+		for _, activity := range activities {
+			seen[activity.ID] = true
+		}
+
+		// Include the oldest second again unless the page holds nothing new
+		// or only that second, where the cursor would not move
+		next := oldest.Add(time.Second)
+		if len(fresh) == 0 || !next.Before(before) {
+			next = oldest
+		}
+
+		if len(fresh) > 0 {
+			if err := handle(fresh, next); err != nil {
+				return err
+			}
+		}
+
+		before = next
+	}
+}
+
+// oldestStartDate returns the earliest parsable start date in activities
+func oldestStartDate(activities []StravaActivity) time.Time {
+	var oldest time.Time
+	for _, activity := range activities {
+		startDate, err := time.Parse(time.RFC3339, activity.StartDate)
+		if err != nil {
+			continue
+		}
+		if oldest.IsZero() || startDate.Before(oldest) {
+			oldest = startDate
+		}
+	}
+	return oldest
+}
+
+func (c *Client) GetActivity(ctx context.Context, activityID int64) (*StravaActivity, error) {
+	url := fmt.Sprintf("%s/activities/%d", c.baseURL, activityID)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.doAuthorized(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch activity: %w", err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("activity %d: %w", activityID, ErrActivityNotFound)
+	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("activity request failed with status %d: %s", resp.StatusCode, string(body))
@@ -175,20 +403,100 @@ func (c *Client) GetActivity(accessToken string, activityID int64) (*StravaActiv
 	return &activity, nil
 }
 
-func (c *Client) GetAthlete(accessToken string) (*AthleteInfo, error) {
-	req, err := http.NewRequest("GET", stravaBaseURL+"/athlete", nil)
+// GetGear fetches a bike or a pair of shoes of the athlete
+func (c *Client) GetGear(ctx context.Context, gearID string) (*Gear, error) {
+	url := fmt.Sprintf("%s/gear/%s", c.baseURL, url.PathEscape(gearID))
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.doAuthorized(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch gear: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("gear request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var gear Gear
+	if err := json.NewDecoder(resp.Body).Decode(&gear); err != nil {
+		return nil, fmt.Errorf("failed to decode gear response: %w", err)
+	}
+
+	return &gear, nil
+}
+
+// GetActivityStreams fetches the recorded time series of an activity.
+// It returns nil when the activity has no streams, e.g. manual entries.
+func (c *Client) GetActivityStreams(ctx context.Context, activityID int64) (*ActivityStreams, error) {
+	url := fmt.Sprintf("%s/activities/%d/streams", c.baseURL, activityID)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+accessToken)
+	q := req.URL.Query()
+	q.Add("keys", streamKeys)
+	q.Add("key_by_type", "true")
+	req.URL.RawQuery = q.Encode()
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.doAuthorized(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch activity streams: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotFound {
+		slog.Debug("Activity has no streams", "activity_id", activityID)
+		return nil, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("activity streams request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var streamSet streamSetResponse
+	if err := json.NewDecoder(resp.Body).Decode(&streamSet); err != nil {
+		return nil, fmt.Errorf("failed to decode activity streams response: %w", err)
+	}
+
+	streams := &ActivityStreams{
+		Time:           streamSet.Time.Data,
+		Watts:          streamSet.Watts.Data,
+		Heartrate:      streamSet.Heartrate.Data,
+		Cadence:        streamSet.Cadence.Data,
+		VelocitySmooth: streamSet.VelocitySmooth.Data,
+		Altitude:       streamSet.Altitude.Data,
+		LatLng:         streamSet.LatLng.Data,
+		Distance:       streamSet.Distance.Data,
+		Temp:           streamSet.Temp.Data,
+		Moving:         streamSet.Moving.Data,
+	}
+
+	slog.Debug("Fetched activity streams from Strava", "activity_id", activityID, "samples", streams.Len())
+	return streams, nil
+}
+
+func (c *Client) GetAthlete(ctx context.Context) (*AthleteInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/athlete", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.doAuthorized(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch athlete: %w", err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("athlete request: %w", ErrUnauthorized)
+	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("athlete request failed with status %d: %s", resp.StatusCode, string(body))
@@ -202,6 +510,130 @@ func (c *Client) GetAthlete(accessToken string) (*AthleteInfo, error) {
 	return &athlete, nil
 }
 
+// GetAthleteZones fetches the athlete's heart rate and power zones. Power
+// zones are nil when the athlete has no FTP set on Strava.
+func (c *Client) GetAthleteZones(ctx context.Context) (*AthleteZones, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/athlete/zones", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.doAuthorized(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch athlete zones: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("athlete zones request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var zones AthleteZones
+	if err := json.NewDecoder(resp.Body).Decode(&zones); err != nil {
+		return nil, fmt.Errorf("failed to decode athlete zones response: %w", err)
+	}
+
+	return &zones, nil
+}
+
+// CreatePushSubscription registers callbackURL as the application's webhook.
+// Strava validates the callback with a GET challenge before responding.
+func (c *Client) CreatePushSubscription(ctx context.Context, callbackURL, verifyToken string) (*PushSubscription, error) {
+	data := url.Values{}
+	data.Set("client_id", c.config.StravaClientID)
+	data.Set("client_secret", c.config.StravaClientSecret)
+	data.Set("callback_url", callbackURL)
+	data.Set("verify_token", verifyToken)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/push_subscriptions", strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create push subscription: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("push subscription request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var subscription PushSubscription
+	if err := json.NewDecoder(resp.Body).Decode(&subscription); err != nil {
+		return nil, fmt.Errorf("failed to decode push subscription response: %w", err)
+	}
+	if subscription.CallbackURL == "" {
+		subscription.CallbackURL = callbackURL
+	}
+
+	slog.Info("Push subscription created", "subscription_id", subscription.ID, "callback_url", subscription.CallbackURL)
+	return &subscription, nil
+}
+
+// ListPushSubscriptions returns the application's webhook subscriptions
+func (c *Client) ListPushSubscriptions(ctx context.Context) ([]PushSubscription, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/push_subscriptions", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	q := req.URL.Query()
+	q.Add("client_id", c.config.StravaClientID)
+	q.Add("client_secret", c.config.StravaClientSecret)
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch push subscriptions: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("push subscriptions request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var subscriptions []PushSubscription
+	if err := json.NewDecoder(resp.Body).Decode(&subscriptions); err != nil {
+		return nil, fmt.Errorf("failed to decode push subscriptions response: %w", err)
+	}
+
+	return subscriptions, nil
+}
+
+// DeletePushSubscription removes a webhook subscription
+func (c *Client) DeletePushSubscription(ctx context.Context, subscriptionID int64) error {
+	url := fmt.Sprintf("%s/push_subscriptions/%d", c.baseURL, subscriptionID)
+	req, err := http.NewRequestWithContext(ctx, "DELETE", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	q := req.URL.Query()
+	q.Add("client_id", c.config.StravaClientID)
+	q.Add("client_secret", c.config.StravaClientSecret)
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete push subscription: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("push subscription delete failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	slog.Info("Push subscription deleted", "subscription_id", subscriptionID)
+	return nil
+}
+
 // ConvertToActivityData converts StravaActivity to ActivityData
 func ConvertToActivityData(stravaActivity StravaActivity, ftp float64) (*ActivityData, error) {
 	startDate, err := time.Parse(time.RFC3339, stravaActivity.StartDate)
@@ -209,6 +641,15 @@ func ConvertToActivityData(stravaActivity StravaActivity, ftp float64) (*Activit
 		return nil, fmt.Errorf("failed to parse start date: %w", err)
 	}
 
+	// Manual and older activities may lack the local start date
+	var startDateLocal time.Time
+	if stravaActivity.StartDateLocal != "" {
+		startDateLocal, err = time.Parse(time.RFC3339, stravaActivity.StartDateLocal)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse local start date: %w", err)
+		}
+	}
+
 	activity := &ActivityData{
 		ID:                   stravaActivity.ID,
 		Name:                 stravaActivity.Name,
@@ -227,14 +668,23 @@ func ConvertToActivityData(stravaActivity StravaActivity, ftp float64) (*Activit
 		MaxWatts:             stravaActivity.MaxWatts,
 		WeightedAverageWatts: stravaActivity.WeightedAverageWatts,
 		Kilojoules:           stravaActivity.Kilojoules,
+		GearID:               stravaActivity.GearID,
+		StartDateLocal:       startDateLocal,
+		Timezone:             stravaActivity.Timezone,
 		FTP:                  ftp,
 	}
 
-	// Calculate TSS and NP
-	if ftp > 0 && stravaActivity.WeightedAverageWatts > 0 {
+	// Calculate TSS and NP from Strava's weighted average power.
+	// ApplyPowerStream replaces these when a power stream is available.
+	// NP is kept without an FTP so that a later FTP can score the activity.
+	if stravaActivity.WeightedAverageWatts > 0 {
 		activity.NP = stravaActivity.WeightedAverageWatts
-		intensityFactor := activity.NP / ftp
-		activity.TSS = (float64(stravaActivity.MovingTime) * activity.NP * intensityFactor) / (ftp * 3600) * 100
+		activity.NPSource = NPSourceStravaWeighted
+	}
+	if ftp > 0 && activity.NP > 0 {
+		activity.LoadModel = LoadModelPower
+		activity.IF = CalculateIntensityFactor(activity.NP, ftp)
+		activity.TSS = (float64(stravaActivity.MovingTime) * activity.NP * activity.IF) / (ftp * 3600) * 100
 	}
 
 	return activity, nil
@@ -1,7 +1,15 @@
 package strava
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
 	"testing"
+	"time"
 
 	"stravaDataImporter/internal/config"
 )
@@ -87,6 +95,10 @@ func TestConvertToActivityData(t *testing.T) {
 		t.Errorf("Activity NP = %v, want %v", activity.NP, 220)
 	}
 
+	if activity.NPSource != NPSourceStravaWeighted {
+		t.Errorf("Activity NPSource = %v, want %v", activity.NPSource, NPSourceStravaWeighted)
+	}
+
 	// TSS should be calculated as (movingTime * NP * IF) / (FTP * 3600) * 100
 	// IF = NP / FTP = 220 / 250 = 0.88
 	// TSS = (3600 * 220 * 0.88) / (250 * 3600) * 100 = 77.44
@@ -96,6 +108,52 @@ func TestConvertToActivityData(t *testing.T) {
 	}
 }
 
+func TestConvertToActivityDataLocalStartDate(t *testing.T) {
+	stravaActivity := StravaActivity{
+		ID:             123456,
+		StartDate:      "2024-06-02T22:00:00Z",
+		StartDateLocal: "2024-06-03T07:00:00Z",
+		Timezone:       "(GMT+09:00) Asia/Tokyo",
+	}
+
+	activity, err := ConvertToActivityData(stravaActivity, 250.0)
+	if err != nil {
+		t.Fatalf("ConvertToActivityData() error = %v", err)
+	}
+	if !activity.StartDateLocal.Equal(time.Date(2024, 6, 3, 7, 0, 0, 0, time.UTC)) {
+		t.Errorf("StartDateLocal = %v, want 2024-06-03 07:00 wall clock", activity.StartDateLocal)
+	}
+	if activity.Timezone != "(GMT+09:00) Asia/Tokyo" {
+		t.Errorf("Timezone = %q", activity.Timezone)
+	}
+}
+
+func TestConvertToActivityDataWithoutFTP(t *testing.T) {
+	stravaActivity := StravaActivity{
+		ID:                   123456,
+		StartDate:            "2024-06-02T22:00:00Z",
+		MovingTime:           3600,
+		WeightedAverageWatts: 220,
+	}
+
+	activity, err := ConvertToActivityData(stravaActivity, 0)
+	if err != nil {
+		t.Fatalf("ConvertToActivityData() error = %v", err)
+	}
+	if activity.NP != 220 || activity.NPSource != NPSourceStravaWeighted {
+		t.Errorf("NP = %v from %q, want 220 from Strava's weighted average", activity.NP, activity.NPSource)
+	}
+	if activity.IF != 0 || activity.TSS != 0 || activity.LoadModel != "" {
+		t.Errorf("IF = %v, TSS = %v, load model = %q, want none without an FTP", activity.IF, activity.TSS, activity.LoadModel)
+	}
+
+	// A later FTP scores the activity
+	ApplyFTP(activity, 200)
+	if activity.TSS <= 0 || activity.IF != 1.1 {
+		t.Errorf("after ApplyFTP: IF = %v, TSS = %v, want the activity scored", activity.IF, activity.TSS)
+	}
+}
+
 func TestConvertToActivityDataInvalidDate(t *testing.T) {
 	stravaActivity := StravaActivity{
 		ID:        123456,
@@ -109,6 +167,338 @@ func TestConvertToActivityDataInvalidDate(t *testing.T) {
 	}
 }
 
+func TestListActivitiesQuery(t *testing.T) {
+	var query map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/athlete/activities" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		query = map[string]string{}
+		for key := range r.URL.Query() {
+			query[key] = r.URL.Query().Get(key)
+		}
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	client := NewClient(&config.Config{})
+	client.baseURL = server.URL
+	client.SetTokenSource(&staticTokenSource{token: &TokenData{AccessToken: "token"}})
+
+	before := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	_, err := client.ListActivities(context.Background(), ActivityListOptions{Before: before, Page: 3, PerPage: 50})
+	if err != nil {
+		t.Fatalf("ListActivities() error = %v", err)
+	}
+
+	expected := map[string]string{
+		"before":   strconv.FormatInt(before.Unix(), 10),
+		"page":     "3",
+		"per_page": "50",
+	}
+	if len(query) != len(expected) {
+		t.Errorf("query = %v, want %v", query, expected)
+	}
+	for key, want := range expected {
+		if query[key] != want {
+			t.Errorf("query[%s] = %v, want %v", key, query[key], want)
+		}
+	}
+}
+
+func TestBackfillActivities(t *testing.T) {
+	history := []StravaActivity{
+		{ID: 5, StartDate: "2024-05-05T08:00:00Z"},
+		{ID: 4, StartDate: "2024-05-04T08:00:00Z"},
+		{ID: 3, StartDate: "2024-05-03T08:00:00Z"},
+		{ID: 2, StartDate: "2024-05-02T08:00:00Z"},
+		{ID: 1, StartDate: "2024-05-01T08:00:00Z"},
+	}
+
+	client, requests := newHistoryClient(t, history)
+
+	var imported []int64
+	var cursors []time.Time
+	err := client.BackfillActivities(context.Background(), time.Time{}, 2, func(activities []StravaActivity, next time.Time) error {
+		for _, activity := range activities {
+			imported = append(imported, activity.ID)
+		}
+		cursors = append(cursors, next)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("BackfillActivities() error = %v", err)
+	}
+
+	if len(imported) != len(history) {
+		t.Fatalf("imported %d activities, want %d", len(imported), len(history))
+	}
+	for i, activity := range history {
+		if imported[i] != activity.ID {
+			t.Errorf("imported[%d] = %d, want %d", i, imported[i], activity.ID)
+		}
+	}
+
+	// Each page repeats the oldest activity of the previous one; the last
+	// page holds nothing new and the walk ends with an empty page
+	if *requests != 6 {
+		t.Errorf("requests = %d, want 6", *requests)
+	}
+
+	lastCursor := time.Date(2024, 5, 1, 8, 0, 1, 0, time.UTC)
+	if !cursors[len(cursors)-1].Equal(lastCursor) {
+		t.Errorf("last cursor = %v, want %v", cursors[len(cursors)-1], lastCursor)
+	}
+}
+
+func TestBackfillActivitiesSameSecond(t *testing.T) {
+	// Activities 3 and 2 start in the same second across a page boundary
+	history := []StravaActivity{
+		{ID: 4, StartDate: "2024-05-04T08:00:00Z"},
+		{ID: 3, StartDate: "2024-05-03T08:00:00Z"},
+		{ID: 2, StartDate: "2024-05-03T08:00:00Z"},
+		{ID: 1, StartDate: "2024-05-01T08:00:00Z"},
+	}
+	client, _ := newHistoryClient(t, history)
+
+	var imported []int64
+	err := client.BackfillActivities(context.Background(), time.Time{}, 2, func(activities []StravaActivity, next time.Time) error {
+		for _, activity := range activities {
+			imported = append(imported, activity.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("BackfillActivities() error = %v", err)
+	}
+	if !reflect.DeepEqual(imported, []int64{4, 3, 2, 1}) {
+		t.Errorf("imported = %v, want every activity once", imported)
+	}
+}
+
+// newHistoryClient returns a client for a server that lists history newest
+// first with Strava's exclusive "before" cursor, and the request counter
+func newHistoryClient(t *testing.T, history []StravaActivity) (*Client, *int) {
+	t.Helper()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		before, _ := strconv.ParseInt(r.URL.Query().Get("before"), 10, 64)
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+
+		page := []StravaActivity{}
+		for _, activity := range history {
+			startDate, _ := time.Parse(time.RFC3339, activity.StartDate)
+			if startDate.Unix() < before && len(page) < perPage {
+				page = append(page, activity)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(page)
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient(&config.Config{})
+	client.baseURL = server.URL
+	client.SetTokenSource(&staticTokenSource{token: &TokenData{AccessToken: "token"}})
+	return client, &requests
+}
+
+func TestGetActivityStreams(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/activities/1/streams":
+			if r.URL.Query().Get("key_by_type") != "true" {
+				t.Errorf("key_by_type = %q, want true", r.URL.Query().Get("key_by_type"))
+			}
+			_, _ = w.Write([]byte(`{
+				"time": {"data": [0, 1, 2], "series_type": "distance", "original_size": 3, "resolution": "high"},
+				"watts": {"data": [200, null, 220], "series_type": "distance", "original_size": 3, "resolution": "high"},
+				"latlng": {"data": [[35.1, 139.1], [35.2, 139.2], [35.3, 139.3]], "series_type": "distance", "original_size": 3, "resolution": "high"},
+				"moving": {"data": [false, true, true], "series_type": "distance", "original_size": 3, "resolution": "high"}
+			}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(&config.Config{})
+	client.baseURL = server.URL
+	client.SetTokenSource(&staticTokenSource{token: &TokenData{AccessToken: "token"}})
+
+	streams, err := client.GetActivityStreams(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GetActivityStreams() error = %v", err)
+	}
+
+	if streams.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", streams.Len())
+	}
+	if streams.Watts[1] != 0 || streams.Watts[2] != 220 {
+		t.Errorf("Watts = %v, want [200 0 220]", streams.Watts)
+	}
+	if streams.LatLng[2][1] != 139.3 {
+		t.Errorf("LatLng[2] = %v, want [35.3 139.3]", streams.LatLng[2])
+	}
+	if !streams.Moving[1] {
+		t.Error("Moving[1] = false, want true")
+	}
+	if streams.Heartrate != nil {
+		t.Errorf("Heartrate = %v, want nil", streams.Heartrate)
+	}
+
+	// Manual activities have no streams
+	streams, err = client.GetActivityStreams(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("GetActivityStreams() error = %v", err)
+	}
+	if streams != nil {
+		t.Errorf("GetActivityStreams() = %v, want nil for missing streams", streams)
+	}
+}
+
+func TestPushSubscriptions(t *testing.T) {
+	var subscriptions []PushSubscription
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/push_subscriptions":
+			if err := r.ParseForm(); err != nil {
+				t.Fatalf("ParseForm() error = %v", err)
+			}
+			if r.PostForm.Get("client_id") != "test_client_id" || r.PostForm.Get("verify_token") != "verify" {
+				t.Errorf("unexpected form %v", r.PostForm)
+			}
+			subscription := PushSubscription{ID: 42, CallbackURL: r.PostForm.Get("callback_url")}
+			subscriptions = append(subscriptions, subscription)
+			_ = json.NewEncoder(w).Encode(map[string]int64{"id": subscription.ID})
+		case r.Method == "GET" && r.URL.Path == "/push_subscriptions":
+			if r.URL.Query().Get("client_secret") != "test_client_secret" {
+				t.Errorf("client_secret = %q", r.URL.Query().Get("client_secret"))
+			}
+			_ = json.NewEncoder(w).Encode(subscriptions)
+		case r.Method == "DELETE" && r.URL.Path == "/push_subscriptions/42":
+			subscriptions = nil
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(&config.Config{
+		StravaClientID:     "test_client_id",
+		StravaClientSecret: "test_client_secret",
+	})
+	client.baseURL = server.URL
+
+	subscription, err := client.CreatePushSubscription(context.Background(), "https://example.com/webhook/strava", "verify")
+	if err != nil {
+		t.Fatalf("CreatePushSubscription() error = %v", err)
+	}
+	if subscription.ID != 42 || subscription.CallbackURL != "https://example.com/webhook/strava" {
+		t.Errorf("CreatePushSubscription() = %+v", subscription)
+	}
+
+	listed, err := client.ListPushSubscriptions(context.Background())
+	if err != nil {
+		t.Fatalf("ListPushSubscriptions() error = %v", err)
+	}
+	if len(listed) != 1 || listed[0].ID != 42 {
+		t.Errorf("ListPushSubscriptions() = %+v, want subscription 42", listed)
+	}
+
+	if err := client.DeletePushSubscription(context.Background(), 42); err != nil {
+		t.Fatalf("DeletePushSubscription() error = %v", err)
+	}
+	if err := client.DeletePushSubscription(context.Background(), 7); err == nil {
+		t.Error("DeletePushSubscription() expected error for unknown subscription")
+	}
+}
+
+// staticTokenSource hands out a fixed token and records refreshes
+type staticTokenSource struct {
+	token     *TokenData
+	refreshed *TokenData
+	refreshes int
+}
+
+func (s *staticTokenSource) Token(ctx context.Context) (*TokenData, error) {
+	return s.token, nil
+}
+
+func (s *staticTokenSource) Refresh(ctx context.Context, rejected *TokenData) (*TokenData, error) {
+	s.refreshes++
+	s.token = s.refreshed
+	return s.token, nil
+}
+
+func TestClientRefreshesTokenOnUnauthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id": 7}`))
+	}))
+	defer server.Close()
+
+	tokenSource := &staticTokenSource{
+		token:     &TokenData{AccessToken: "stale"},
+		refreshed: &TokenData{AccessToken: "fresh"},
+	}
+
+	client := NewClient(&config.Config{})
+	client.baseURL = server.URL
+	client.SetTokenSource(tokenSource)
+
+	athlete, err := client.GetAthlete(context.Background())
+	if err != nil {
+		t.Fatalf("GetAthlete() error = %v", err)
+	}
+	if athlete.ID != 7 {
+		t.Errorf("athlete ID = %d, want 7", athlete.ID)
+	}
+	if tokenSource.refreshes != 1 {
+		t.Errorf("refreshes = %d, want 1", tokenSource.refreshes)
+	}
+}
+
+func TestClientWithoutTokenSource(t *testing.T) {
+	client := NewClient(&config.Config{})
+	if _, err := client.GetAthlete(context.Background()); err == nil {
+		t.Error("GetAthlete() expected error without a token source")
+	}
+}
+
+func TestClientCancelledContext(t *testing.T) {
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-unblock:
+		}
+	}))
+	defer server.Close()
+	defer close(unblock)
+
+	client := NewClient(&config.Config{})
+	client.baseURL = server.URL
+	client.SetTokenSource(&staticTokenSource{token: &TokenData{AccessToken: "token"}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.GetAthlete(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetAthlete() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("GetAthlete() returned after %v, want it to stop with the context", elapsed)
+	}
+}
+
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr ||
 		(len(s) > len(substr) && (s[:len(substr)] == substr || s[len(s)-len(substr):] == substr ||
@@ -0,0 +1,591 @@
+// Package fake serves the subset of the Strava API used by the importer from
+// fixture JSON, so that the OAuth flow and the import pipeline can run
+// offline in tests and local development.
+package fake
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"stravaDataImporter/internal/config"
+	"stravaDataImporter/internal/strava"
+)
+
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+const (
+	// AuthorizationCode is the code handed out by the fake authorize page
+	AuthorizationCode = "fake-authorization-code"
+
+	apiPrefix      = "/api/v3"
+	tokenLifetime  = 6 * time.Hour
+	defaultPerPage = 30
+)
+
+// detailOnlyKeys are dropped from activities in list responses, which
+// Strava returns as summary representations
+var detailOnlyKeys = []string{"laps", "segment_efforts", "splits_metric", "best_efforts"}
+
+// Server is an in-memory Strava API. It implements http.Handler and is
+// usually wrapped in an httptest.Server.
+type Server struct {
+	mux *http.ServeMux
+
+	mu            sync.Mutex
+	athlete       map[string]any
+	activities    []map[string]any
+	gear          []map[string]any
+	zones         json.RawMessage
+	streams       map[int64]json.RawMessage
+	accessTokens  map[string]bool
+	refreshToken  string
+	tokenSeq      int
+	subscriptions []strava.PushSubscription
+	nextSubID     int64
+}
+
+// New creates a fake Strava server loaded with the embedded fixtures
+func New() (*Server, error) {
+	s := &Server{
+		mux:          http.NewServeMux(),
+		streams:      make(map[int64]json.RawMessage),
+		accessTokens: make(map[string]bool),
+		nextSubID:    1,
+	}
+
+	if err := loadFixture("athlete.json", &s.athlete); err != nil {
+		return nil, err
+	}
+	if err := loadFixture("activities.json", &s.activities); err != nil {
+		return nil, err
+	}
+	if err := loadFixture("gear.json", &s.gear); err != nil {
+		return nil, err
+	}
+	if err := loadFixture("zones.json", &s.zones); err != nil {
+		return nil, err
+	}
+
+	entries, err := fixtures.ReadDir("fixtures")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		if !strings.HasPrefix(name, "streams_") {
+			continue
+		}
+		activityID, err := strconv.ParseInt(strings.TrimPrefix(name, "streams_"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid streams fixture name %s: %w", entry.Name(), err)
+		}
+		data, err := fixtures.ReadFile(path.Join("fixtures", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture %s: %w", entry.Name(), err)
+		}
+		s.streams[activityID] = data
+	}
+
+	s.mux.HandleFunc("GET /oauth/authorize", s.handleAuthorize)
+	s.mux.HandleFunc("POST /oauth/token", s.handleToken)
+	s.mux.HandleFunc("GET "+apiPrefix+"/athlete", s.authorized(s.handleAthlete))
+	s.mux.HandleFunc("GET "+apiPrefix+"/athlete/zones", s.authorized(s.handleZones))
+	s.mux.HandleFunc("GET "+apiPrefix+"/athlete/activities", s.authorized(s.handleListActivities))
+	s.mux.HandleFunc("GET "+apiPrefix+"/activities/{id}", s.authorized(s.handleActivity))
+	s.mux.HandleFunc("GET "+apiPrefix+"/activities/{id}/streams", s.authorized(s.handleStreams))
+	s.mux.HandleFunc("GET "+apiPrefix+"/gear/{id}", s.authorized(s.handleGear))
+	s.mux.HandleFunc("GET "+apiPrefix+"/push_subscriptions", s.clientAuthorized(s.handleListSubscriptions))
+	s.mux.HandleFunc("POST "+apiPrefix+"/push_subscriptions", s.clientAuthorized(s.handleCreateSubscription))
+	s.mux.HandleFunc("DELETE "+apiPrefix+"/push_subscriptions/{id}", s.clientAuthorized(s.handleDeleteSubscription))
+
+	return s, nil
+}
+
+func loadFixture(name string, v any) error {
+	data, err := fixtures.ReadFile(path.Join("fixtures", name))
+	if err != nil {
+		return fmt.Errorf("failed to read fixture %s: %w", name, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode fixture %s: %w", name, err)
+	}
+	return nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Configure points the Strava endpoints of cfg at a fake server listening on baseURL
+func Configure(cfg *config.Config, baseURL string) {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	cfg.StravaAPIBaseURL = baseURL + apiPrefix
+	cfg.StravaAuthURL = baseURL + "/oauth/authorize"
+	cfg.StravaTokenURL = baseURL + "/oauth/token"
+}
+
+// AthleteID returns the id of the fixture athlete
+func (s *Server) AthleteID() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int64(numberField(s.athlete, "id"))
+}
+
+// IssueToken returns a valid token for the fixture athlete, as if the OAuth
+// flow had already been completed
+func (s *Server) IssueToken() *strava.TokenData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token := s.issueTokenLocked()
+	return &strava.TokenData{
+		AccessToken:  token["access_token"].(string),
+		RefreshToken: token["refresh_token"].(string),
+		ExpiresAt:    time.Unix(token["expires_at"].(int64), 0),
+		AthleteID:    int64(numberField(s.athlete, "id")),
+	}
+}
+
+// ExpireAccessTokens invalidates every issued access token, so the next API
+// call is answered with 401 until the token is refreshed
+func (s *Server) ExpireAccessTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accessTokens = make(map[string]bool)
+}
+
+// RevokeAuthorization invalidates every issued access and refresh token, as
+// when the athlete deauthorizes the application on Strava
+func (s *Server) RevokeAuthorization() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accessTokens = make(map[string]bool)
+	s.refreshToken = ""
+}
+
+// ShiftActivities moves the start dates of all activities so that the most
+// recent one starts at newest, keeping the gaps between them
+func (s *Server) ShiftActivities(newest time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var latest time.Time
+	for _, activity := range s.activities {
+		if startDate := timeField(activity, "start_date"); startDate.After(latest) {
+			latest = startDate
+		}
+	}
+	if latest.IsZero() {
+		return
+	}
+
+	shift := newest.Sub(latest)
+	for _, activity := range s.activities {
+		shiftStartDates(activity, shift)
+		for _, key := range []string{"laps", "segment_efforts"} {
+			nested, _ := activity[key].([]any)
+			for _, item := range nested {
+				if item, ok := item.(map[string]any); ok {
+					shiftStartDates(item, shift)
+				}
+			}
+		}
+	}
+}
+
+func shiftStartDates(object map[string]any, shift time.Duration) {
+	for _, key := range []string{"start_date", "start_date_local"} {
+		if value := timeField(object, key); !value.IsZero() {
+			object[key] = value.Add(shift).UTC().Format(time.RFC3339)
+		}
+	}
+}
+
+// UpdateActivity overwrites fields of an activity, as if the athlete edited it
+// on Strava. It reports whether the activity exists.
+func (s *Server) UpdateActivity(id int64, fields map[string]any) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, activity := range s.activities {
+		if int64(numberField(activity, "id")) == id {
+			for key, value := range fields {
+				activity[key] = value
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteActivity removes an activity and its streams. It reports whether the
+// activity existed.
+func (s *Server) DeleteActivity(id int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, activity := range s.activities {
+		if int64(numberField(activity, "id")) == id {
+			s.activities = append(s.activities[:i], s.activities[i+1:]...)
+			delete(s.streams, id)
+			return true
+		}
+	}
+	return false
+}
+
+// Subscriptions returns the registered push subscriptions
+func (s *Server) Subscriptions() []strava.PushSubscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]strava.PushSubscription(nil), s.subscriptions...)
+}
+
+func (s *Server) issueTokenLocked() map[string]any {
+	s.tokenSeq++
+	accessToken := fmt.Sprintf("fake-access-token-%d", s.tokenSeq)
+	s.refreshToken = fmt.Sprintf("fake-refresh-token-%d", s.tokenSeq)
+	s.accessTokens[accessToken] = true
+
+	expiresAt := time.Now().Add(tokenLifetime)
+	return map[string]any{
+		"token_type":    "Bearer",
+		"access_token":  accessToken,
+		"refresh_token": s.refreshToken,
+		"expires_at":    expiresAt.Unix(),
+		"expires_in":    int64(tokenLifetime.Seconds()),
+	}
+}
+
+// handleAuthorize approves every request and redirects straight back to the
+// application, like an athlete who clicked "Authorize"
+func (s *Server) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	redirectURI, err := url.Parse(r.URL.Query().Get("redirect_uri"))
+	if err != nil || redirectURI.String() == "" {
+		writeError(w, http.StatusBadRequest, "invalid redirect_uri")
+		return
+	}
+
+	q := redirectURI.Query()
+	q.Set("state", r.URL.Query().Get("state"))
+	q.Set("code", AuthorizationCode)
+	q.Set("scope", r.URL.Query().Get("scope"))
+	redirectURI.RawQuery = q.Encode()
+
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid form")
+		return
+	}
+	if r.PostForm.Get("client_id") == "" || r.PostForm.Get("client_secret") == "" {
+		writeError(w, http.StatusUnauthorized, "invalid client")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		if r.PostForm.Get("code") != AuthorizationCode {
+			writeError(w, http.StatusBadRequest, "invalid code")
+			return
+		}
+		token := s.issueTokenLocked()
+		token["athlete"] = s.athlete
+		writeJSON(w, http.StatusOK, token)
+
+	case "refresh_token":
+		if s.refreshToken == "" || r.PostForm.Get("refresh_token") != s.refreshToken {
+			writeError(w, http.StatusBadRequest, "invalid refresh_token")
+			return
+		}
+		writeJSON(w, http.StatusOK, s.issueTokenLocked())
+
+	default:
+		writeError(w, http.StatusBadRequest, "unsupported grant_type")
+	}
+}
+
+// authorized rejects requests without a currently valid access token
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accessToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		s.mu.Lock()
+		valid := s.accessTokens[accessToken]
+		s.mu.Unlock()
+
+		if !valid {
+			writeError(w, http.StatusUnauthorized, "Authorization Error")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// clientAuthorized rejects push subscription requests without client credentials
+func (s *Server) clientAuthorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid form")
+			return
+		}
+		if r.Form.Get("client_id") == "" || r.Form.Get("client_secret") == "" {
+			writeError(w, http.StatusUnauthorized, "invalid client")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) handleAthlete(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, s.athlete)
+}
+
+func (s *Server) handleZones(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, s.zones)
+}
+
+// handleListActivities filters by before/after and pages newest first
+func (s *Server) handleListActivities(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	before, err := unixParam(q, "before")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	after, err := unixParam(q, "after")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	page := intParam(q, "page", 1)
+	perPage := intParam(q, "per_page", defaultPerPage)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matched []map[string]any
+	for _, activity := range s.activities {
+		startDate := timeField(activity, "start_date")
+		if !before.IsZero() && !startDate.Before(before) {
+			continue
+		}
+		if !after.IsZero() && !startDate.After(after) {
+			continue
+		}
+		matched = append(matched, summaryOf(activity))
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return timeField(matched[i], "start_date").After(timeField(matched[j], "start_date"))
+	})
+
+	start := (page - 1) * perPage
+	if start > len(matched) {
+		start = len(matched)
+	}
+	end := min(start+perPage, len(matched))
+
+	writeJSON(w, http.StatusOK, append([]map[string]any{}, matched[start:end]...))
+}
+
+func (s *Server) handleActivity(w http.ResponseWriter, r *http.Request) {
+	activityID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, activity := range s.activities {
+		if int64(numberField(activity, "id")) == activityID {
+			writeJSON(w, http.StatusOK, activity)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Record Not Found")
+}
+
+func (s *Server) handleGear(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, gear := range s.gear {
+		if gear["id"] == r.PathValue("id") {
+			writeJSON(w, http.StatusOK, gear)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Record Not Found")
+}
+
+// handleStreams serves the streams fixture of an activity, restricted to the
+// requested keys like Strava does
+func (s *Server) handleStreams(w http.ResponseWriter, r *http.Request) {
+	activityID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	s.mu.Lock()
+	data, ok := s.streams[activityID]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "Record Not Found")
+		return
+	}
+
+	var streams map[string]json.RawMessage
+	if err := json.Unmarshal(data, &streams); err != nil {
+		writeError(w, http.StatusInternalServerError, "invalid streams fixture")
+		return
+	}
+
+	if keys := r.URL.Query().Get("keys"); keys != "" {
+		requested := make(map[string]bool)
+		for _, key := range strings.Split(keys, ",") {
+			requested[key] = true
+		}
+		for key := range streams {
+			// time is always returned as the series the others are keyed by
+			if key != "time" && !requested[key] {
+				delete(streams, key)
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, streams)
+}
+
+func (s *Server) handleListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Subscriptions())
+}
+
+func (s *Server) handleCreateSubscription(w http.ResponseWriter, r *http.Request) {
+	callbackURL := r.Form.Get("callback_url")
+	if callbackURL == "" || r.Form.Get("verify_token") == "" {
+		writeError(w, http.StatusBadRequest, "callback_url and verify_token are required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Strava allows a single subscription per application
+	if len(s.subscriptions) > 0 {
+		writeError(w, http.StatusBadRequest, "subscription already exists")
+		return
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	subscription := strava.PushSubscription{
+		ID:          s.nextSubID,
+		CallbackURL: callbackURL,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	s.nextSubID++
+	s.subscriptions = append(s.subscriptions, subscription)
+
+	writeJSON(w, http.StatusCreated, map[string]int64{"id": subscription.ID})
+}
+
+func (s *Server) handleDeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	subscriptionID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, subscription := range s.subscriptions {
+		if subscription.ID == subscriptionID {
+			s.subscriptions = append(s.subscriptions[:i], s.subscriptions[i+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Record Not Found")
+}
+
+func summaryOf(activity map[string]any) map[string]any {
+	summary := make(map[string]any, len(activity))
+	for key, value := range activity {
+		summary[key] = value
+	}
+	for _, key := range detailOnlyKeys {
+		delete(summary, key)
+	}
+	return summary
+}
+
+func numberField(object map[string]any, key string) float64 {
+	value, _ := object[key].(float64)
+	return value
+}
+
+func timeField(object map[string]any, key string) time.Time {
+	value, _ := object[key].(string)
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func unixParam(q url.Values, key string) (time.Time, error) {
+	value := q.Get(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s", key)
+	}
+	return time.Unix(seconds, 0), nil
+}
+
+func intParam(q url.Values, key string, defaultValue int) int {
+	value, err := strconv.Atoi(q.Get(key))
+	if err != nil || value < 1 {
+		return defaultValue
+	}
+	return value
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError answers with Strava's fault envelope
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]any{
+		"message": message,
+		"errors":  []map[string]string{{"resource": "Application", "code": "invalid"}},
+	})
+}
@@ -0,0 +1,174 @@
+package fake
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"stravaDataImporter/internal/config"
+	"stravaDataImporter/internal/strava"
+)
+
+type tokenSource struct {
+	token *strava.TokenData
+}
+
+func (s *tokenSource) Token(ctx context.Context) (*strava.TokenData, error) {
+	return s.token, nil
+}
+
+func (s *tokenSource) Refresh(ctx context.Context, rejected *strava.TokenData) (*strava.TokenData, error) {
+	return s.token, nil
+}
+
+func newTestClient(t *testing.T) (*strava.Client, *Server, *config.Config) {
+	t.Helper()
+
+	fakeStrava, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	server := httptest.NewServer(fakeStrava)
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{StravaClientID: "id", StravaClientSecret: "secret"}
+	Configure(cfg, server.URL)
+
+	client := strava.NewClient(cfg)
+	client.SetTokenSource(&tokenSource{token: fakeStrava.IssueToken()})
+	return client, fakeStrava, cfg
+}
+
+func TestActivitiesFromFixtures(t *testing.T) {
+	client, _, _ := newTestClient(t)
+
+	activities, err := client.ListActivities(context.Background(), strava.ActivityListOptions{PerPage: 2})
+	if err != nil {
+		t.Fatalf("ListActivities() error = %v", err)
+	}
+	if len(activities) != 2 || activities[0].ID != 1001 || activities[1].ID != 1002 {
+		t.Fatalf("first page = %+v, want activities 1001 and 1002", activities)
+	}
+
+	activities, err = client.ListActivities(context.Background(), strava.ActivityListOptions{Page: 2, PerPage: 2})
+	if err != nil {
+		t.Fatalf("ListActivities() error = %v", err)
+	}
+	if len(activities) != 1 || activities[0].ID != 1003 || !activities[0].Manual {
+		t.Fatalf("second page = %+v, want manual activity 1003", activities)
+	}
+
+	before := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+	activities, err = client.ListActivities(context.Background(), strava.ActivityListOptions{Before: before, After: before.AddDate(0, 0, -2)})
+	if err != nil {
+		t.Fatalf("ListActivities() error = %v", err)
+	}
+	if len(activities) != 2 {
+		t.Errorf("filtered by date got %d activities, want 2", len(activities))
+	}
+
+	activity, err := client.GetActivity(context.Background(), 1001)
+	if err != nil {
+		t.Fatalf("GetActivity() error = %v", err)
+	}
+	if activity.WeightedAverageWatts != 238 {
+		t.Errorf("WeightedAverageWatts = %v, want 238", activity.WeightedAverageWatts)
+	}
+
+	if _, err := client.GetActivity(context.Background(), 999); err == nil {
+		t.Error("GetActivity() of an unknown activity should fail")
+	}
+}
+
+func TestStreamsFromFixtures(t *testing.T) {
+	client, _, _ := newTestClient(t)
+
+	streams, err := client.GetActivityStreams(context.Background(), 1001)
+	if err != nil {
+		t.Fatalf("GetActivityStreams() error = %v", err)
+	}
+	if streams.Len() != 600 || len(streams.Watts) != 600 || len(streams.LatLng) != 600 {
+		t.Errorf("ride streams have %d samples, want 600 with watts and latlng", streams.Len())
+	}
+
+	streams, err = client.GetActivityStreams(context.Background(), 1003)
+	if err != nil || streams != nil {
+		t.Errorf("GetActivityStreams() of a manual activity = %v, %v, want nil, nil", streams, err)
+	}
+}
+
+func TestTokenFlow(t *testing.T) {
+	client, fakeStrava, cfg := newTestClient(t)
+
+	if _, err := client.ExchangeCodeForToken(context.Background(), "wrong"); err == nil {
+		t.Error("ExchangeCodeForToken() with a wrong code should fail")
+	}
+
+	token, err := client.ExchangeCodeForToken(context.Background(), AuthorizationCode)
+	if err != nil {
+		t.Fatalf("ExchangeCodeForToken() error = %v", err)
+	}
+	if token.AthleteID != fakeStrava.AthleteID() || !token.ExpiresAt.After(time.Now()) {
+		t.Errorf("token = %+v, want athlete %d and a future expiry", token, fakeStrava.AthleteID())
+	}
+
+	refreshed, err := client.RefreshToken(context.Background(), token.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshToken() error = %v", err)
+	}
+	if refreshed.AccessToken == token.AccessToken || refreshed.RefreshToken == token.RefreshToken {
+		t.Error("RefreshToken() should rotate both tokens")
+	}
+	if _, err := client.RefreshToken(context.Background(), token.RefreshToken); err == nil {
+		t.Error("RefreshToken() with a rotated refresh token should fail")
+	}
+
+	fakeStrava.ExpireAccessTokens()
+	req, _ := http.NewRequest("GET", cfg.StravaAPIBaseURL+"/athlete", nil)
+	req.Header.Set("Authorization", "Bearer "+refreshed.AccessToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("athlete request failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expired token status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+}
+
+func TestShiftActivities(t *testing.T) {
+	client, fakeStrava, _ := newTestClient(t)
+
+	newest := time.Now().Add(-time.Hour).Truncate(time.Second)
+	fakeStrava.ShiftActivities(newest)
+
+	activities, err := client.GetActivities(context.Background(), newest.AddDate(0, 0, -2), 30)
+	if err != nil {
+		t.Fatalf("GetActivities() error = %v", err)
+	}
+	if len(activities) != 2 {
+		t.Fatalf("got %d activities in the last two days, want 2", len(activities))
+	}
+	startDate, _ := time.Parse(time.RFC3339, activities[0].StartDate)
+	if !startDate.Equal(newest) {
+		t.Errorf("newest start date = %v, want %v", startDate, newest)
+	}
+}
+
+func TestGearFromFixtures(t *testing.T) {
+	client, _, _ := newTestClient(t)
+
+	gear, err := client.GetGear(context.Background(), "b1111")
+	if err != nil {
+		t.Fatalf("GetGear() error = %v", err)
+	}
+	if gear.Name != "Tarmac" || gear.Distance != 2985000 || strava.GearKind(gear.ID) != "bike" {
+		t.Errorf("gear = %+v, want the Tarmac bike", gear)
+	}
+
+	if _, err := client.GetGear(context.Background(), "b9999"); err == nil {
+		t.Error("GetGear() of unknown gear should fail")
+	}
+}
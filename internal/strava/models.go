@@ -1,6 +1,8 @@
 package strava
 
 import (
+	"errors"
+	"strings"
 	"time"
 )
 
@@ -23,13 +25,95 @@ type ActivityData struct {
 	MaxWatts             float64   `json:"max_watts"`
 	WeightedAverageWatts float64   `json:"weighted_average_watts"`
 	Kilojoules           float64   `json:"kilojoules"`
+	GearID               string    `json:"gear_id"`
+
+	// StartDateLocal is the wall clock start time where the activity took
+	// place. Like Strava, it carries a UTC location; Timezone names the zone.
+	StartDateLocal time.Time `json:"start_date_local"`
+	Timezone       string    `json:"timezone"`
 
 	// Calculated fields
-	FTP float64 `json:"ftp"`
-	TSS float64 `json:"tss"`
-	NP  float64 `json:"np"`
+	FTP      float64 `json:"ftp"`
+	TSS      float64 `json:"tss"`
+	NP       float64 `json:"np"`
+	IF       float64 `json:"if"`
+	NPSource string  `json:"np_source"`
+
+	// LoadModel names the model that produced TSS. TRIMP and LTHR are set
+	// whenever heart rate and thresholds are available.
+	LoadModel string  `json:"load_model"`
+	TRIMP     float64 `json:"trimp"`
+	LTHR      float64 `json:"lthr"`
+
+	// Normalized graded pace of runs and the threshold pace or critical
+	// swim speed the pace based TSS was scored against, in m/s
+	NGP            float64 `json:"ngp"`
+	ThresholdSpeed float64 `json:"threshold_speed"`
+
+	// Variability index (NP / average power), efficiency factor (NP /
+	// average heart rate) and Pa:Hr decoupling in percent, from the power and
+	// heart rate streams
+	VI         float64 `json:"variability_index"`
+	EF         float64 `json:"efficiency_factor"`
+	Decoupling float64 `json:"decoupling"`
+
+	// Critical power and W' the W'bal of the power stream was computed
+	// with, and the lowest W'bal in J. Zero CP means no W'bal.
+	CP               float64 `json:"cp"`
+	WPrime           float64 `json:"w_prime"`
+	WPrimeBalanceMin float64 `json:"w_prime_balance_min"`
+
+	// Body weight in kg on the activity date and the FTP and NP relative to
+	// it. Zero weight means no weight was known.
+	Weight   float64 `json:"weight"`
+	FTPPerKg float64 `json:"ftp_per_kg"`
+	NPPerKg  float64 `json:"np_per_kg"`
+
+	// Seconds spent in each power and heart rate zone, from the streams
+	PowerZoneSeconds     []int `json:"power_zone_seconds,omitempty"`
+	HeartRateZoneSeconds []int `json:"heart_rate_zone_seconds,omitempty"`
+
+	// Deleted is set once the activity has been deleted on Strava
+	Deleted bool `json:"deleted"`
 }
 
+// Training load models stored in ActivityData.LoadModel
+const (
+	LoadModelPower     = "power"
+	LoadModelHeartRate = "heart_rate"
+	LoadModelRunPace   = "run_pace"
+	LoadModelSwimPace  = "swim_pace"
+)
+
+// Thresholds are the athlete's physiological thresholds valid on a date.
+// Zero means unknown. Paces are stored as speeds in m/s.
+type Thresholds struct {
+	LTHR              float64 `json:"lthr"`
+	RestingHR         float64 `json:"resting_hr"`
+	MaxHR             float64 `json:"max_hr"`
+	ThresholdPace     float64 `json:"threshold_pace"`
+	CriticalSwimSpeed float64 `json:"critical_swim_speed"`
+}
+
+// Sources of a body weight record
+const (
+	WeightSourceFile   = "file"
+	WeightSourceStrava = "strava"
+)
+
+// WeightRecord is a body weight in kg valid from the date on
+type WeightRecord struct {
+	Date   time.Time `json:"date"`
+	Weight float64   `json:"weight"`
+	Source string    `json:"source"`
+}
+
+// Sources of the Normalized Power value stored in ActivityData.NPSource
+const (
+	NPSourceStream         = "stream"
+	NPSourceStravaWeighted = "strava_weighted"
+)
+
 // WeeklySummary represents weekly aggregated data
 type WeeklySummary struct {
 	WeekStart          time.Time `json:"week_start"`
@@ -37,22 +121,208 @@ type WeeklySummary struct {
 	TotalMovingTime    int       `json:"total_moving_time"`
 	TotalDistance      float64   `json:"total_distance"`
 	TotalElevationGain float64   `json:"total_elevation_gain"`
+	TotalKilojoules    float64   `json:"total_kilojoules"`
+	ActivityCount      int       `json:"activity_count"`
+
+	PowerZoneSeconds     []int `json:"power_zone_seconds,omitempty"`
+	HeartRateZoneSeconds []int `json:"heart_rate_zone_seconds,omitempty"`
+
+	// Totals per activity type and per sport group
+	ByType  []SportTotals `json:"by_type"`
+	BySport []SportTotals `json:"by_sport"`
 }
 
 // MonthlySummary represents monthly aggregated data
 type MonthlySummary struct {
-	MonthStart      time.Time `json:"month_start"`
-	TotalTSS        float64   `json:"total_tss"`
-	TotalMovingTime int       `json:"total_moving_time"`
-	TotalDistance   float64   `json:"total_distance"`
+	MonthStart         time.Time `json:"month_start"`
+	TotalTSS           float64   `json:"total_tss"`
+	TotalMovingTime    int       `json:"total_moving_time"`
+	TotalDistance      float64   `json:"total_distance"`
+	TotalElevationGain float64   `json:"total_elevation_gain"`
+	TotalKilojoules    float64   `json:"total_kilojoules"`
+	ActivityCount      int       `json:"activity_count"`
+
+	PowerZoneSeconds     []int `json:"power_zone_seconds,omitempty"`
+	HeartRateZoneSeconds []int `json:"heart_rate_zone_seconds,omitempty"`
+
+	// Totals per activity type and per sport group
+	ByType  []SportTotals `json:"by_type"`
+	BySport []SportTotals `json:"by_sport"`
 }
 
 // YearlySummary represents yearly aggregated data
 type YearlySummary struct {
-	YearStart       time.Time `json:"year_start"`
-	TotalTSS        float64   `json:"total_tss"`
-	TotalMovingTime int       `json:"total_moving_time"`
-	TotalDistance   float64   `json:"total_distance"`
+	YearStart          time.Time `json:"year_start"`
+	TotalTSS           float64   `json:"total_tss"`
+	TotalMovingTime    int       `json:"total_moving_time"`
+	TotalDistance      float64   `json:"total_distance"`
+	TotalElevationGain float64   `json:"total_elevation_gain"`
+	TotalKilojoules    float64   `json:"total_kilojoules"`
+	ActivityCount      int       `json:"activity_count"`
+
+	PowerZoneSeconds     []int `json:"power_zone_seconds,omitempty"`
+	HeartRateZoneSeconds []int `json:"heart_rate_zone_seconds,omitempty"`
+
+	// Totals per activity type and per sport group
+	ByType  []SportTotals `json:"by_type"`
+	BySport []SportTotals `json:"by_sport"`
+}
+
+// Sport groups that combine activity types in the summaries
+const (
+	SportGroupRide  = "ride"
+	SportGroupRun   = "run"
+	SportGroupSwim  = "swim"
+	SportGroupWalk  = "walk"
+	SportGroupOther = "other"
+)
+
+// SportTotals are the summary totals of the activities of one activity type
+// or sport group
+type SportTotals struct {
+	Type               string  `json:"type"`
+	TotalTSS           float64 `json:"total_tss"`
+	TotalMovingTime    int     `json:"total_moving_time"`
+	TotalDistance      float64 `json:"total_distance"`
+	TotalElevationGain float64 `json:"total_elevation_gain"`
+	TotalKilojoules    float64 `json:"total_kilojoules"`
+	ActivityCount      int     `json:"activity_count"`
+}
+
+// RollingSummary represents the totals of the last Days days up to and
+// including the day before End
+type RollingSummary struct {
+	Days               int       `json:"days"`
+	Start              time.Time `json:"start"`
+	End                time.Time `json:"end"`
+	TotalTSS           float64   `json:"total_tss"`
+	TotalMovingTime    int       `json:"total_moving_time"`
+	TotalDistance      float64   `json:"total_distance"`
+	TotalElevationGain float64   `json:"total_elevation_gain"`
+	ActivityCount      int       `json:"activity_count"`
+}
+
+// FitnessDay is one day of the Performance Management Chart. TSB is the form
+// going into the day, i.e. the previous day's CTL minus its ATL.
+type FitnessDay struct {
+	Date time.Time `json:"date"`
+	TSS  float64   `json:"tss"`
+	CTL  float64   `json:"ctl"`
+	ATL  float64   `json:"atl"`
+	TSB  float64   `json:"tsb"`
+
+	// Time constants the day was computed with
+	CTLDays int `json:"ctl_days"`
+	ATLDays int `json:"atl_days"`
+}
+
+// WorkloadDay holds the load ratios of a day. ACWR is the average daily TSS
+// of the last 7 days over that of the last 28 days; monotony (Foster) is the
+// average daily TSS of the last 7 days over its standard deviation and
+// strain is the 7 day TSS times the monotony. Zero means the history does
+// not cover the window yet. TSB is copied from the fitness series.
+type WorkloadDay struct {
+	Date        time.Time `json:"date"`
+	AcuteLoad   float64   `json:"acute_load"`
+	ChronicLoad float64   `json:"chronic_load"`
+	ACWR        float64   `json:"acwr"`
+	Monotony    float64   `json:"monotony"`
+	Strain      float64   `json:"strain"`
+	TSB         float64   `json:"tsb"`
+}
+
+// Workload metrics an overload alert can be raised for
+const (
+	WorkloadMetricACWR     = "acwr"
+	WorkloadMetricMonotony = "monotony"
+	WorkloadMetricTSB      = "tsb"
+)
+
+// OverloadAlert reports that a workload metric crossed its danger threshold
+// on the date
+type OverloadAlert struct {
+	Date      time.Time `json:"date"`
+	Metric    string    `json:"metric"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+}
+
+// PowerCurveDurations are the durations in seconds of the mean-maximal power
+// curve
+var PowerCurveDurations = []int{1, 5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600, 5400}
+
+// PowerCurve is the best average power of an activity for each of
+// PowerCurveDurations. Zero means the activity was shorter than the duration.
+type PowerCurve struct {
+	ActivityID int64     `json:"activity_id"`
+	StartDate  time.Time `json:"start_date"`
+	Watts      []float64 `json:"watts"`
+	Weight     float64   `json:"weight"`
+}
+
+// PowerCurvePoint is the best power for a duration and the activity that
+// set it. WattsPerKg uses the weight on the activity date.
+type PowerCurvePoint struct {
+	Duration   int       `json:"duration"`
+	Watts      float64   `json:"watts"`
+	WattsPerKg float64   `json:"watts_per_kg"`
+	ActivityID int64     `json:"activity_id"`
+	Date       time.Time `json:"date"`
+}
+
+// PowerCurveBests are the mean-maximal power curves of the recent days, the
+// current season and all time
+type PowerCurveBests struct {
+	RecentDays  int               `json:"recent_days"`
+	SeasonStart time.Time         `json:"season_start"`
+	Recent      []PowerCurvePoint `json:"recent"`
+	Season      []PowerCurvePoint `json:"season"`
+	AllTime     []PowerCurvePoint `json:"all_time"`
+}
+
+// CriticalPower is a fit of the 2-parameter critical power model to the best
+// efforts of the window of days up to the date. FTP is the FTP history value
+// on the date, for comparison.
+type CriticalPower struct {
+	Date       time.Time `json:"date"`
+	CP         float64   `json:"cp"`
+	WPrime     float64   `json:"w_prime"`
+	WindowDays int       `json:"window_days"`
+	Efforts    int       `json:"efforts"`
+	FTP        float64   `json:"ftp"`
+}
+
+// Evidence an FTP suggestion is based on
+const (
+	FTPSourceTwentyMinute  = "20min"
+	FTPSourceCriticalPower = "cp"
+)
+
+// FTP suggestion states. A pending suggestion is superseded when stronger
+// evidence produces a higher one.
+const (
+	FTPSuggestionPending    = "pending"
+	FTPSuggestionAccepted   = "accepted"
+	FTPSuggestionDismissed  = "dismissed"
+	FTPSuggestionSuperseded = "superseded"
+)
+
+var (
+	ErrFTPSuggestionNotFound   = errors.New("FTP suggestion not found")
+	ErrFTPSuggestionNotPending = errors.New("FTP suggestion is not pending")
+)
+
+// FTPSuggestion proposes a new dated FTP entry from the evidence of a best
+// effort or a critical power fit
+type FTPSuggestion struct {
+	ID            string    `json:"id"`
+	Date          time.Time `json:"date"`
+	FTP           float64   `json:"ftp"`
+	CurrentFTP    float64   `json:"current_ftp"`
+	Source        string    `json:"source"`
+	EvidenceWatts float64   `json:"evidence_watts"`
+	ActivityID    int64     `json:"activity_id,omitempty"`
+	Status        string    `json:"status"`
 }
 
 // TokenData represents OAuth token information
@@ -64,6 +334,15 @@ type TokenData struct {
 	AthleteID    int64     `json:"athlete_id"`
 }
 
+// BackfillState tracks the progress of the full-history import so that an
+// interrupted backfill can resume from the last completed page
+type BackfillState struct {
+	Before    time.Time `json:"before"`
+	Imported  int       `json:"imported"`
+	Completed bool      `json:"completed"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 // StravaActivity represents the raw activity data from Strava API
 type StravaActivity struct {
 	ID                   int64   `json:"id"`
@@ -74,6 +353,8 @@ type StravaActivity struct {
 	ElapsedTime          int     `json:"elapsed_time"`
 	TotalElevationGain   float64 `json:"total_elevation_gain"`
 	StartDate            string  `json:"start_date"`
+	StartDateLocal       string  `json:"start_date_local"`
+	Timezone             string  `json:"timezone"`
 	AverageSpeed         float64 `json:"average_speed"`
 	MaxSpeed             float64 `json:"max_speed"`
 	Calories             float64 `json:"calories"`
@@ -83,6 +364,241 @@ type StravaActivity struct {
 	MaxWatts             float64 `json:"max_watts"`
 	WeightedAverageWatts float64 `json:"weighted_average_watts"`
 	Kilojoules           float64 `json:"kilojoules"`
+	Manual               bool    `json:"manual"`
+	GearID               string  `json:"gear_id"`
+
+	// Only present in detailed activities returned by GetActivity
+	Laps           []Lap           `json:"laps"`
+	SegmentEfforts []SegmentEffort `json:"segment_efforts"`
+}
+
+// Lap is a lap of a detailed activity. StartIndex and EndIndex point into
+// the activity streams.
+type Lap struct {
+	ID                 int64   `json:"id"`
+	Name               string  `json:"name"`
+	LapIndex           int     `json:"lap_index"`
+	ElapsedTime        int     `json:"elapsed_time"`
+	MovingTime         int     `json:"moving_time"`
+	StartDate          string  `json:"start_date"`
+	Distance           float64 `json:"distance"`
+	StartIndex         int     `json:"start_index"`
+	EndIndex           int     `json:"end_index"`
+	TotalElevationGain float64 `json:"total_elevation_gain"`
+	AverageSpeed       float64 `json:"average_speed"`
+	AverageCadence     float64 `json:"average_cadence"`
+	AverageWatts       float64 `json:"average_watts"`
+	AverageHeartrate   float64 `json:"average_heartrate"`
+	MaxHeartrate       float64 `json:"max_heartrate"`
+}
+
+// SegmentEffort is an effort on a segment within a detailed activity
+type SegmentEffort struct {
+	ID               int64   `json:"id"`
+	Name             string  `json:"name"`
+	ElapsedTime      int     `json:"elapsed_time"`
+	MovingTime       int     `json:"moving_time"`
+	StartDate        string  `json:"start_date"`
+	Distance         float64 `json:"distance"`
+	StartIndex       int     `json:"start_index"`
+	EndIndex         int     `json:"end_index"`
+	AverageWatts     float64 `json:"average_watts"`
+	AverageHeartrate float64 `json:"average_heartrate"`
+	MaxHeartrate     float64 `json:"max_heartrate"`
+	PRRank           *int    `json:"pr_rank"`
+	KOMRank          *int    `json:"kom_rank"`
+	Segment          Segment `json:"segment"`
+}
+
+// Segment is the summary of a segment embedded in a segment effort
+type Segment struct {
+	ID            int64   `json:"id"`
+	Name          string  `json:"name"`
+	ActivityType  string  `json:"activity_type"`
+	Distance      float64 `json:"distance"`
+	AverageGrade  float64 `json:"average_grade"`
+	MaximumGrade  float64 `json:"maximum_grade"`
+	ClimbCategory int     `json:"climb_category"`
+}
+
+// LapData is a lap with its power metrics calculated against the FTP
+type LapData struct {
+	ActivityID       int64     `json:"activity_id"`
+	ActivityType     string    `json:"activity_type"`
+	LapIndex         int       `json:"lap_index"`
+	Name             string    `json:"name"`
+	StartDate        time.Time `json:"start_date"`
+	ElapsedTime      int       `json:"elapsed_time"`
+	MovingTime       int       `json:"moving_time"`
+	Distance         float64   `json:"distance"`
+	AverageWatts     float64   `json:"average_watts"`
+	NP               float64   `json:"np"`
+	IF               float64   `json:"if"`
+	AverageHeartrate float64   `json:"average_heartrate"`
+	MaxHeartrate     float64   `json:"max_heartrate"`
+	AverageSpeed     float64   `json:"average_speed"`
+	AverageCadence   float64   `json:"average_cadence"`
+}
+
+// SegmentEffortData is a segment effort as stored in InfluxDB. PRRank and
+// KOMRank are 0 when the effort did not rank.
+type SegmentEffortData struct {
+	ID               int64     `json:"id"`
+	ActivityID       int64     `json:"activity_id"`
+	SegmentID        int64     `json:"segment_id"`
+	SegmentName      string    `json:"segment_name"`
+	StartDate        time.Time `json:"start_date"`
+	ElapsedTime      int       `json:"elapsed_time"`
+	MovingTime       int       `json:"moving_time"`
+	Distance         float64   `json:"distance"`
+	AverageGrade     float64   `json:"average_grade"`
+	AverageWatts     float64   `json:"average_watts"`
+	AverageHeartrate float64   `json:"average_heartrate"`
+	MaxHeartrate     float64   `json:"max_heartrate"`
+	PRRank           int       `json:"pr_rank"`
+	KOMRank          int       `json:"kom_rank"`
+}
+
+// Gear is a bike or a pair of shoes as returned by the Strava gear endpoint
+type Gear struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	BrandName string  `json:"brand_name"`
+	ModelName string  `json:"model_name"`
+	Distance  float64 `json:"distance"` // meters, including activities before the import
+	Primary   bool    `json:"primary"`
+	Retired   bool    `json:"retired"`
+}
+
+// GearKind returns "bike" or "shoes" from the prefix Strava gives gear ids
+func GearKind(gearID string) string {
+	if strings.HasPrefix(gearID, "b") {
+		return "bike"
+	}
+	return "shoes"
+}
+
+// GearStats is the cumulative usage of a piece of gear
+type GearStats struct {
+	GearID        string  `json:"gear_id"`
+	Name          string  `json:"name"`
+	Kind          string  `json:"kind"`
+	Distance      float64 `json:"distance"`    // meters as reported by Strava
+	MovingTime    int     `json:"moving_time"` // seconds over the imported activities
+	ActivityCount int     `json:"activity_count"`
+	Retired       bool    `json:"retired"`
+}
+
+// DistanceKm returns the distance in kilometers
+func (g GearStats) DistanceKm() float64 {
+	return g.Distance / 1000
+}
+
+// MovingTimeHours returns the moving time in hours
+func (g GearStats) MovingTimeHours() float64 {
+	return float64(g.MovingTime) / 3600
+}
+
+// MaintenanceStatus is the state of a maintenance item such as a chain for
+// one piece of gear
+type MaintenanceStatus struct {
+	GearID     string  `json:"gear_id"`
+	GearName   string  `json:"gear_name"`
+	Item       string  `json:"item"`
+	IntervalKm float64 `json:"interval_km"`
+	DistanceKm float64 `json:"distance_km"` // since the last service
+	Intervals  int     `json:"intervals"`   // whole intervals covered since the last service
+	Due        bool    `json:"due"`
+}
+
+// ActivityStreams holds the per-sample time series recorded for an activity.
+// Streams that were not recorded by the device are left nil.
+type ActivityStreams struct {
+	Time           []int        `json:"time"`
+	Watts          []float64    `json:"watts"`
+	Heartrate      []float64    `json:"heartrate"`
+	Cadence        []float64    `json:"cadence"`
+	VelocitySmooth []float64    `json:"velocity_smooth"`
+	Altitude       []float64    `json:"altitude"`
+	LatLng         [][2]float64 `json:"latlng"`
+	Distance       []float64    `json:"distance"`
+	Temp           []float64    `json:"temp"`
+	Moving         []bool       `json:"moving"`
+
+	// WPrimeBalance is derived from the watts stream, one value per watts
+	// sample, and is not part of the Strava response
+	WPrimeBalance []float64 `json:"-"`
+}
+
+// streamData is the envelope Strava wraps around every stream
+type streamData[T any] struct {
+	Data []T `json:"data"`
+}
+
+// streamSetResponse represents the /activities/{id}/streams response with key_by_type=true
+type streamSetResponse struct {
+	Time           streamData[int]        `json:"time"`
+	Watts          streamData[float64]    `json:"watts"`
+	Heartrate      streamData[float64]    `json:"heartrate"`
+	Cadence        streamData[float64]    `json:"cadence"`
+	VelocitySmooth streamData[float64]    `json:"velocity_smooth"`
+	Altitude       streamData[float64]    `json:"altitude"`
+	LatLng         streamData[[2]float64] `json:"latlng"`
+	Distance       streamData[float64]    `json:"distance"`
+	Temp           streamData[float64]    `json:"temp"`
+	Moving         streamData[bool]       `json:"moving"`
+}
+
+// ReconcileReport describes what a reconciliation run changed in InfluxDB
+type ReconcileReport struct {
+	Since   time.Time        `json:"since"`
+	Checked int              `json:"checked"`
+	Added   []int64          `json:"added"`
+	Updated []ActivityChange `json:"updated"`
+	Deleted []int64          `json:"deleted"`
+	Failed  []int64          `json:"failed"`
+}
+
+// ActivityChange lists the fields of a stored activity that differed from Strava
+type ActivityChange struct {
+	ID     int64    `json:"id"`
+	Name   string   `json:"name"`
+	Fields []string `json:"fields"`
+}
+
+// Webhook event object and aspect types
+const (
+	WebhookObjectActivity = "activity"
+	WebhookObjectAthlete  = "athlete"
+
+	WebhookAspectCreate = "create"
+	WebhookAspectUpdate = "update"
+	WebhookAspectDelete = "delete"
+)
+
+// WebhookEvent represents an event pushed by a Strava push subscription
+type WebhookEvent struct {
+	ObjectType     string            `json:"object_type"`
+	ObjectID       int64             `json:"object_id"`
+	AspectType     string            `json:"aspect_type"`
+	Updates        map[string]string `json:"updates"`
+	OwnerID        int64             `json:"owner_id"`
+	SubscriptionID int64             `json:"subscription_id"`
+	EventTime      int64             `json:"event_time"`
+}
+
+// IsDeauthorization reports whether the event revokes the app's access
+func (e *WebhookEvent) IsDeauthorization() bool {
+	return e.ObjectType == WebhookObjectAthlete && e.Updates["authorized"] == "false"
+}
+
+// PushSubscription represents a Strava webhook subscription
+type PushSubscription struct {
+	ID            int64  `json:"id"`
+	ApplicationID int64  `json:"application_id"`
+	CallbackURL   string `json:"callback_url"`
+	CreatedAt     string `json:"created_at"`
+	UpdatedAt     string `json:"updated_at"`
 }
 
 // AthleteInfo represents basic athlete information from Strava
@@ -91,6 +607,33 @@ type AthleteInfo struct {
 	Username  string `json:"username"`
 	Firstname string `json:"firstname"`
 	Lastname  string `json:"lastname"`
+
+	// Weight in kg as set in the athlete's Strava profile, 0 when unset
+	Weight float64 `json:"weight"`
+}
+
+// AthleteZones holds the athlete's heart rate and power zones
+type AthleteZones struct {
+	HeartRate *ZoneRanges `json:"heart_rate"`
+	Power     *ZoneRanges `json:"power"`
+}
+
+// ZoneRanges is an ordered list of zones
+type ZoneRanges struct {
+	CustomZones bool        `json:"custom_zones"`
+	Zones       []ZoneRange `json:"zones"`
+}
+
+// ZoneRange covers values from Min (inclusive) to Max (exclusive). The last
+// zone has a Max of -1 and is open-ended.
+type ZoneRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// Contains reports whether value falls into the zone
+func (z ZoneRange) Contains(value float64) bool {
+	return value >= float64(z.Min) && (z.Max < 0 || value < float64(z.Max))
 }
 
 // TokenResponse represents OAuth token response from Strava
@@ -102,6 +645,26 @@ type TokenResponse struct {
 	Athlete      AthleteInfo `json:"athlete"`
 }
 
+// Len returns the number of samples in the longest stream
+func (s *ActivityStreams) Len() int {
+	n := len(s.Time)
+	for _, l := range []int{len(s.Watts), len(s.Heartrate), len(s.Cadence), len(s.VelocitySmooth),
+		len(s.Altitude), len(s.LatLng), len(s.Distance), len(s.Temp), len(s.Moving)} {
+		if l > n {
+			n = l
+		}
+	}
+	return n
+}
+
+// Offset returns the number of seconds between the activity start and sample i
+func (s *ActivityStreams) Offset(i int) int {
+	if i < len(s.Time) {
+		return s.Time[i]
+	}
+	return i
+}
+
 // Helper methods for template display
 func (a *ActivityData) MovingTimeHours() float64 {
 	return float64(a.MovingTime) / 3600
@@ -139,3 +702,23 @@ func (y *YearlySummary) TotalMovingTimeHours() float64 {
 func (y *YearlySummary) TotalDistanceKm() float64 {
 	return y.TotalDistance / 1000
 }
+
+func (s *SportTotals) TotalMovingTimeHours() float64 {
+	return float64(s.TotalMovingTime) / 3600
+}
+
+func (s *SportTotals) TotalDistanceKm() float64 {
+	return s.TotalDistance / 1000
+}
+
+func (c *CriticalPower) WPrimeKJ() float64 {
+	return c.WPrime / 1000
+}
+
+func (s *RollingSummary) TotalMovingTimeHours() float64 {
+	return float64(s.TotalMovingTime) / 3600
+}
+
+func (s *RollingSummary) TotalDistanceKm() float64 {
+	return s.TotalDistance / 1000
+}
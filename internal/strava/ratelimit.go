@@ -0,0 +1,245 @@
+package strava
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// rateLimitWindow is the length of Strava's short-term rate limit window.
+	// Windows start on the quarter hour and the daily limit resets at midnight UTC.
+	rateLimitWindow = 15 * time.Minute
+
+	// maxRateLimitRetries is how many times a request rejected with 429 is retried
+	maxRateLimitRetries = 3
+)
+
+// sharedRateLimiter is used by every Client so that the scheduler and the web
+// handlers draw from the same budget
+var sharedRateLimiter = NewRateLimiter()
+
+// RateLimitUsage is a snapshot of the Strava API budget
+type RateLimitUsage struct {
+	ShortLimit int       `json:"short_limit"`
+	ShortUsage int       `json:"short_usage"`
+	DailyLimit int       `json:"daily_limit"`
+	DailyUsage int       `json:"daily_usage"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Fraction returns the larger of the short-term and daily usage ratios.
+// It is zero until Strava has reported the limits.
+func (u RateLimitUsage) Fraction() float64 {
+	fraction := 0.0
+	if u.ShortLimit > 0 {
+		fraction = float64(u.ShortUsage) / float64(u.ShortLimit)
+	}
+	if u.DailyLimit > 0 {
+		if daily := float64(u.DailyUsage) / float64(u.DailyLimit); daily > fraction {
+			fraction = daily
+		}
+	}
+	return fraction
+}
+
+// RateLimiter tracks the budget reported in Strava's X-RateLimit-Limit and
+// X-RateLimit-Usage headers and holds requests back until the next window
+// once it is used up
+type RateLimiter struct {
+	mu           sync.Mutex
+	usage        RateLimitUsage
+	blockedUntil time.Time
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		now:   time.Now,
+		sleep: sleepContext,
+	}
+}
+
+// sleepContext pauses for d or until ctx is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Update records the budget reported in a response's headers
+func (r *RateLimiter) Update(header http.Header) {
+	shortLimit, dailyLimit, ok := parseRateLimitPair(header.Get("X-RateLimit-Limit"))
+	if !ok {
+		return
+	}
+	shortUsage, dailyUsage, ok := parseRateLimitPair(header.Get("X-RateLimit-Usage"))
+	if !ok {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.usage = RateLimitUsage{
+		ShortLimit: shortLimit,
+		ShortUsage: shortUsage,
+		DailyLimit: dailyLimit,
+		DailyUsage: dailyUsage,
+		UpdatedAt:  r.now(),
+	}
+}
+
+// Exhausted blocks further requests until the window that rejected a
+// request with 429 has passed
+func (r *RateLimiter) Exhausted() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	r.resetExpired(now)
+
+	until := r.constrainedWindow(now)
+	r.blockedUntil = until
+
+	slog.Warn("Strava rate limit exceeded, pausing requests", "until", until, "short_usage", r.usage.ShortUsage, "daily_usage", r.usage.DailyUsage)
+}
+
+// Wait blocks until the budget allows another request and reserves it.
+// It returns the context's error when ctx is cancelled first.
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	return r.waitUntil(ctx, func(usage RateLimitUsage) bool {
+		return (usage.ShortLimit == 0 || usage.ShortUsage < usage.ShortLimit) &&
+			(usage.DailyLimit == 0 || usage.DailyUsage < usage.DailyLimit)
+	}, true)
+}
+
+// WaitBelow blocks until the used fraction of the budget is below threshold.
+// Background work calls it to leave headroom for scheduled imports and webhooks.
+// A threshold of zero or less disables deferring.
+func (r *RateLimiter) WaitBelow(ctx context.Context, threshold float64) error {
+	if threshold <= 0 {
+		return nil
+	}
+	return r.waitUntil(ctx, func(usage RateLimitUsage) bool {
+		return usage.Fraction() < threshold
+	}, false)
+}
+
+// Usage returns the current budget snapshot
+func (r *RateLimiter) Usage() RateLimitUsage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.resetExpired(r.now())
+	return r.usage
+}
+
+// ShouldDefer reports whether non-urgent work should wait for the next window
+func (r *RateLimiter) ShouldDefer(threshold float64) bool {
+	if threshold <= 0 {
+		return false
+	}
+	return r.Usage().Fraction() >= threshold
+}
+
+func (r *RateLimiter) waitUntil(ctx context.Context, ready func(RateLimitUsage) bool, reserve bool) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		r.mu.Lock()
+		now := r.now()
+		r.resetExpired(now)
+
+		var until time.Time
+		switch {
+		case now.Before(r.blockedUntil):
+			until = r.blockedUntil
+		case ready(r.usage):
+			if reserve {
+				r.usage.ShortUsage++
+				r.usage.DailyUsage++
+			}
+			r.mu.Unlock()
+			return nil
+		default:
+			until = r.constrainedWindow(now)
+		}
+		r.mu.Unlock()
+
+		slog.Info("Waiting for the next Strava rate limit window", "until", until)
+		if err := r.sleep(ctx, until.Sub(now)); err != nil {
+			return err
+		}
+	}
+}
+
+// constrainedWindow returns when the limit with the larger used fraction resets
+func (r *RateLimiter) constrainedWindow(now time.Time) time.Time {
+	shortFraction, dailyFraction := 0.0, 0.0
+	if r.usage.ShortLimit > 0 {
+		shortFraction = float64(r.usage.ShortUsage) / float64(r.usage.ShortLimit)
+	}
+	if r.usage.DailyLimit > 0 {
+		dailyFraction = float64(r.usage.DailyUsage) / float64(r.usage.DailyLimit)
+	}
+	if dailyFraction > shortFraction {
+		return nextDailyWindow(now)
+	}
+	return nextShortWindow(now)
+}
+
+// resetExpired clears usage counters that belong to a window that has ended
+func (r *RateLimiter) resetExpired(now time.Time) {
+	if r.usage.UpdatedAt.IsZero() {
+		r.usage.UpdatedAt = now
+		return
+	}
+	if !now.Before(nextShortWindow(r.usage.UpdatedAt)) {
+		r.usage.ShortUsage = 0
+	}
+	if !now.Before(nextDailyWindow(r.usage.UpdatedAt)) {
+		r.usage.DailyUsage = 0
+	}
+	r.usage.UpdatedAt = now
+}
+
+func nextShortWindow(t time.Time) time.Time {
+	return t.Truncate(rateLimitWindow).Add(rateLimitWindow)
+}
+
+func nextDailyWindow(t time.Time) time.Time {
+	utc := t.UTC()
+	return time.Date(utc.Year(), utc.Month(), utc.Day()+1, 0, 0, 0, 0, time.UTC)
+}
+
+// parseRateLimitPair parses a "short,daily" header value
+func parseRateLimitPair(value string) (int, int, bool) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	short, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, false
+	}
+	daily, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return 0, 0, false
+	}
+	return short, daily, true
+}
@@ -0,0 +1,156 @@
+package strava
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"stravaDataImporter/internal/config"
+)
+
+// newTestRateLimiter returns a limiter whose clock only advances when it sleeps
+func newTestRateLimiter(start time.Time) (*RateLimiter, *[]time.Duration) {
+	now := start
+	var slept []time.Duration
+
+	limiter := NewRateLimiter()
+	limiter.now = func() time.Time { return now }
+	limiter.sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		now = now.Add(d)
+		return nil
+	}
+	return limiter, &slept
+}
+
+func rateLimitHeader(limit, usage string) http.Header {
+	header := http.Header{}
+	header.Set("X-RateLimit-Limit", limit)
+	header.Set("X-RateLimit-Usage", usage)
+	return header
+}
+
+func TestRateLimiterUpdate(t *testing.T) {
+	limiter, _ := newTestRateLimiter(time.Date(2024, 5, 1, 10, 3, 0, 0, time.UTC))
+	limiter.Update(rateLimitHeader("100,1000", "42,420"))
+
+	usage := limiter.Usage()
+	if usage.ShortLimit != 100 || usage.ShortUsage != 42 || usage.DailyLimit != 1000 || usage.DailyUsage != 420 {
+		t.Errorf("Usage() = %+v, want 42/100 and 420/1000", usage)
+	}
+	if usage.Fraction() != 0.42 {
+		t.Errorf("Fraction() = %v, want 0.42", usage.Fraction())
+	}
+
+	// Malformed headers are ignored
+	limiter.Update(rateLimitHeader("100", "oops"))
+	if limiter.Usage().ShortUsage != 42 {
+		t.Error("Update() with malformed headers changed the usage")
+	}
+}
+
+func TestRateLimiterWaitsForNextShortWindow(t *testing.T) {
+	limiter, slept := newTestRateLimiter(time.Date(2024, 5, 1, 10, 3, 0, 0, time.UTC))
+	limiter.Update(rateLimitHeader("100,1000", "100,420"))
+
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+
+	if len(*slept) != 1 || (*slept)[0] != 12*time.Minute {
+		t.Errorf("slept %v, want [12m0s] until 10:15", *slept)
+	}
+	if usage := limiter.Usage(); usage.ShortUsage != 1 || usage.DailyUsage != 421 {
+		t.Errorf("Usage() after wait = %+v, want the reserved request counted in the new window", usage)
+	}
+}
+
+func TestRateLimiterWaitsForNextDay(t *testing.T) {
+	limiter, slept := newTestRateLimiter(time.Date(2024, 5, 1, 22, 0, 0, 0, time.UTC))
+	limiter.Update(rateLimitHeader("100,1000", "10,1000"))
+
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+
+	if len(*slept) != 1 || (*slept)[0] != 2*time.Hour {
+		t.Errorf("slept %v, want [2h0m0s] until midnight UTC", *slept)
+	}
+}
+
+func TestRateLimiterWaitCancelled(t *testing.T) {
+	limiter := NewRateLimiter()
+	limiter.Update(rateLimitHeader("100,1000", "100,420"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := limiter.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestRateLimiterShouldDefer(t *testing.T) {
+	limiter, slept := newTestRateLimiter(time.Date(2024, 5, 1, 10, 14, 0, 0, time.UTC))
+	limiter.Update(rateLimitHeader("100,1000", "85,300"))
+
+	if !limiter.ShouldDefer(0.8) {
+		t.Error("ShouldDefer(0.8) = false, want true at 85% usage")
+	}
+	if limiter.ShouldDefer(0.9) {
+		t.Error("ShouldDefer(0.9) = true, want false at 85% usage")
+	}
+
+	if err := limiter.WaitBelow(context.Background(), 0.8); err != nil {
+		t.Fatalf("WaitBelow() error = %v", err)
+	}
+	if len(*slept) != 1 || (*slept)[0] != time.Minute {
+		t.Errorf("slept %v, want [1m0s] until the window resets", *slept)
+	}
+	if limiter.ShouldDefer(0.8) {
+		t.Error("ShouldDefer(0.8) = true after the window reset")
+	}
+}
+
+func TestClientRetriesAfterTooManyRequests(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("X-RateLimit-Limit", "100,1000")
+			w.Header().Set("X-RateLimit-Usage", "101,500")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("X-RateLimit-Limit", "100,1000")
+		w.Header().Set("X-RateLimit-Usage", "1,501")
+		_, _ = w.Write([]byte(`{"id": 1, "firstname": "Test"}`))
+	}))
+	defer server.Close()
+
+	limiter, slept := newTestRateLimiter(time.Date(2024, 5, 1, 10, 5, 0, 0, time.UTC))
+	client := NewClient(&config.Config{})
+	client.baseURL = server.URL
+	client.SetTokenSource(&staticTokenSource{token: &TokenData{AccessToken: "token"}})
+	client.rateLimiter = limiter
+
+	athlete, err := client.GetAthlete(context.Background())
+	if err != nil {
+		t.Fatalf("GetAthlete() error = %v", err)
+	}
+	if athlete.ID != 1 {
+		t.Errorf("athlete ID = %d, want 1", athlete.ID)
+	}
+	if requests != 2 {
+		t.Errorf("requests = %d, want 2", requests)
+	}
+	if len(*slept) != 1 || (*slept)[0] != 10*time.Minute {
+		t.Errorf("slept %v, want [10m0s] until 10:15", *slept)
+	}
+	if usage := client.RateLimiter().Usage(); usage.ShortUsage != 1 || usage.DailyUsage != 501 {
+		t.Errorf("Usage() = %+v, want 1/100 and 501/1000", usage)
+	}
+}
@@ -0,0 +1,194 @@
+package threshold
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"stravaDataImporter/internal/strava"
+)
+
+// ThresholdRecord is a row of the threshold history. Zero values are empty
+// cells and keep the previous value. Paces are converted to speeds in m/s.
+type ThresholdRecord struct {
+	Date              time.Time
+	LTHR              float64
+	RestingHR         float64
+	MaxHR             float64
+	ThresholdPace     float64
+	CriticalSwimSpeed float64
+}
+
+// Distances the pace columns are given for: threshold pace per km and
+// critical swim speed per 100 m
+const (
+	thresholdPaceMeters = 1000
+	swimPaceMeters      = 100
+)
+
+// ThresholdManager holds the threshold history from the threshold CSV file.
+// Dates are days in the athlete location.
+type ThresholdManager struct {
+	filePath string
+	location *time.Location
+	records  []ThresholdRecord
+}
+
+// NewThresholdManager creates a manager whose file dates are days in loc
+func NewThresholdManager(filePath string, loc *time.Location) *ThresholdManager {
+	return &ThresholdManager{
+		filePath: filePath,
+		location: loc,
+		records:  make([]ThresholdRecord, 0),
+	}
+}
+
+// LoadThresholdData reads the history from the CSV file. A missing file
+// means no heart rate or pace based training load is calculated.
+func (m *ThresholdManager) LoadThresholdData() error {
+	file, err := os.Open(m.filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		slog.Info("No threshold file found, heart rate and pace based load disabled", "path", m.filePath)
+		m.records = m.records[:0]
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open threshold file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return fmt.Errorf("failed to read CSV: %w", err)
+	}
+
+	m.records = make([]ThresholdRecord, 0, len(records))
+
+	for i, record := range records {
+		if i == 0 && len(record) > 0 && record[0] == "date" {
+			// Skip header row
+			continue
+		}
+
+		if len(record) < 2 {
+			continue
+		}
+
+		date, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(record[0]), m.location)
+		if err != nil {
+			slog.Warn("Failed to parse threshold date", "date", record[0], "error", err)
+			continue
+		}
+
+		parsers := []func(string) (float64, error){
+			parseNumber,
+			parseNumber,
+			parseNumber,
+			func(s string) (float64, error) { return parsePace(s, thresholdPaceMeters) },
+			func(s string) (float64, error) { return parsePace(s, swimPaceMeters) },
+		}
+		values := make([]float64, len(parsers))
+		valid := true
+		for j, parse := range parsers {
+			if j+1 >= len(record) || strings.TrimSpace(record[j+1]) == "" {
+				continue
+			}
+			value, err := parse(strings.TrimSpace(record[j+1]))
+			if err != nil {
+				slog.Warn("Failed to parse threshold", "date", record[0], "value", record[j+1], "error", err)
+				valid = false
+				break
+			}
+			values[j] = value
+		}
+		if !valid {
+			continue
+		}
+
+		m.records = append(m.records, ThresholdRecord{
+			Date:              date,
+			LTHR:              values[0],
+			RestingHR:         values[1],
+			MaxHR:             values[2],
+			ThresholdPace:     values[3],
+			CriticalSwimSpeed: values[4],
+		})
+	}
+
+	sort.SliceStable(m.records, func(i, j int) bool { return m.records[i].Date.Before(m.records[j].Date) })
+
+	slog.Info("Loaded threshold data", "records", len(m.records))
+	return nil
+}
+
+// GetThresholdsForDate returns the latest value of every threshold set on
+// or before the given date
+func (m *ThresholdManager) GetThresholdsForDate(date time.Time) strava.Thresholds {
+	var thresholds strava.Thresholds
+	for _, record := range m.records {
+		if record.Date.After(date) {
+			break
+		}
+		if record.LTHR > 0 {
+			thresholds.LTHR = record.LTHR
+		}
+		if record.RestingHR > 0 {
+			thresholds.RestingHR = record.RestingHR
+		}
+		if record.MaxHR > 0 {
+			thresholds.MaxHR = record.MaxHR
+		}
+		if record.ThresholdPace > 0 {
+			thresholds.ThresholdPace = record.ThresholdPace
+		}
+		if record.CriticalSwimSpeed > 0 {
+			thresholds.CriticalSwimSpeed = record.CriticalSwimSpeed
+		}
+	}
+	return thresholds
+}
+
+func (m *ThresholdManager) GetAllRecords() []ThresholdRecord {
+	return m.records
+}
+
+func parseNumber(s string) (float64, error) {
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if value < 0 {
+		return 0, fmt.Errorf("negative value %v", value)
+	}
+	return value, nil
+}
+
+// parsePace converts a "m:ss" pace over the given distance to a speed in m/s
+func parsePace(s string, meters float64) (float64, error) {
+	minutes, seconds, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, fmt.Errorf("pace %q is not in m:ss format", s)
+	}
+	m, err := strconv.Atoi(minutes)
+	if err != nil {
+		return 0, fmt.Errorf("invalid pace minutes: %w", err)
+	}
+	sec, err := strconv.ParseFloat(seconds, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid pace seconds: %w", err)
+	}
+	total := float64(m*60) + sec
+	if m < 0 || sec < 0 || sec >= 60 || total <= 0 {
+		return 0, fmt.Errorf("pace %q is out of range", s)
+	}
+	return meters / total, nil
+}
@@ -0,0 +1,115 @@
+package threshold
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestThresholdManager(t *testing.T) {
+	csvFile := filepath.Join(t.TempDir(), "thresholds.csv")
+
+	csvContent := `date,lthr,resting_hr,max_hr
+2024-06-01,168,,
+2024-01-01,165,48,190
+2024-09-01,,45
+2024-10-01,abc,45,190
+not-a-date,170,45,190`
+
+	if err := os.WriteFile(csvFile, []byte(csvContent), 0644); err != nil {
+		t.Fatalf("Failed to create test CSV file: %v", err)
+	}
+
+	manager := NewThresholdManager(csvFile, time.UTC)
+	if err := manager.LoadThresholdData(); err != nil {
+		t.Fatalf("LoadThresholdData() error = %v", err)
+	}
+
+	if records := manager.GetAllRecords(); len(records) != 3 {
+		t.Fatalf("Expected 3 valid records, got %d", len(records))
+	}
+
+	tests := []struct {
+		date                   time.Time
+		lthr, restingHR, maxHR float64
+	}{
+		{time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC), 0, 0, 0},
+		{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 165, 48, 190},
+		{time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC), 168, 48, 190},
+		{time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC), 168, 45, 190},
+	}
+
+	for _, tt := range tests {
+		got := manager.GetThresholdsForDate(tt.date)
+		if got.LTHR != tt.lthr || got.RestingHR != tt.restingHR || got.MaxHR != tt.maxHR {
+			t.Errorf("GetThresholdsForDate(%v) = %+v, want %v/%v/%v", tt.date, got, tt.lthr, tt.restingHR, tt.maxHR)
+		}
+	}
+}
+
+func TestThresholdManagerMissingFile(t *testing.T) {
+	manager := NewThresholdManager(filepath.Join(t.TempDir(), "missing.csv"), time.UTC)
+	if err := manager.LoadThresholdData(); err != nil {
+		t.Fatalf("LoadThresholdData() error = %v, want nil for a missing file", err)
+	}
+	if thresholds := manager.GetThresholdsForDate(time.Now()); thresholds.LTHR != 0 {
+		t.Errorf("Expected no thresholds for a missing file, got %+v", thresholds)
+	}
+}
+
+func TestThresholdManagerPaces(t *testing.T) {
+	csvFile := filepath.Join(t.TempDir(), "thresholds.csv")
+
+	csvContent := `date,lthr,resting_hr,max_hr,threshold_pace,css
+2024-01-01,165,48,190,4:10,1:40
+2024-03-01,,,,4:00
+2024-04-01,,,,4:61
+2024-05-01,,,,,95`
+
+	if err := os.WriteFile(csvFile, []byte(csvContent), 0644); err != nil {
+		t.Fatalf("Failed to create test CSV file: %v", err)
+	}
+
+	manager := NewThresholdManager(csvFile, time.UTC)
+	if err := manager.LoadThresholdData(); err != nil {
+		t.Fatalf("LoadThresholdData() error = %v", err)
+	}
+
+	if records := manager.GetAllRecords(); len(records) != 2 {
+		t.Fatalf("Expected 2 valid records, got %d", len(records))
+	}
+
+	got := manager.GetThresholdsForDate(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+	if math.Abs(got.ThresholdPace-1000.0/250) > 1e-9 || math.Abs(got.CriticalSwimSpeed-1) > 1e-9 {
+		t.Errorf("GetThresholdsForDate(2024-02-01) = %+v, want 4.0 m/s and 1.0 m/s", got)
+	}
+
+	got = manager.GetThresholdsForDate(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	if math.Abs(got.ThresholdPace-1000.0/240) > 1e-9 || math.Abs(got.CriticalSwimSpeed-1) > 1e-9 || got.LTHR != 165 {
+		t.Errorf("GetThresholdsForDate(2024-06-01) = %+v, want the new threshold pace and the earlier CSS", got)
+	}
+}
+
+func TestThresholdManagerLocation(t *testing.T) {
+	csvFile := filepath.Join(t.TempDir(), "thresholds.csv")
+	if err := os.WriteFile(csvFile, []byte("date,lthr\n2024-01-01,160\n2024-06-01,165\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test CSV file: %v", err)
+	}
+
+	tokyo := time.FixedZone("Asia/Tokyo", 9*60*60)
+	manager := NewThresholdManager(csvFile, tokyo)
+	if err := manager.LoadThresholdData(); err != nil {
+		t.Fatalf("LoadThresholdData() error = %v", err)
+	}
+
+	// The day starts at midnight in Tokyo, not at midnight UTC
+	morning := time.Date(2024, 6, 1, 7, 0, 0, 0, tokyo)
+	if got := manager.GetThresholdsForDate(morning); got.LTHR != 165 {
+		t.Errorf("GetThresholdsForDate(%v) LTHR = %v, want 165", morning, got.LTHR)
+	}
+	if got := manager.GetThresholdsForDate(morning.Add(-8 * time.Hour)); got.LTHR != 160 {
+		t.Errorf("GetThresholdsForDate() the evening before LTHR = %v, want 160", got.LTHR)
+	}
+}
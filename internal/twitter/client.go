@@ -36,6 +36,67 @@ func (c *Client) PostActivity(activity *strava.ActivityData) error {
 	return nil
 }
 
+// PostMaintenanceReminder posts a reminder that a gear maintenance item is due
+func (c *Client) PostMaintenanceReminder(status *strava.MaintenanceStatus) error {
+	slog.Info("Posting maintenance reminder to Twitter", "gear_id", status.GearID, "item", status.Item)
+
+	tweetText := c.formatMaintenanceTweet(status)
+
+	// As with activities, the tweet is only logged until the API is wired up
+	slog.Info("Tweet content", "text", tweetText)
+
+	return nil
+}
+
+// PostOverloadAlert posts a warning that a workload metric crossed its danger
+// threshold
+func (c *Client) PostOverloadAlert(alert *strava.OverloadAlert) error {
+	slog.Info("Posting overload alert to Twitter", "metric", alert.Metric, "value", alert.Value)
+
+	tweetText := c.formatOverloadTweet(alert)
+
+	// As with activities, the tweet is only logged until the API is wired up
+	slog.Info("Tweet content", "text", tweetText)
+
+	return nil
+}
+
+func (c *Client) formatOverloadTweet(alert *strava.OverloadAlert) string {
+	var metric, comparison string
+	switch alert.Metric {
+	case strava.WorkloadMetricACWR:
+		metric, comparison = "急性:慢性負荷比 (ACWR)", "超えました"
+	case strava.WorkloadMetricMonotony:
+		metric, comparison = "トレーニングの単調さ (Monotony)", "超えました"
+	case strava.WorkloadMetricTSB:
+		metric, comparison = "フォーム (TSB)", "下回りました"
+	default:
+		metric, comparison = alert.Metric, "超えました"
+	}
+
+	return fmt.Sprintf(`オーバーロード注意
+%s: %.2f（%s）
+危険域の %.2f を%s。負荷の上げすぎに注意してください`,
+		metric,
+		alert.Value,
+		alert.Date.Format("2006-01-02"),
+		alert.Threshold,
+		comparison,
+	)
+}
+
+func (c *Client) formatMaintenanceTweet(status *strava.MaintenanceStatus) string {
+	return fmt.Sprintf(`メンテナンス時期です
+機材: %s
+項目: %s
+前回から: %.0fkm（%.0fkmごと）`,
+		status.GearName,
+		status.Item,
+		status.DistanceKm,
+		status.IntervalKm,
+	)
+}
+
 func (c *Client) formatActivityTweet(activity *strava.ActivityData) string {
 	// Format the date in Japanese style
 	dateStr := activity.StartDate.Format("2006年01月02日(Mon)")
@@ -65,6 +65,42 @@ func TestFormatActivityTweet(t *testing.T) {
 	}
 }
 
+func TestFormatMaintenanceTweet(t *testing.T) {
+	client := NewClient(&config.Config{})
+
+	tweet := client.formatMaintenanceTweet(&strava.MaintenanceStatus{
+		GearID:     "b1111",
+		GearName:   "Tarmac",
+		Item:       "chain",
+		IntervalKm: 3000,
+		DistanceKm: 3012.4,
+		Due:        true,
+	})
+
+	for _, element := range []string{"メンテナンス時期です", "Tarmac", "chain", "3012km", "3000kmごと"} {
+		if !contains(tweet, element) {
+			t.Errorf("Tweet does not contain expected element: %s", element)
+		}
+	}
+}
+
+func TestFormatOverloadTweet(t *testing.T) {
+	client := NewClient(&config.Config{})
+
+	tweet := client.formatOverloadTweet(&strava.OverloadAlert{
+		Date:      time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		Metric:    strava.WorkloadMetricTSB,
+		Value:     -34.2,
+		Threshold: -30,
+	})
+
+	for _, element := range []string{"オーバーロード注意", "TSB", "-34.20", "2024-06-01", "-30.00 を下回りました"} {
+		if !contains(tweet, element) {
+			t.Errorf("Tweet does not contain expected element: %s", element)
+		}
+	}
+}
+
 func TestTranslateActivityType(t *testing.T) {
 	cfg := &config.Config{}
 	client := NewClient(cfg)
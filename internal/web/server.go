@@ -4,11 +4,13 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"time"
 
+	"stravaDataImporter/internal/auth"
 	"stravaDataImporter/internal/config"
 	"stravaDataImporter/internal/db"
 	"stravaDataImporter/internal/handlers"
@@ -23,7 +25,7 @@ type Server struct {
 	handler    *handlers.Handler
 }
 
-func NewServer(cfg *config.Config, influxClient *db.InfluxDBClient) (*Server, error) {
+func NewServer(cfg *config.Config, influxClient *db.InfluxDBClient, tokenStore *auth.TokenStore) (*Server, error) {
 	// Set Gin mode
 	if cfg.Port == "8080" {
 		gin.SetMode(gin.DebugMode)
@@ -84,7 +86,7 @@ func NewServer(cfg *config.Config, influxClient *db.InfluxDBClient) (*Server, er
 
 	var handler *handlers.Handler
 	if influxClient != nil {
-		handler = handlers.NewHandler(cfg, influxClient)
+		handler = handlers.NewHandler(cfg, influxClient, tokenStore)
 	} else {
 		// For tests, create a dummy handler that won't fail
 		// This is a temporary solution for testing
@@ -143,13 +145,20 @@ func (s *Server) setupRoutes() {
 	s.router.GET("/", s.handler.Home)
 	s.router.GET("/login", s.handler.Login)
 
+	// Strava push subscription
+	webhook := s.router.Group("/webhook")
+	{
+		webhook.GET("/strava", s.handler.StravaWebhookChallenge)
+		webhook.POST("/strava", s.handler.StravaWebhookEvent)
+	}
+
 	// Auth routes
 	auth := s.router.Group("/auth")
 	{
 		auth.GET("/login", s.handler.AuthLogin)
 		auth.GET("/callback", s.handler.AuthCallback)
-		auth.POST("/logout", s.handler.AuthLogout)
-		auth.POST("/refresh", s.handler.RefreshToken)
+		auth.POST("/logout", authMiddleware(s.handler), s.handler.AuthLogout)
+		auth.POST("/refresh", authMiddleware(s.handler), s.handler.RefreshToken)
 	}
 
 	// Protected routes
@@ -167,12 +176,51 @@ func (s *Server) setupRoutes() {
 		api.GET("/health", s.handler.Health)
 		api.GET("/activities", s.handler.GetActivities)
 		api.POST("/auth/refresh", s.handler.RefreshToken)
+		api.POST("/reconcile", s.handler.Reconcile)
+		api.GET("/fitness", s.handler.GetFitness)
+		api.GET("/workload", s.handler.GetWorkload)
+		api.GET("/summaries/weekly", s.handler.GetWeeklySummaries)
+		api.GET("/summaries/monthly", s.handler.GetMonthlySummaries)
+		api.GET("/summaries/yearly", s.handler.GetYearlySummaries)
+		api.GET("/summaries/rolling", s.handler.GetRollingSummaries)
+		api.GET("/power-curve", s.handler.GetPowerCurve)
+		api.GET("/critical-power", s.handler.GetCriticalPower)
+		api.GET("/ftp-suggestions", s.handler.GetFTPSuggestions)
+		api.POST("/ftp-suggestions/:id/accept", s.handler.AcceptFTPSuggestion)
+		api.POST("/ftp-suggestions/:id/dismiss", s.handler.DismissFTPSuggestion)
+	}
+}
+
+// SetWebhookProcessor connects the Strava webhook routes to the importer
+func (s *Server) SetWebhookProcessor(processor handlers.WebhookProcessor) {
+	if s.handler != nil {
+		s.handler.SetWebhookProcessor(processor)
+	}
+}
+
+// SetReconciler connects the reconcile endpoint to the importer
+func (s *Server) SetReconciler(reconciler handlers.Reconciler) {
+	if s.handler != nil {
+		s.handler.SetReconciler(reconciler)
 	}
 }
 
-func (s *Server) Start() error {
+// SetFTPSuggester connects the FTP suggestion endpoints to the importer
+func (s *Server) SetFTPSuggester(suggester handlers.FTPSuggester) {
+	if s.handler != nil {
+		s.handler.SetFTPSuggester(suggester)
+	}
+}
+
+// Start serves requests until Shutdown is called. Request contexts are
+// derived from ctx, so cancelling it aborts in-flight Strava and InfluxDB calls.
+func (s *Server) Start(ctx context.Context) error {
 	slog.Info("Starting web server", "port", s.config.Port)
 
+	s.httpServer.BaseContext = func(net.Listener) context.Context {
+		return ctx
+	}
+
 	if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		return fmt.Errorf("failed to start server: %w", err)
 	}
@@ -210,8 +258,15 @@ func requestTimeMiddleware() gin.HandlerFunc {
 	}
 }
 
+// authMiddleware lets through clients holding a session from the OAuth
+// callback while the server has a usable Strava token
 func authMiddleware(handler *handlers.Handler) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if handler.IsAuthorized(c) {
+			c.Next()
+			return
+		}
+
 		// Check if this is an API route
 		if c.FullPath() != "" && c.FullPath()[:4] == "/api" {
 			// For API routes, return JSON error
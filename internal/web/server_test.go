@@ -1,34 +1,56 @@
 package web
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"stravaDataImporter/internal/auth"
 	"stravaDataImporter/internal/config"
+	"stravaDataImporter/internal/handlers"
 	"stravaDataImporter/internal/strava"
+
+	"github.com/gin-gonic/gin"
 )
 
 // InfluxDBClientのインターフェイスを実装するモック
 type mockInfluxDBClient struct{}
 
-func (m *mockInfluxDBClient) Close()                                                   {}
-func (m *mockInfluxDBClient) WriteActivity(activity *strava.ActivityData) error        { return nil }
-func (m *mockInfluxDBClient) WriteWeeklySummary(summary *strava.WeeklySummary) error   { return nil }
-func (m *mockInfluxDBClient) WriteMonthlySummary(summary *strava.MonthlySummary) error { return nil }
-func (m *mockInfluxDBClient) WriteYearlySummary(summary *strava.YearlySummary) error   { return nil }
-func (m *mockInfluxDBClient) GetLatestActivity() (*strava.ActivityData, error)         { return nil, nil }
-func (m *mockInfluxDBClient) GetWeeklyTrend() ([]strava.WeeklySummary, error)          { return nil, nil }
-func (m *mockInfluxDBClient) SaveToken(token *strava.TokenData) error                  { return nil }
-func (m *mockInfluxDBClient) LoadToken() (*strava.TokenData, error)                    { return nil, nil }
-func (m *mockInfluxDBClient) ClearToken() error                                        { return nil }
+func (m *mockInfluxDBClient) Close(ctx context.Context) {}
+func (m *mockInfluxDBClient) WriteActivity(ctx context.Context, activity *strava.ActivityData) error {
+	return nil
+}
+func (m *mockInfluxDBClient) WriteWeeklySummary(ctx context.Context, summary *strava.WeeklySummary) error {
+	return nil
+}
+func (m *mockInfluxDBClient) WriteMonthlySummary(ctx context.Context, summary *strava.MonthlySummary) error {
+	return nil
+}
+func (m *mockInfluxDBClient) WriteYearlySummary(ctx context.Context, summary *strava.YearlySummary) error {
+	return nil
+}
+func (m *mockInfluxDBClient) GetLatestActivity(ctx context.Context) (*strava.ActivityData, error) {
+	return nil, nil
+}
+func (m *mockInfluxDBClient) GetWeeklyTrend(ctx context.Context) ([]strava.WeeklySummary, error) {
+	return nil, nil
+}
+func (m *mockInfluxDBClient) SaveToken(ctx context.Context, token *strava.TokenData) error {
+	return nil
+}
+func (m *mockInfluxDBClient) LoadToken(ctx context.Context) (*strava.TokenData, error) {
+	return nil, nil
+}
+func (m *mockInfluxDBClient) ClearToken(ctx context.Context) error { return nil }
 
 func TestNewServer(t *testing.T) {
 	cfg := &config.Config{
 		Port: "8080",
 	}
 
-	server, err := NewServer(cfg, nil)
+	server, err := NewServer(cfg, nil, nil)
 	if err != nil {
 		t.Fatalf("NewServer() returned error: %v", err)
 	}
@@ -54,7 +76,7 @@ func TestHealthRoute(t *testing.T) {
 		Port: "8080",
 	}
 
-	server, err := NewServer(cfg, nil)
+	server, err := NewServer(cfg, nil, nil)
 	if err != nil {
 		t.Fatalf("NewServer() returned error: %v", err)
 	}
@@ -82,7 +104,7 @@ func TestCORSMiddleware(t *testing.T) {
 		Port: "8080",
 	}
 
-	server, err := NewServer(cfg, nil)
+	server, err := NewServer(cfg, nil, nil)
 	if err != nil {
 		t.Fatalf("NewServer() returned error: %v", err)
 	}
@@ -110,7 +132,7 @@ func TestHomeRedirect(t *testing.T) {
 		Port: "8080",
 	}
 
-	server, err := NewServer(cfg, nil)
+	server, err := NewServer(cfg, nil, nil)
 	if err != nil {
 		t.Fatalf("NewServer() returned error: %v", err)
 	}
@@ -138,7 +160,7 @@ func TestProtectedRouteWithoutAuth(t *testing.T) {
 		Port: "8080",
 	}
 
-	server, err := NewServer(cfg, nil)
+	server, err := NewServer(cfg, nil, nil)
 	if err != nil {
 		t.Fatalf("NewServer() returned error: %v", err)
 	}
@@ -160,3 +182,67 @@ func TestProtectedRouteWithoutAuth(t *testing.T) {
 		t.Errorf("Protected route redirected to wrong location: got %v want %v", location, "/login")
 	}
 }
+
+func TestAuthMiddlewareRequiresSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// The server holds a valid Strava token
+	tokenStore := auth.NewTokenStore(&mockInfluxDBClient{})
+	if err := tokenStore.SaveToken(context.Background(), &strava.TokenData{AccessToken: "access", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("SaveToken() error = %v", err)
+	}
+
+	handler := handlers.NewHandler(&config.Config{}, nil, tokenStore)
+	router := gin.New()
+	router.GET("/session", func(c *gin.Context) {
+		if err := handler.StartSession(c); err != nil {
+			t.Fatalf("StartSession() error = %v", err)
+		}
+	})
+	router.POST("/api/v1/reconcile", authMiddleware(handler), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/portal", authMiddleware(handler), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	// Anonymous clients are rejected
+	req, _ := http.NewRequest("POST", "/api/v1/reconcile", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous API request status = %v, want %v", rr.Code, http.StatusUnauthorized)
+	}
+
+	req, _ = http.NewRequest("GET", "/portal", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusFound || rr.Header().Get("Location") != "/login" {
+		t.Errorf("anonymous portal request = %v to %q, want a redirect to /login", rr.Code, rr.Header().Get("Location"))
+	}
+
+	req, _ = http.NewRequest("POST", "/api/v1/reconcile", nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: "forged"})
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("forged session status = %v, want %v", rr.Code, http.StatusUnauthorized)
+	}
+
+	// A client with an issued session is let through
+	req, _ = http.NewRequest("GET", "/session", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	cookies := rr.Result().Cookies()
+	if len(cookies) == 0 {
+		t.Fatal("StartSession() did not set a cookie")
+	}
+
+	req, _ = http.NewRequest("POST", "/api/v1/reconcile", nil)
+	req.AddCookie(cookies[0])
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("signed in API request status = %v, want %v", rr.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,152 @@
+package weight
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"stravaDataImporter/internal/strava"
+)
+
+// WeightManager holds the dated body weight history from the weight CSV file
+// and the weights recorded from the Strava athlete profile. Every record is
+// dated at midnight of its day in the athlete location. It is safe for
+// concurrent use.
+type WeightManager struct {
+	filePath string
+	location *time.Location
+
+	mu      sync.RWMutex
+	records []strava.WeightRecord
+}
+
+// NewWeightManager creates a manager whose file dates are days in loc
+func NewWeightManager(filePath string, loc *time.Location) *WeightManager {
+	return &WeightManager{
+		filePath: filePath,
+		location: loc,
+		records:  make([]strava.WeightRecord, 0),
+	}
+}
+
+// LoadWeightData reads the history from the CSV file, replacing the records
+// of an earlier load and keeping those added from Strava. A missing file
+// means only the Strava weight is used.
+func (m *WeightManager) LoadWeightData() error {
+	file, err := os.Open(m.filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		slog.Info("No weight file found, using the Strava weight only", "path", m.filePath)
+		m.replace(strava.WeightSourceFile, nil)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open weight file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return fmt.Errorf("failed to read CSV: %w", err)
+	}
+
+	loaded := make([]strava.WeightRecord, 0, len(records))
+	for i, record := range records {
+		if i == 0 && len(record) > 0 && record[0] == "date" {
+			// Skip header row
+			continue
+		}
+
+		if len(record) < 2 {
+			continue
+		}
+
+		date, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(record[0]), m.location)
+		if err != nil {
+			slog.Warn("Failed to parse weight date", "date", record[0], "error", err)
+			continue
+		}
+
+		weight, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil || weight <= 0 {
+			slog.Warn("Failed to parse weight", "date", record[0], "weight", record[1], "error", err)
+			continue
+		}
+
+		loaded = append(loaded, strava.WeightRecord{Date: date, Weight: weight, Source: strava.WeightSourceFile})
+	}
+
+	m.replace(strava.WeightSourceFile, loaded)
+	slog.Info("Loaded weight data", "records", len(loaded))
+	return nil
+}
+
+// AddRecords adds weights from another source, such as the Strava weights
+// stored in InfluxDB
+func (m *WeightManager) AddRecords(records ...strava.WeightRecord) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.records = append(m.records, records...)
+	m.sortRecords()
+}
+
+// GetWeightForDate returns the most recent weight on or before the given
+// date, or 0 when none is known. On a day with both a file and a Strava
+// record the file wins.
+func (m *WeightManager) GetWeightForDate(date time.Time) float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var weight float64
+	for _, record := range m.records {
+		if record.Date.After(date) {
+			break
+		}
+		weight = record.Weight
+	}
+	return weight
+}
+
+func (m *WeightManager) GetAllRecords() []strava.WeightRecord {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append([]strava.WeightRecord(nil), m.records...)
+}
+
+// replace swaps the records of one source for the given ones
+func (m *WeightManager) replace(source string, records []strava.WeightRecord) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := make([]strava.WeightRecord, 0, len(m.records)+len(records))
+	for _, record := range m.records {
+		if record.Source != source {
+			kept = append(kept, record)
+		}
+	}
+	m.records = append(kept, records...)
+	m.sortRecords()
+}
+
+// sortRecords orders the records by date, with file records after Strava
+// records of the same day so that the lookup prefers them
+func (m *WeightManager) sortRecords() {
+	sort.SliceStable(m.records, func(i, j int) bool {
+		a, b := m.records[i], m.records[j]
+		if !a.Date.Equal(b.Date) {
+			return a.Date.Before(b.Date)
+		}
+		return a.Source == strava.WeightSourceStrava && b.Source == strava.WeightSourceFile
+	})
+}
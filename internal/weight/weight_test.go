@@ -0,0 +1,97 @@
+package weight
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"stravaDataImporter/internal/strava"
+)
+
+func TestWeightManager(t *testing.T) {
+	csvFile := filepath.Join(t.TempDir(), "weight.csv")
+
+	csvContent := `date,weight
+2024-06-01,68.0
+2024-01-01,70.5
+2024-09-01,-1
+not-a-date,69`
+
+	if err := os.WriteFile(csvFile, []byte(csvContent), 0644); err != nil {
+		t.Fatalf("Failed to create test CSV file: %v", err)
+	}
+
+	manager := NewWeightManager(csvFile, time.UTC)
+	if err := manager.LoadWeightData(); err != nil {
+		t.Fatalf("LoadWeightData() error = %v", err)
+	}
+
+	if records := manager.GetAllRecords(); len(records) != 2 {
+		t.Fatalf("Expected 2 valid records, got %d", len(records))
+	}
+
+	// Weights recorded from Strava fill in between the file records and
+	// lose against a file record of the same day
+	manager.AddRecords(
+		strava.WeightRecord{Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Weight: 69.2, Source: strava.WeightSourceStrava},
+		strava.WeightRecord{Date: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), Weight: 67.0, Source: strava.WeightSourceStrava},
+	)
+
+	tests := []struct {
+		date   time.Time
+		weight float64
+	}{
+		{time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC), 0},
+		{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 70.5},
+		{time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), 69.2},
+		{time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), 68.0},
+		{time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC), 68.0},
+	}
+
+	for _, tt := range tests {
+		if got := manager.GetWeightForDate(tt.date); got != tt.weight {
+			t.Errorf("GetWeightForDate(%v) = %v, want %v", tt.date, got, tt.weight)
+		}
+	}
+
+	// Reloading the file keeps the Strava records
+	if err := manager.LoadWeightData(); err != nil {
+		t.Fatalf("LoadWeightData() error = %v", err)
+	}
+	if records := manager.GetAllRecords(); len(records) != 4 {
+		t.Errorf("Expected 4 records after a reload, got %d", len(records))
+	}
+}
+
+func TestWeightManagerMissingFile(t *testing.T) {
+	manager := NewWeightManager(filepath.Join(t.TempDir(), "missing.csv"), time.UTC)
+	if err := manager.LoadWeightData(); err != nil {
+		t.Fatalf("LoadWeightData() error = %v, want nil for a missing file", err)
+	}
+	if weight := manager.GetWeightForDate(time.Now()); weight != 0 {
+		t.Errorf("Expected no weight for a missing file, got %v", weight)
+	}
+}
+
+func TestWeightManagerLocation(t *testing.T) {
+	csvFile := filepath.Join(t.TempDir(), "weight.csv")
+	if err := os.WriteFile(csvFile, []byte("date,weight\n2024-06-01,68.0\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test CSV file: %v", err)
+	}
+
+	tokyo := time.FixedZone("Asia/Tokyo", 9*60*60)
+	manager := NewWeightManager(csvFile, tokyo)
+	if err := manager.LoadWeightData(); err != nil {
+		t.Fatalf("LoadWeightData() error = %v", err)
+	}
+
+	// The file date is a day in Tokyo, which starts at 15:00 UTC the day before
+	morning := time.Date(2024, 6, 1, 7, 0, 0, 0, tokyo)
+	if weight := manager.GetWeightForDate(morning); weight != 68.0 {
+		t.Errorf("GetWeightForDate(%v) = %v, want 68.0", morning, weight)
+	}
+	if weight := manager.GetWeightForDate(morning.Add(-8 * time.Hour)); weight != 0 {
+		t.Errorf("GetWeightForDate() the day before = %v, want 0", weight)
+	}
+}